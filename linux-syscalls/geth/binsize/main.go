@@ -0,0 +1,171 @@
+// Command binsize reports the text, rodata, data and bss bytes of a Go ELF
+// binary per package and per symbol, and compares two binaries.
+//
+// With -build, it builds stateless-exec for every guest variant (linux/riscv64
+// with and without the ECALL crypto backend, and the tamago boards when -tamago
+// names a tamago Go toolchain) and reports each one, so that the cost of an
+// import can be followed across the targets that care about ELF size.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/eth-act/riscv-compilation/elfsize"
+)
+
+// variant is one way of building the guest.
+type variant struct {
+	name    string
+	goos    string
+	tags    string
+	ldflags string
+}
+
+var variants = []variant{
+	{name: "linux-riscv64", goos: "linux"},
+	{name: "linux-riscv64-ecallcrypto", goos: "linux", tags: "ecallcrypto"},
+	// tinygo.wasm keeps fastcache off unix.Mmap, which tamago does not have.
+	{name: "tamago-zkvm", goos: "tamago", tags: "tinygo.wasm", ldflags: "-T 0x80010000 -R 0x1000"},
+	{name: "tamago-sifive_u", goos: "tamago", tags: "sifive_u,tinygo.wasm", ldflags: "-T 0x80010000 -R 0x1000"},
+	{name: "tamago-sifive_u-semihosting", goos: "tamago", tags: "sifive_u,semihosting,tinygo.wasm", ldflags: "-T 0x80010000 -R 0x1000"},
+}
+
+func main() {
+	var (
+		packages = flag.Int("packages", 30, "print the N largest packages (0 for all)")
+		symbols  = flag.Int("symbols", 0, "print the N largest symbols")
+		asJSON   = flag.Bool("json", false, "print the report as JSON")
+		build    = flag.Bool("build", false, "build every variant of the package argument (default ./stateless-exec) and report each")
+		out      = flag.String("o", "", "with -build, keep the binaries in this directory")
+		tamago   = flag.String("tamago", os.Getenv("TAMAGO"), "with -build, the tamago go command; tamago variants are skipped without it")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: binsize [flags] <elf>\n       binsize [flags] <elf-a> <elf-b>\n       binsize -build [flags] [package]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *build {
+		if flag.NArg() > 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		pkg := "./stateless-exec"
+		if flag.NArg() == 1 {
+			pkg = flag.Arg(0)
+		}
+		if err := buildAll(pkg, *out, *tamago, *packages, *symbols, *asJSON); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	switch flag.NArg() {
+	case 1:
+		r, err := elfsize.Load(flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		if *asJSON {
+			writeJSON(r)
+			return
+		}
+		fmt.Print(r.Format(*packages, *symbols))
+	case 2:
+		a, err := elfsize.Load(flag.Arg(0))
+		if err != nil {
+			fatal(err)
+		}
+		b, err := elfsize.Load(flag.Arg(1))
+		if err != nil {
+			fatal(err)
+		}
+		diff := elfsize.Compare(a, b)
+		if *asJSON {
+			writeJSON(diff)
+			return
+		}
+		fmt.Print(diff.Format(*packages, *symbols))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// buildAll builds pkg for every variant into dir, or a temporary directory,
+// and reports each binary followed by a summary.
+func buildAll(pkg, dir, tamago string, packages, symbols int, asJSON bool) error {
+	if dir == "" {
+		tmp, err := os.MkdirTemp("", "binsize")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	} else if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var reports []*elfsize.Report
+	for _, v := range variants {
+		gocmd := "go"
+		if v.goos == "tamago" {
+			if tamago == "" {
+				fmt.Fprintf(os.Stderr, "binsize: skipping %s: no tamago toolchain (-tamago)\n", v.name)
+				continue
+			}
+			gocmd = tamago
+		}
+		path := filepath.Join(dir, v.name)
+		args := []string{"build", "-trimpath", "-o", path}
+		if v.tags != "" {
+			args = append(args, "-tags", v.tags)
+		}
+		if v.ldflags != "" {
+			args = append(args, "-ldflags", v.ldflags)
+		}
+		cmd := exec.Command(gocmd, append(args, pkg)...)
+		cmd.Env = append(os.Environ(), "GOOS="+v.goos, "GOARCH=riscv64")
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("building %s: %v", v.name, err)
+		}
+		r, err := elfsize.Load(path)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, r)
+	}
+
+	if asJSON {
+		writeJSON(reports)
+		return nil
+	}
+	for _, r := range reports {
+		fmt.Println(r.Format(packages, symbols))
+	}
+	fmt.Printf("%-28s %10s %10s %10s %10s %10s\n", "VARIANT", "TEXT", "RODATA", "DATA", "BSS", "FILE")
+	for _, r := range reports {
+		s := r.Sections
+		fmt.Printf("%-28s %10d %10d %10d %10d %10d\n", filepath.Base(r.Path), s[elfsize.Text], s[elfsize.Rodata], s[elfsize.Data], s[elfsize.Bss], r.FileSize)
+	}
+	return nil
+}
+
+func writeJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "binsize: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,50 @@
+//go:build tamago
+
+package zkvm
+
+import (
+	_ "unsafe"
+
+	"github.com/eth-act/riscv-compilation/deterministic"
+)
+
+// TimeStep is how far, in nanoseconds, the clock advances every time it is
+// read. There is no timer peripheral: time is virtual, advances
+// deterministically and jumps forward whenever the runtime would idle.
+var TimeStep int64 = deterministic.DefaultTimeStep
+
+// Epoch is the wall clock time at boot, in nanoseconds since the Unix epoch.
+// The runtime derives the wall clock from nanotime, so the clock starts here.
+var Epoch int64 = deterministic.DefaultEpoch
+
+var clock int64 // nanoseconds since boot
+
+//go:linkname nanotime1 runtime.nanotime1
+func nanotime1() int64 {
+	clock += TimeStep
+	return Epoch + clock
+}
+
+func advance(until int64) {
+	if until -= Epoch; until > clock {
+		clock = until
+	}
+}
+
+// Seed seeds the random number generator behind crypto/rand and the runtime.
+// A zkVM run must be reproducible, so the default is fixed, and the same as
+// deterministic.DefaultSeed. It is spelled out because initRNG runs before
+// package initialisation, when only static values are in place.
+var Seed = [32]byte{'z', 'k', 'v', 'm'}
+
+var drbg deterministic.Random
+
+//go:linkname initRNG runtime.initRNG
+func initRNG() {
+	drbg.Seed(Seed)
+}
+
+//go:linkname getRandomData runtime.getRandomData
+func getRandomData(b []byte) {
+	drbg.Read(b)
+}
@@ -0,0 +1,32 @@
+//go:build tamago && !linkprintk
+
+package zkvm
+
+import (
+	"unsafe"
+)
+
+// console buffers output until a newline, so that the host sees one write
+// call per line instead of one per byte.
+var (
+	console    [256]byte
+	consoleLen int
+)
+
+//go:linkname printk runtime.printk
+func printk(c byte) {
+	console[consoleLen] = c
+	consoleLen++
+	if c == '\n' || consoleLen == len(console) {
+		flush()
+	}
+}
+
+// flush writes the buffered console output to the host's standard output.
+func flush() {
+	if consoleLen == 0 {
+		return
+	}
+	ecall(SysWrite, 1, uint64(uintptr(unsafe.Pointer(&console[0]))), uint64(consoleLen))
+	consoleLen = 0
+}
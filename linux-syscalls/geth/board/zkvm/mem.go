@@ -0,0 +1,17 @@
+//go:build tamago && !linkramstart
+
+package zkvm
+
+import (
+	_ "unsafe"
+)
+
+// Applications can override ramStart with the `linkramstart` build tag, to
+// match the memory map of a particular zkVM. The text segment must then be
+// linked to the new base (`-ldflags "-T <ramStart+0x10000>"`).
+
+//go:linkname ramStart runtime.ramStart
+var ramStart uint64 = 0x80000000
+
+//go:linkname ramStackOffset runtime.ramStackOffset
+var ramStackOffset uint64 = 0x100
@@ -0,0 +1,12 @@
+//go:build tamago && !linkramsize
+
+package zkvm
+
+import (
+	_ "unsafe"
+)
+
+// Applications can override ramSize with the `linkramsize` build tag.
+
+//go:linkname ramSize runtime.ramSize
+var ramSize uint64 = 0x20000000 // 512MB
@@ -0,0 +1,67 @@
+//go:build tamago
+
+// Package zkvm provides hardware initialization, automatically on import, for
+// a generic zkVM-style RISC-V machine: a single RV64 hart in machine mode with
+// flat RAM and no peripherals. Console output and program exit go through
+// ECALL, which the zkVM (or rvemu) services; there is no UART and no timer.
+//
+// The ECALL convention follows the Linux numbering used by the linuxabi
+// package: the call number is in a7, the arguments in a0-a2 and the result in
+// a0.
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=riscv64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/usbarmory/tamago.
+package zkvm
+
+import (
+	"math"
+	"runtime"
+	_ "unsafe"
+
+	"github.com/usbarmory/tamago/riscv64"
+)
+
+// ECALL numbers understood by the host.
+const (
+	SysWrite = 64
+	SysExit  = 93
+)
+
+// IdleExitCode is the exit status used when every goroutine is blocked
+// forever, which on a machine without interrupts can only be a deadlock.
+const IdleExitCode = 3
+
+// RV64 is the RISC-V core.
+var RV64 = &riscv64.CPU{}
+
+// ecall issues an environment call to the host.
+func ecall(num, a0, a1, a2 uint64) uint64
+
+// Exit flushes the console and terminates the program through ECALL.
+func Exit(code int32) {
+	flush()
+	ecall(SysExit, uint64(code), 0, 0)
+	// The host must not return from an exit call.
+	for {
+	}
+}
+
+// Init takes care of the lower level initialization triggered early in runtime
+// setup (post World start).
+//
+//go:linkname Init runtime.hwinit1
+func Init() {
+	// initialize CPU, which also installs the default exception handler
+	RV64.Init()
+
+	runtime.Exit = Exit
+	runtime.Idle = func(pollUntil int64) {
+		if pollUntil == math.MaxInt64 {
+			Exit(IdleExitCode)
+		}
+		// Without a timer there is nothing to wait for: jump straight to the
+		// next deadline.
+		advance(pollUntil)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build tamago
+
+// zkVM ECALL interface
+
+#include "textflag.h"
+
+// func ecall(num, a0, a1, a2 uint64) uint64
+TEXT ·ecall(SB),NOSPLIT,$0-40
+	MOV	num+0(FP), A7
+	MOV	a0+8(FP), A0
+	MOV	a1+16(FP), A1
+	MOV	a2+24(FP), A2
+	ECALL
+	MOV	A0, ret+32(FP)
+	RET
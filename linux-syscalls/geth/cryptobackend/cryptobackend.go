@@ -0,0 +1,48 @@
+// Package cryptobackend abstracts the cryptographic primitives a zkVM
+// accelerates with precompiles: keccak256, sha256, secp256k1 public key
+// recovery, the bn254 and BLS12-381 curve operations and KZG point
+// evaluation.
+//
+// Go is the default backend and computes everything in the guest, like a
+// regular build. Building with the `ecallcrypto` tag on riscv64 selects the
+// ECALL backend instead, which hands each operation to the host (rvemu or a
+// zkVM), so that accelerated and unaccelerated guests can be measured against
+// each other.
+//
+// The curve operations and KZG point evaluation take and return the byte
+// encodings of their EVM precompiles, so every backend can be checked against
+// the precompile test vectors.
+package cryptobackend
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Backend computes the accelerated primitives.
+type Backend interface {
+	// Name identifies the backend in reports.
+	Name() string
+
+	Keccak256(data []byte) common.Hash
+	Sha256(data []byte) common.Hash
+	// Ecrecover returns the 65-byte uncompressed public key that produced
+	// sig over hash. sig is [R || S || V] with V 0 or 1.
+	Ecrecover(hash, sig []byte) ([]byte, error)
+
+	Bn254Add(input []byte) ([]byte, error)
+	Bn254ScalarMul(input []byte) ([]byte, error)
+	Bn254Pairing(input []byte) ([]byte, error)
+
+	Bls12381G1Add(input []byte) ([]byte, error)
+	Bls12381G1MultiExp(input []byte) ([]byte, error)
+	Bls12381G2Add(input []byte) ([]byte, error)
+	Bls12381G2MultiExp(input []byte) ([]byte, error)
+	Bls12381Pairing(input []byte) ([]byte, error)
+	Bls12381MapG1(input []byte) ([]byte, error)
+	Bls12381MapG2(input []byte) ([]byte, error)
+
+	KZGPointEvaluation(input []byte) ([]byte, error)
+}
+
+// Default is the backend selected at build time.
+var Default Backend = Go
@@ -0,0 +1,88 @@
+package cryptobackend
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ECALL numbers of the operations, chosen above the Linux syscall numbers so
+// that a host can serve both. The arguments are the input address and length
+// in a0 and a1 and the output buffer address and size in a2 and a3. The host
+// returns the output length in a0, or -1 if the operation failed.
+//
+// Ecrecover takes the 32-byte hash followed by the 65-byte signature and
+// returns the public key. The other operations take the same input and return
+// the same output as the corresponding method of Backend.
+const (
+	ECallKeccak256 = 0x1000 + iota
+	ECallSha256
+	ECallEcrecover
+	ECallBn254Add
+	ECallBn254ScalarMul
+	ECallBn254Pairing
+	ECallBls12381G1Add
+	ECallBls12381G1MultiExp
+	ECallBls12381G2Add
+	ECallBls12381G2MultiExp
+	ECallBls12381Pairing
+	ECallBls12381MapG1
+	ECallBls12381MapG2
+	ECallKZGPointEvaluation
+)
+
+// MaxOutput is the largest output of any operation, a BLS12-381 G2 point.
+const MaxOutput = 256
+
+// ErrFailed is returned by the ECALL backend when the host reports that an
+// operation failed. The host's reason is not passed back.
+var ErrFailed = errors.New("cryptobackend: operation failed")
+
+type op struct {
+	name string
+	run  func(b Backend, input []byte) ([]byte, error)
+}
+
+var ops = map[uint64]op{
+	ECallKeccak256: {"keccak256", func(b Backend, input []byte) ([]byte, error) {
+		h := b.Keccak256(input)
+		return h[:], nil
+	}},
+	ECallSha256: {"sha256", func(b Backend, input []byte) ([]byte, error) {
+		h := b.Sha256(input)
+		return h[:], nil
+	}},
+	ECallEcrecover: {"ecrecover", func(b Backend, input []byte) ([]byte, error) {
+		if len(input) != 32+65 {
+			return nil, fmt.Errorf("ecrecover input is %d bytes, want %d", len(input), 32+65)
+		}
+		return b.Ecrecover(input[:32], input[32:])
+	}},
+	ECallBn254Add:           {"bn254_add", Backend.Bn254Add},
+	ECallBn254ScalarMul:     {"bn254_mul", Backend.Bn254ScalarMul},
+	ECallBn254Pairing:       {"bn254_pairing", Backend.Bn254Pairing},
+	ECallBls12381G1Add:      {"bls12381_g1add", Backend.Bls12381G1Add},
+	ECallBls12381G1MultiExp: {"bls12381_g1msm", Backend.Bls12381G1MultiExp},
+	ECallBls12381G2Add:      {"bls12381_g2add", Backend.Bls12381G2Add},
+	ECallBls12381G2MultiExp: {"bls12381_g2msm", Backend.Bls12381G2MultiExp},
+	ECallBls12381Pairing:    {"bls12381_pairing", Backend.Bls12381Pairing},
+	ECallBls12381MapG1:      {"bls12381_map_g1", Backend.Bls12381MapG1},
+	ECallBls12381MapG2:      {"bls12381_map_g2", Backend.Bls12381MapG2},
+	ECallKZGPointEvaluation: {"kzg_point_evaluation", Backend.KZGPointEvaluation},
+}
+
+// ECallName returns the name of an operation's ECALL number, and whether the
+// number belongs to an operation.
+func ECallName(num uint64) (string, bool) {
+	o, ok := ops[num]
+	return o.name, ok
+}
+
+// Serve performs the operation for an ECALL on the host with b, for an
+// emulator or zkVM implementing the ECALL backend's host side.
+func Serve(b Backend, num uint64, input []byte) ([]byte, error) {
+	o, ok := ops[num]
+	if !ok {
+		return nil, fmt.Errorf("unknown crypto ECALL %#x", num)
+	}
+	return o.run(b, input)
+}
@@ -0,0 +1,95 @@
+//go:build riscv64 && ecallcrypto
+
+package cryptobackend
+
+import (
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func init() {
+	Default = ECall
+}
+
+// ECall hands every operation to the host through ECALL.
+var ECall Backend = ecallBackend{}
+
+// ecall issues an operation ECALL and returns a0.
+func ecall(num uint64, in unsafe.Pointer, inLen uint64, out unsafe.Pointer, outLen uint64) int64
+
+// call runs an operation on the host.
+func call(num uint64, input []byte) ([]byte, error) {
+	var out [MaxOutput]byte
+	n := ecall(num, unsafe.Pointer(unsafe.SliceData(input)), uint64(len(input)), unsafe.Pointer(&out[0]), MaxOutput)
+	if n < 0 || n > MaxOutput {
+		return nil, ErrFailed
+	}
+	return append([]byte(nil), out[:n]...), nil
+}
+
+// hash runs a hash operation, which cannot fail.
+func hash(num uint64, data []byte) common.Hash {
+	out, err := call(num, data)
+	if err != nil || len(out) != common.HashLength {
+		panic("cryptobackend: host failed to hash")
+	}
+	return common.Hash(out)
+}
+
+type ecallBackend struct{}
+
+func (ecallBackend) Name() string { return "ecall" }
+
+func (ecallBackend) Keccak256(data []byte) common.Hash { return hash(ECallKeccak256, data) }
+
+func (ecallBackend) Sha256(data []byte) common.Hash { return hash(ECallSha256, data) }
+
+func (ecallBackend) Ecrecover(h, sig []byte) ([]byte, error) {
+	input := make([]byte, 0, len(h)+len(sig))
+	return call(ECallEcrecover, append(append(input, h...), sig...))
+}
+
+func (ecallBackend) Bn254Add(input []byte) ([]byte, error) {
+	return call(ECallBn254Add, input)
+}
+
+func (ecallBackend) Bn254ScalarMul(input []byte) ([]byte, error) {
+	return call(ECallBn254ScalarMul, input)
+}
+
+func (ecallBackend) Bn254Pairing(input []byte) ([]byte, error) {
+	return call(ECallBn254Pairing, input)
+}
+
+func (ecallBackend) Bls12381G1Add(input []byte) ([]byte, error) {
+	return call(ECallBls12381G1Add, input)
+}
+
+func (ecallBackend) Bls12381G1MultiExp(input []byte) ([]byte, error) {
+	return call(ECallBls12381G1MultiExp, input)
+}
+
+func (ecallBackend) Bls12381G2Add(input []byte) ([]byte, error) {
+	return call(ECallBls12381G2Add, input)
+}
+
+func (ecallBackend) Bls12381G2MultiExp(input []byte) ([]byte, error) {
+	return call(ECallBls12381G2MultiExp, input)
+}
+
+func (ecallBackend) Bls12381Pairing(input []byte) ([]byte, error) {
+	return call(ECallBls12381Pairing, input)
+}
+
+func (ecallBackend) Bls12381MapG1(input []byte) ([]byte, error) {
+	return call(ECallBls12381MapG1, input)
+}
+
+func (ecallBackend) Bls12381MapG2(input []byte) ([]byte, error) {
+	return call(ECallBls12381MapG2, input)
+}
+
+func (ecallBackend) KZGPointEvaluation(input []byte) ([]byte, error) {
+	return call(ECallKZGPointEvaluation, input)
+}
@@ -0,0 +1,14 @@
+//go:build ecallcrypto
+
+#include "textflag.h"
+
+// func ecall(num uint64, in unsafe.Pointer, inLen uint64, out unsafe.Pointer, outLen uint64) int64
+TEXT ·ecall(SB),NOSPLIT,$0-48
+	MOV	num+0(FP), A7
+	MOV	in+8(FP), A0
+	MOV	inLen+16(FP), A1
+	MOV	out+24(FP), A2
+	MOV	outLen+32(FP), A3
+	ECALL
+	MOV	A0, ret+40(FP)
+	RET
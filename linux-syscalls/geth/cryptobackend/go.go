@@ -0,0 +1,42 @@
+package cryptobackend
+
+import (
+	"crypto/sha256"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Go computes every primitive in the guest with the implementations geth
+// uses itself.
+var Go Backend = goBackend{}
+
+type goBackend struct{}
+
+func (goBackend) Name() string { return "go" }
+
+func (goBackend) Keccak256(data []byte) common.Hash { return crypto.Keccak256Hash(data) }
+
+func (goBackend) Sha256(data []byte) common.Hash { return sha256.Sum256(data) }
+
+func (goBackend) Ecrecover(hash, sig []byte) ([]byte, error) { return crypto.Ecrecover(hash, sig) }
+
+func (goBackend) Bn254Add(input []byte) ([]byte, error)       { return runPrecompile(0x06, input) }
+func (goBackend) Bn254ScalarMul(input []byte) ([]byte, error) { return runPrecompile(0x07, input) }
+func (goBackend) Bn254Pairing(input []byte) ([]byte, error)   { return runPrecompile(0x08, input) }
+
+func (goBackend) Bls12381G1Add(input []byte) ([]byte, error)      { return runPrecompile(0x0b, input) }
+func (goBackend) Bls12381G1MultiExp(input []byte) ([]byte, error) { return runPrecompile(0x0c, input) }
+func (goBackend) Bls12381G2Add(input []byte) ([]byte, error)      { return runPrecompile(0x0d, input) }
+func (goBackend) Bls12381G2MultiExp(input []byte) ([]byte, error) { return runPrecompile(0x0e, input) }
+func (goBackend) Bls12381Pairing(input []byte) ([]byte, error)    { return runPrecompile(0x0f, input) }
+func (goBackend) Bls12381MapG1(input []byte) ([]byte, error)      { return runPrecompile(0x10, input) }
+func (goBackend) Bls12381MapG2(input []byte) ([]byte, error)      { return runPrecompile(0x11, input) }
+
+func (goBackend) KZGPointEvaluation(input []byte) ([]byte, error) { return runPrecompile(0x0a, input) }
+
+// runPrecompile runs geth's own implementation of a precompile.
+func runPrecompile(addr byte, input []byte) ([]byte, error) {
+	return vm.PrecompiledContractsPrague[common.BytesToAddress([]byte{addr})].Run(input)
+}
@@ -0,0 +1,90 @@
+package cryptobackend
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer wraps s so that transaction senders are recovered on b. Signature
+// hashes, type support and chain ID checks are left to s, and V and s values
+// are checked by the rules of s, as geth's signers do.
+func Signer(s types.Signer, b Backend) types.Signer {
+	return &signer{Signer: s, backend: b}
+}
+
+type signer struct {
+	types.Signer
+	backend Backend
+}
+
+var zeroSig = make([]byte, crypto.SignatureLength)
+
+// Sender recovers the sender of tx, normalising V the way geth's signers do.
+func (s *signer) Sender(tx *types.Transaction) (common.Address, error) {
+	// SignatureValues fails for unsupported types and wrong chain IDs, and
+	// costs no curve operation.
+	if _, _, _, err := s.Signer.SignatureValues(tx, zeroSig); err != nil {
+		return common.Address{}, err
+	}
+	var (
+		V, R, S   = tx.RawSignatureValues()
+		homestead = true
+		hasher    = s.Signer
+	)
+	switch {
+	case s.ChainID() == nil:
+		// Frontier and Homestead signers predate EIP-155 and take V as it
+		// is, so a protected transaction fails recovery. Only Frontier
+		// allows s values in the upper half of the curve order.
+		_, frontier := s.Signer.(types.FrontierSigner)
+		homestead = !frontier
+	case tx.Type() != types.LegacyTxType:
+		if tx.ChainId().Cmp(s.ChainID()) != 0 {
+			return common.Address{}, fmt.Errorf("%w: have %d want %d", types.ErrInvalidChainId, tx.ChainId(), s.ChainID())
+		}
+		// Typed transactions use 0 and 1 as their recovery id.
+		V = new(big.Int).Add(V, big.NewInt(27))
+	case tx.Protected():
+		if tx.ChainId().Cmp(s.ChainID()) != 0 {
+			return common.Address{}, fmt.Errorf("%w: have %d want %d", types.ErrInvalidChainId, tx.ChainId(), s.ChainID())
+		}
+		// EIP-155: V = recovery id + chain ID * 2 + 35.
+		V = new(big.Int).Sub(V, new(big.Int).Lsh(s.ChainID(), 1))
+		V.Sub(V, big.NewInt(8))
+	default:
+		// Unprotected legacy transactions are signed without the chain ID.
+		hasher = types.HomesteadSigner{}
+	}
+	if V.BitLen() > 8 {
+		return common.Address{}, types.ErrInvalidSig
+	}
+	v := byte(V.Uint64() - 27)
+	if !crypto.ValidateSignatureValues(v, R, S, homestead) {
+		return common.Address{}, types.ErrInvalidSig
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	R.FillBytes(sig[:32])
+	S.FillBytes(sig[32:64])
+	sig[64] = v
+
+	sighash := hasher.Hash(tx)
+	pub, err := s.backend.Ecrecover(sighash[:], sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(pub) != 65 || pub[0] != 4 {
+		return common.Address{}, errors.New("invalid public key")
+	}
+	h := s.backend.Keccak256(pub[1:])
+	return common.BytesToAddress(h[12:]), nil
+}
+
+func (s *signer) Equal(other types.Signer) bool {
+	o, ok := other.(*signer)
+	return ok && o.backend == s.backend && s.Signer.Equal(o.Signer)
+}
@@ -0,0 +1,77 @@
+package cryptobackend
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var secp256k1N, _ = new(big.Int).SetString("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", 16)
+
+// highS returns tx with its s value mirrored into the upper half of the curve
+// order, which recovers the same sender but breaks the Homestead rule.
+func highS(t *testing.T, tx *types.Transaction) *types.Transaction {
+	v, r, s := tx.RawSignatureValues()
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	new(big.Int).Sub(secp256k1N, s).FillBytes(sig[32:64])
+	sig[64] = byte(1 - (v.Uint64()-27)%2)
+	tx, err := tx.WithSignature(types.HomesteadSigner{}, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestSignerMatchesGeth(t *testing.T) {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	chainID := big.NewInt(1)
+	to := common.HexToAddress("0x1000")
+	sign := func(s types.Signer, data types.TxData) *types.Transaction {
+		tx, err := types.SignNewTx(key, s, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	legacy := &types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(10), Gas: 21000, To: &to, Value: big.NewInt(1)}
+	unprotected := sign(types.HomesteadSigner{}, legacy)
+	txs := map[string]*types.Transaction{
+		"unprotected":        unprotected,
+		"unprotected high-s": highS(t, unprotected),
+		"protected":          sign(types.NewEIP155Signer(chainID), legacy),
+		"protected chain 5":  sign(types.NewEIP155Signer(big.NewInt(5)), legacy),
+		"access list":        sign(types.NewEIP2930Signer(chainID), &types.AccessListTx{ChainID: chainID, Nonce: 2, GasPrice: big.NewInt(10), Gas: 21000, To: &to}),
+		"dynamic fee":        sign(types.NewLondonSigner(chainID), &types.DynamicFeeTx{ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(10), Gas: 21000, To: &to}),
+	}
+	signers := map[string]types.Signer{
+		"frontier":  types.FrontierSigner{},
+		"homestead": types.HomesteadSigner{},
+		"eip155":    types.NewEIP155Signer(chainID),
+		"london":    types.NewLondonSigner(chainID),
+	}
+	for sname, s := range signers {
+		wrapped := Signer(s, Go)
+		for tname, tx := range txs {
+			want, wantErr := s.Sender(tx)
+			got, err := wrapped.Sender(tx)
+			if (err != nil) != (wantErr != nil) || got != want {
+				t.Errorf("%s signer, %s tx: got %s, %v, want %s, %v", sname, tname, got, err, want, wantErr)
+			}
+		}
+	}
+	// The matrix must cover both outcomes of the rules that differ between
+	// signers.
+	if _, err := Signer(types.FrontierSigner{}, Go).Sender(txs["unprotected high-s"]); err != nil {
+		t.Errorf("frontier rejects a high-s signature: %v", err)
+	}
+	if _, err := Signer(types.HomesteadSigner{}, Go).Sender(txs["unprotected high-s"]); err == nil {
+		t.Error("homestead accepts a high-s signature")
+	}
+	if _, err := Signer(types.HomesteadSigner{}, Go).Sender(txs["protected"]); err == nil {
+		t.Error("homestead accepts a protected transaction")
+	}
+}
@@ -0,0 +1,53 @@
+// Package deterministic holds the virtual time and randomness shared by the
+// two environments that run the guest reproducibly: the zkvm tamago board and
+// the linuxabi kernel behind rvemu. With the same seed and time step, both
+// hand the guest the same random bytes and clock readings, so a run depends
+// only on its input.
+//
+// The package is used from the tamago runtime hooks, before the heap is set
+// up, so it must not allocate and its variables must be statically
+// initialised.
+package deterministic
+
+import (
+	"encoding/binary"
+	"math/rand/v2"
+)
+
+const (
+	// DefaultTimeStep is how far, in nanoseconds, the clock advances every
+	// time it is read.
+	DefaultTimeStep = 1000
+	// DefaultEpoch is the wall clock time at boot, 2025-01-01T00:00:00Z, in
+	// nanoseconds since the Unix epoch.
+	DefaultEpoch = 1735689600 * 1e9
+)
+
+// DefaultSeed seeds the random source unless a run picks another seed.
+var DefaultSeed = [32]byte{'z', 'k', 'v', 'm'}
+
+// Random is a seeded random byte source. The zero value must be seeded before
+// use.
+type Random struct {
+	rng rand.ChaCha8
+}
+
+// Seed resets r to the start of the stream for seed.
+func (r *Random) Seed(seed [32]byte) {
+	r.rng.Seed(seed)
+}
+
+// Read fills b with the next bytes of the stream. Every call consumes whole
+// 8-byte words, so the bytes a guest sees depend only on the sizes it asks
+// for.
+func (r *Random) Read(b []byte) {
+	for len(b) >= 8 {
+		binary.LittleEndian.PutUint64(b, r.rng.Uint64())
+		b = b[8:]
+	}
+	if len(b) > 0 {
+		var last [8]byte
+		binary.LittleEndian.PutUint64(last[:], r.rng.Uint64())
+		copy(b, last[:])
+	}
+}
@@ -0,0 +1,125 @@
+package elfsize
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Delta is the change of a package or symbol between two binaries. A and B
+// are the sizes in each binary; an entry missing from one has zero sizes in
+// it.
+type Delta struct {
+	Name string `json:"name"`
+	A    Sizes  `json:"a"`
+	B    Sizes  `json:"b"`
+}
+
+// Bytes is the change of the bytes in the file, b - a.
+func (d Delta) Bytes() int64 { return int64(d.B.File()) - int64(d.A.File()) }
+
+// Diff compares two binaries.
+type Diff struct {
+	A        string  `json:"a"`
+	B        string  `json:"b"`
+	Total    Delta   `json:"total"`
+	Packages []Delta `json:"packages"`
+	Symbols  []Delta `json:"symbols"`
+}
+
+// Compare lists the packages and symbols whose size differs between a and b,
+// largest change first.
+func Compare(a, b *Report) *Diff {
+	d := &Diff{A: a.Path, B: b.Path, Total: Delta{Name: "total", A: a.Sections, B: b.Sections}}
+
+	pkgs := make(map[string]*Delta)
+	for _, p := range a.Packages {
+		pkgs[p.Name] = &Delta{Name: p.Name, A: p.Sizes}
+	}
+	for _, p := range b.Packages {
+		if pkgs[p.Name] == nil {
+			pkgs[p.Name] = &Delta{Name: p.Name}
+		}
+		pkgs[p.Name].B = p.Sizes
+	}
+	d.Packages = changed(pkgs)
+
+	syms := make(map[string]*Delta)
+	for i, r := range []*Report{a, b} {
+		for _, s := range r.Symbols {
+			if syms[s.Name] == nil {
+				syms[s.Name] = &Delta{Name: s.Name}
+			}
+			sizes := &syms[s.Name].A
+			if i == 1 {
+				sizes = &syms[s.Name].B
+			}
+			sizes[s.Kind] += s.Size
+		}
+	}
+	d.Symbols = changed(syms)
+	return d
+}
+
+func changed(m map[string]*Delta) []Delta {
+	var out []Delta
+	for _, d := range m {
+		if d.A != d.B {
+			out = append(out, *d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := abs(out[i].Bytes()), abs(out[j].Bytes())
+		if a != b {
+			return a > b
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// String renders the diff of every package.
+func (d *Diff) String() string {
+	return d.Format(0, 0)
+}
+
+// Format renders the total, the packages and the symbols with the largest
+// changes. A count <= 0 lists every package and no symbols.
+func (d *Diff) Format(packages, symbols int) string {
+	var sb strings.Builder
+	section := func(title, column string, deltas []Delta, n int) {
+		total := len(deltas)
+		if n > 0 && n < len(deltas) {
+			deltas = deltas[:n]
+		}
+		fmt.Fprintf(&sb, "%s (%d of %d)\n", title, len(deltas), total)
+		if len(deltas) == 0 {
+			sb.WriteString("  -\n")
+			return
+		}
+		fmt.Fprintf(&sb, "  %10s %10s %10s  %s\n", "A", "B", "DELTA", column)
+		for _, delta := range deltas {
+			fmt.Fprintf(&sb, "  %10d %10d %+10d  %s\n", delta.A.File(), delta.B.File(), delta.Bytes(), delta.Name)
+		}
+	}
+	fmt.Fprintf(&sb, "A: %s\nB: %s\n\n", d.A, d.B)
+	fmt.Fprintf(&sb, "  %-6s %10s %10s %10s\n", "KIND", "A", "B", "DELTA")
+	for k := Kind(0); k < numKinds; k++ {
+		a, b := d.Total.A[k], d.Total.B[k]
+		fmt.Fprintf(&sb, "  %-6s %10d %10d %+10d\n", k, a, b, int64(b)-int64(a))
+	}
+	sb.WriteString("\n")
+	section("Packages", "PACKAGE", d.Packages, packages)
+	if symbols > 0 {
+		sb.WriteString("\n")
+		section("Symbols", "SYMBOL", d.Symbols, symbols)
+	}
+	return sb.String()
+}
@@ -0,0 +1,344 @@
+// Package elfsize breaks the size of a Go ELF binary down by package and by
+// symbol, to find the imports that make a guest binary large.
+//
+// Every allocated section is counted as text, rodata, data or bss from its
+// flags. Symbols are attributed to the package in their name; symbols whose
+// name has no package, such as assembly entry points, fall back to the DWARF
+// compile unit that covers their address.
+//
+// The Go linker does not symbolise strings, embedded files, type descriptors
+// or the pcln table. The pcln table is split by the functions it describes,
+// and strings and embedded files by the package variables, found in DWARF,
+// whose string or slice header points at them. What is left, such as string
+// literals only code refers to, is reported under the name of its section:
+// ".rodata", ".go.type" and ".gopclntab".
+package elfsize
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Kind is the class of a section.
+type Kind int
+
+const (
+	Text Kind = iota
+	Rodata
+	Data
+	Bss
+	numKinds
+)
+
+var kindNames = [numKinds]string{"text", "rodata", "data", "bss"}
+
+func (k Kind) String() string { return kindNames[k] }
+
+func (k Kind) MarshalText() ([]byte, error) { return []byte(k.String()), nil }
+
+// Sizes holds a size per Kind.
+type Sizes [numKinds]uint64
+
+// File is the number of bytes that take up space in the file, which is every
+// kind but bss.
+func (s Sizes) File() uint64 { return s[Text] + s[Rodata] + s[Data] }
+
+func (s Sizes) MarshalJSON() ([]byte, error) {
+	m := make(map[string]uint64, numKinds)
+	for k, n := range s {
+		m[Kind(k).String()] = n
+	}
+	return json.Marshal(m)
+}
+
+// Package is the size of one package.
+type Package struct {
+	Name  string `json:"name"`
+	Sizes Sizes  `json:"sizes"`
+}
+
+// Symbol is the size of one symbol.
+type Symbol struct {
+	Name    string `json:"name"`
+	Package string `json:"package"`
+	Kind    Kind   `json:"kind"`
+	Size    uint64 `json:"size"`
+}
+
+// Report is the size breakdown of one binary.
+type Report struct {
+	Path     string    `json:"path"`
+	FileSize uint64    `json:"fileSize"`
+	Sections Sizes     `json:"sections"`
+	Debug    uint64    `json:"debug"` // bytes of .debug_* sections
+	Packages []Package `json:"packages"`
+	Symbols  []Symbol  `json:"symbols"`
+}
+
+// Load analyses the ELF file at path.
+func Load(path string) (*Report, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{Path: path, FileSize: uint64(info.Size())}
+
+	kinds := make(map[elf.SectionIndex]Kind)
+	for i, s := range f.Sections {
+		if strings.HasPrefix(s.Name, ".debug_") || strings.HasPrefix(s.Name, ".zdebug_") {
+			r.Debug += s.FileSize
+		}
+		k, ok := sectionKind(s)
+		if !ok {
+			continue
+		}
+		kinds[elf.SectionIndex(i)] = k
+		r.Sections[k] += s.Size
+	}
+
+	syms, err := f.Symbols()
+	if err != nil && err != elf.ErrNoSymbols {
+		return nil, err
+	}
+	units, vars := readDWARF(f)
+	covered := make(map[elf.SectionIndex]uint64)
+	packages := make(map[string]*Sizes)
+	add := func(pkg string, k Kind, size uint64) {
+		if packages[pkg] == nil {
+			packages[pkg] = new(Sizes)
+		}
+		packages[pkg][k] += size
+	}
+	var gofunc elf.Symbol
+	for _, s := range syms {
+		k, ok := kinds[s.Section]
+		if !ok || s.Size == 0 || elf.ST_TYPE(s.Info) == elf.STT_SECTION {
+			continue
+		}
+		// The funcdata area is split with the pcln table.
+		if s.Name == "go:func.*" {
+			gofunc = s
+			continue
+		}
+		pkg := PackageOf(s.Name)
+		if pkg == "" {
+			pkg = units.lookup(s.Value)
+		}
+		if pkg == "" {
+			pkg = f.Sections[s.Section].Name
+		}
+		r.Symbols = append(r.Symbols, Symbol{Name: s.Name, Package: pkg, Kind: k, Size: s.Size})
+		add(pkg, k, s.Size)
+		covered[s.Section] += s.Size
+	}
+	// Data reached from a variable is listed as one symbol per variable.
+	data := make(map[string]*Symbol)
+	for _, d := range varData(f, kinds, vars) {
+		if data[d.name] == nil {
+			data[d.name] = &Symbol{Name: "data of " + d.name, Package: d.pkg, Kind: Rodata}
+		}
+		data[d.name].Size += d.size
+		add(d.pkg, Rodata, d.size)
+		covered[d.section] += d.size
+	}
+	for _, s := range data {
+		r.Symbols = append(r.Symbols, *s)
+	}
+	if s := f.Section(".gopclntab"); s != nil && s.Type != elf.SHT_NOBITS {
+		if data, err := s.Data(); err == nil {
+			text := uint64(0)
+			if t := f.Section(".text"); t != nil {
+				text = t.Addr
+			}
+			i := sectionIndex(f, s)
+			var funcdata []byte
+			if gofunc.Section == i && gofunc.Value >= s.Addr && gofunc.Value+gofunc.Size <= s.Addr+uint64(len(data)) {
+				funcdata = data[gofunc.Value-s.Addr : gofunc.Value-s.Addr+gofunc.Size]
+			}
+			for pkg, size := range pclnSizes(data, f.ByteOrder, text, units, funcdata) {
+				add(pkg, kinds[i], size)
+				covered[i] += size
+			}
+		}
+	}
+	for i, k := range kinds {
+		s := f.Sections[i]
+		if s.Size > covered[i] {
+			add(s.Name, k, s.Size-covered[i])
+		}
+	}
+
+	for name, sizes := range packages {
+		r.Packages = append(r.Packages, Package{Name: name, Sizes: *sizes})
+	}
+	sort.Slice(r.Packages, func(i, j int) bool {
+		a, b := r.Packages[i].Sizes, r.Packages[j].Sizes
+		if a.File() != b.File() {
+			return a.File() > b.File()
+		}
+		return r.Packages[i].Name < r.Packages[j].Name
+	})
+	sort.Slice(r.Symbols, func(i, j int) bool {
+		if r.Symbols[i].Size != r.Symbols[j].Size {
+			return r.Symbols[i].Size > r.Symbols[j].Size
+		}
+		return r.Symbols[i].Name < r.Symbols[j].Name
+	})
+	return r, nil
+}
+
+func sectionKind(s *elf.Section) (Kind, bool) {
+	switch {
+	case s.Flags&elf.SHF_ALLOC == 0 || s.Size == 0:
+		return 0, false
+	case s.Type == elf.SHT_NOBITS:
+		return Bss, true
+	case s.Flags&elf.SHF_EXECINSTR != 0:
+		return Text, true
+	case s.Flags&elf.SHF_WRITE != 0:
+		return Data, true
+	case s.Type == elf.SHT_NOTE:
+		return 0, false
+	default:
+		return Rodata, true
+	}
+}
+
+// PackageOf returns the package of a Go symbol name, or "" if the name has
+// none. Generic instantiations belong to the package that defines them, and
+// method values and closures to the package of their function.
+//
+// The linker escapes the dots of the last path element, as in
+// "gopkg.in/yaml%2ev3.Marshal"; the package is returned unescaped, as DWARF
+// names it.
+func PackageOf(sym string) string {
+	// Linker-made symbols, and the constants the compiler pools as
+	// "$f64.<bits>", have no package.
+	if strings.HasPrefix(sym, "go:") || strings.HasPrefix(sym, "type:") || strings.HasPrefix(sym, "$") {
+		return ""
+	}
+	// Type arguments can contain slashes and dots of their own packages.
+	if i := strings.IndexByte(sym, '['); i >= 0 {
+		sym = sym[:i]
+	}
+	slash := strings.LastIndexByte(sym, '/')
+	dot := strings.IndexByte(sym[slash+1:], '.')
+	if dot <= 0 {
+		return ""
+	}
+	return strings.ReplaceAll(sym[:slash+1+dot], "%2e", ".")
+}
+
+func sectionIndex(f *elf.File, s *elf.Section) elf.SectionIndex {
+	for i, t := range f.Sections {
+		if t == s {
+			return elf.SectionIndex(i)
+		}
+	}
+	return 0
+}
+
+// unitRanges maps address ranges to the DWARF compile units, which are Go
+// packages, that contain them.
+type unitRanges []unitRange
+
+type unitRange struct {
+	low, high uint64
+	name      string
+}
+
+// readDWARF reads the compile unit ranges of f and its package variables of
+// string, slice or embed.FS type, or returns nil if f has no DWARF.
+func readDWARF(f *elf.File) (unitRanges, []dwarfVar) {
+	d, err := f.DWARF()
+	if err != nil {
+		return nil, nil
+	}
+	var (
+		units unitRanges
+		vars  []dwarfVar
+	)
+	rd := d.Reader()
+	for {
+		e, err := rd.Next()
+		if err != nil || e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			// Read the unit's children for its variables.
+			name, _ := e.Val(dwarf.AttrName).(string)
+			ranges, err := d.Ranges(e)
+			if err == nil {
+				for _, r := range ranges {
+					units = append(units, unitRange{r[0], r[1], name})
+				}
+			}
+			continue
+		case dwarf.TagVariable:
+			if v, ok := staticVar(d, e, f.ByteOrder); ok {
+				vars = append(vars, v)
+			}
+		}
+		if e.Children {
+			rd.SkipChildren()
+		}
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].low < units[j].low })
+	return units, vars
+}
+
+func (u unitRanges) lookup(addr uint64) string {
+	i := sort.Search(len(u), func(i int) bool { return u[i].low > addr })
+	if i > 0 && addr < u[i-1].high {
+		return u[i-1].name
+	}
+	return ""
+}
+
+// String renders the section totals and every package.
+func (r *Report) String() string {
+	return r.Format(0, 0)
+}
+
+// Format renders the section totals, the largest packages and the largest
+// symbols. A count <= 0 lists every package and no symbols.
+func (r *Report) Format(packages, symbols int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Binary: %s (%d bytes, %d of DWARF)\n", r.Path, r.FileSize, r.Debug)
+	fmt.Fprintf(&sb, "  %10s %10s %10s %10s\n", "TEXT", "RODATA", "DATA", "BSS")
+	fmt.Fprintf(&sb, "  %10d %10d %10d %10d\n\n", r.Sections[Text], r.Sections[Rodata], r.Sections[Data], r.Sections[Bss])
+
+	list := r.Packages
+	if packages > 0 && packages < len(list) {
+		list = list[:packages]
+	}
+	fmt.Fprintf(&sb, "Packages (%d of %d)\n", len(list), len(r.Packages))
+	fmt.Fprintf(&sb, "  %10s %10s %10s %10s  %s\n", "TEXT", "RODATA", "DATA", "BSS", "PACKAGE")
+	for _, p := range list {
+		fmt.Fprintf(&sb, "  %10d %10d %10d %10d  %s\n", p.Sizes[Text], p.Sizes[Rodata], p.Sizes[Data], p.Sizes[Bss], p.Name)
+	}
+
+	if symbols > 0 {
+		syms := r.Symbols
+		if symbols < len(syms) {
+			syms = syms[:symbols]
+		}
+		fmt.Fprintf(&sb, "\nSymbols (%d of %d)\n", len(syms), len(r.Symbols))
+		fmt.Fprintf(&sb, "  %10s %-6s  %s\n", "SIZE", "KIND", "SYMBOL")
+		for _, s := range syms {
+			fmt.Fprintf(&sb, "  %10d %-6s  %s\n", s.Size, s.Kind, s.Name)
+		}
+	}
+	return sb.String()
+}
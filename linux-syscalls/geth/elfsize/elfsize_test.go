@@ -0,0 +1,31 @@
+package elfsize
+
+import "testing"
+
+func TestPackageOf(t *testing.T) {
+	tests := map[string]string{
+		"runtime.main":      "runtime",
+		"main.main.func1":   "main",
+		"runtime.gcbits.*":  "runtime",
+		"crypto/sha256.New": "crypto/sha256",
+		"github.com/ethereum/go-ethereum/core/vm.(*EVM).Call":          "github.com/ethereum/go-ethereum/core/vm",
+		"github.com/ethereum/go-ethereum/core/vm.(*EVM).Call-fm":       "github.com/ethereum/go-ethereum/core/vm",
+		"slices.SortFunc[go.shape.[]uint8]":                            "slices",
+		"github.com/a/b.Map[github.com/c/d.T,go.shape.string]":         "github.com/a/b",
+		"internal/strconv..dict.shortFloat[float64]":                   "internal/strconv",
+		"gopkg.in/yaml%2ev3.Marshal":                                   "gopkg.in/yaml.v3",
+		"github.com/ethereum/go-ethereum/crypto/kzg4844.content.files": "github.com/ethereum/go-ethereum/crypto/kzg4844",
+		"go:string.*":           "",
+		"go:func.*":             "",
+		"type:*":                "",
+		"type:.eq.[2]string":    "",
+		"_rt0_riscv64_linux":    "",
+		"$f64.3ff0000000000000": "",
+		"":                      "",
+	}
+	for sym, want := range tests {
+		if got := PackageOf(sym); got != want {
+			t.Errorf("PackageOf(%q) = %q, want %q", sym, got, want)
+		}
+	}
+}
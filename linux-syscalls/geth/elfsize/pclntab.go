@@ -0,0 +1,184 @@
+package elfsize
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// go120PCLnTabMagic starts the pcln table of Go 1.20 and later.
+const go120PCLnTabMagic = 0xfffffff1
+
+// _func record layout, see runtime._func.
+const (
+	funcNameOff   = 4
+	funcPCSP      = 16
+	funcPCFile    = 20
+	funcPCLn      = 24
+	funcNPCData   = 28
+	funcCUOffset  = 32
+	funcNFuncData = 43
+	funcSize      = 44
+)
+
+// pclnSizes attributes the bytes of the pcln table in data to the packages of
+// the functions they describe: each function's function table entry, its
+// _func record, its name, the pc-value tables and funcdata it uses, and the
+// file list of its compile unit. Tables and funcdata shared by several
+// functions go to the first. gofunc is the funcdata area, go:func.*, and
+// funcdata runs up to the next funcdata in it. text is the address entry
+// offsets count from, and units place the functions whose name has no
+// package. Nothing is attributed if the table is not in a format this reads.
+func pclnSizes(data []byte, order binary.ByteOrder, text uint64, units unitRanges, gofunc []byte) map[string]uint64 {
+	if len(data) < 8 || order.Uint32(data) != go120PCLnTabMagic {
+		return nil
+	}
+	ptrSize := int(data[7])
+	if ptrSize != 4 && ptrSize != 8 || len(data) < 8+8*ptrSize {
+		return nil
+	}
+	word := func(i int) uint64 {
+		if ptrSize == 8 {
+			return order.Uint64(data[8+8*i:])
+		}
+		return uint64(order.Uint32(data[8+4*i:]))
+	}
+	var (
+		nfunc    = word(0)
+		funcname = word(3)
+		cutab    = word(4)
+		filetab  = word(5)
+		pctab    = word(6)
+		pcln     = word(7)
+	)
+	if funcname > cutab || cutab > filetab || filetab > pctab || pctab > pcln || pcln > uint64(len(data)) ||
+		nfunc > (uint64(len(data))-pcln)/8 {
+		return nil
+	}
+	var (
+		names  = data[funcname:cutab]
+		cus    = data[cutab:filetab]
+		files  = data[filetab:pctab]
+		tables = data[pctab:pcln]
+		funcs  = data[pcln:]
+
+		sizes     = make(map[string]uint64)
+		counted   = make(map[uint32]bool)
+		cuPkgs    = make(map[uint32]string)
+		funcdata  = make(map[uint32]string)
+		fileNames = make(map[uint32]bool)
+	)
+	table := func(off uint32) uint64 {
+		if off == 0 || counted[off] || uint64(off) >= uint64(len(tables)) {
+			return 0
+		}
+		counted[off] = true
+		return uint64(pcTableLen(tables[off:]))
+	}
+	for i := uint64(0); i < nfunc; i++ {
+		entry, off := order.Uint32(funcs[8*i:]), order.Uint32(funcs[8*i+4:])
+		if uint64(off)+funcSize > uint64(len(funcs)) {
+			continue
+		}
+		f := funcs[off:]
+		npcdata, nfuncdata := uint64(order.Uint32(f[funcNPCData:])), uint64(f[funcNFuncData])
+		record := funcSize + 4*(npcdata+nfuncdata)
+		if uint64(off)+record > uint64(len(funcs)) {
+			continue
+		}
+		var name string
+		if nameOff := uint64(order.Uint32(f[funcNameOff:])); nameOff < uint64(len(names)) {
+			name = cstring(names[nameOff:])
+		}
+		pkg := PackageOf(name)
+		if pkg == "" {
+			pkg = units.lookup(text + uint64(entry))
+		}
+		if pkg == "" {
+			continue
+		}
+		size := 8 + record + uint64(len(name)) + 1
+		for _, field := range []int{funcPCSP, funcPCFile, funcPCLn} {
+			size += table(order.Uint32(f[field:]))
+		}
+		for j := uint64(0); j < npcdata; j++ {
+			size += table(order.Uint32(f[funcSize+4*j:]))
+		}
+		for j := uint64(0); j < nfuncdata; j++ {
+			if fd := order.Uint32(f[funcSize+4*(npcdata+j):]); fd != ^uint32(0) {
+				if _, ok := funcdata[fd]; !ok {
+					funcdata[fd] = pkg
+				}
+			}
+		}
+		if cu := order.Uint32(f[funcCUOffset:]); cuPkgs[cu] == "" {
+			cuPkgs[cu] = pkg
+		}
+		sizes[pkg] += size
+	}
+
+	// A compile unit's files run up to the next unit's.
+	cuOffs := sortedKeys(cuPkgs)
+	for i, cu := range cuOffs {
+		end := uint64(len(cus)) / 4
+		if i+1 < len(cuOffs) {
+			end = uint64(cuOffs[i+1])
+		}
+		for j := uint64(cu); j < end && 4*j+4 <= uint64(len(cus)); j++ {
+			sizes[cuPkgs[cu]] += 4
+			if file := order.Uint32(cus[4*j:]); file != ^uint32(0) && !fileNames[file] && uint64(file) < uint64(len(files)) {
+				fileNames[file] = true
+				sizes[cuPkgs[cu]] += uint64(len(cstring(files[file:]))) + 1
+			}
+		}
+	}
+	fdOffs := sortedKeys(funcdata)
+	for i, fd := range fdOffs {
+		end := uint64(len(gofunc))
+		if i+1 < len(fdOffs) {
+			end = uint64(fdOffs[i+1])
+		}
+		if uint64(fd) < end {
+			sizes[funcdata[fd]] += end - uint64(fd)
+		}
+	}
+	return sizes
+}
+
+func sortedKeys(m map[uint32]string) []uint32 {
+	keys := make([]uint32, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// pcTableLen returns the length of the pc-value table at the start of p: pairs
+// of a value delta and a pc delta, ended by a zero value delta after the
+// first pair.
+func pcTableLen(p []byte) int {
+	for n, first := 0, true; ; first = false {
+		delta, k := binary.Uvarint(p[n:])
+		if k <= 0 {
+			return n
+		}
+		n += k
+		if delta == 0 && !first {
+			return n
+		}
+		if _, k = binary.Uvarint(p[n:]); k <= 0 {
+			return n
+		}
+		n += k
+	}
+}
+
+// cstring returns the NUL-terminated string at the start of b.
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
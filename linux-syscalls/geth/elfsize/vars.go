@@ -0,0 +1,199 @@
+package elfsize
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// varKind is the type of a package variable whose data the linker leaves
+// unsymbolised.
+type varKind int
+
+const (
+	varString varKind = iota
+	varSlice
+	varEmbedFS
+)
+
+// dwarfVar is a package variable at a static address.
+type dwarfVar struct {
+	name string
+	addr uint64
+	kind varKind
+	elem uint64 // element size of a slice
+}
+
+// staticVar returns the variable e describes if it is a string, a slice or
+// an embed.FS at a static address.
+func staticVar(d *dwarf.Data, e *dwarf.Entry, order binary.ByteOrder) (dwarfVar, bool) {
+	name, _ := e.Val(dwarf.AttrName).(string)
+	loc, _ := e.Val(dwarf.AttrLocation).([]byte)
+	off, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
+	if name == "" || !ok || len(loc) < 1 || loc[0] != 0x03 { // DW_OP_addr
+		return dwarfVar{}, false
+	}
+	v := dwarfVar{name: name}
+	switch len(loc) {
+	case 1 + 8:
+		v.addr = order.Uint64(loc[1:])
+	case 1 + 4:
+		v.addr = uint64(order.Uint32(loc[1:]))
+	default:
+		return dwarfVar{}, false
+	}
+	t, err := d.Type(off)
+	if err != nil {
+		return dwarfVar{}, false
+	}
+	// Named types are typedefs of their underlying type.
+	for {
+		td, ok := t.(*dwarf.TypedefType)
+		if !ok {
+			break
+		}
+		if td.Name == "embed.FS" {
+			v.kind = varEmbedFS
+			return v, true
+		}
+		t = td.Type
+	}
+	st, ok := t.(*dwarf.StructType)
+	switch {
+	case !ok:
+		return dwarfVar{}, false
+	case st.StructName == "string":
+		v.kind = varString
+	case strings.HasPrefix(st.StructName, "[]") && len(st.Field) > 0:
+		ptr, ok := st.Field[0].Type.(*dwarf.PtrType)
+		if !ok || ptr.Type.Size() <= 0 {
+			return dwarfVar{}, false
+		}
+		v.kind, v.elem = varSlice, uint64(ptr.Type.Size())
+	default:
+		return dwarfVar{}, false
+	}
+	return v, true
+}
+
+// dataRange is the part of a read-only section that a variable points at and
+// no other variable already covers.
+type dataRange struct {
+	name, pkg  string
+	section    elf.SectionIndex
+	start, end uint64
+	size       uint64
+}
+
+// varData returns the bytes of read-only sections that the variables point
+// at: the bytes of a string, the elements of a slice, and the file list,
+// names and contents of an embed.FS. A byte several variables point at, as
+// when one string is a prefix of another, goes to the variable pointing at
+// the lowest address, then the first by name.
+func varData(f *elf.File, kinds map[elf.SectionIndex]Kind, vars []dwarfVar) []dataRange {
+	ptrSize := uint64(8)
+	if f.Class == elf.ELFCLASS32 {
+		ptrSize = 4
+	}
+	contents := make(map[*elf.Section][]byte)
+	word := func(addr uint64) (uint64, bool) {
+		for _, s := range f.Sections {
+			if s.Type == elf.SHT_NOBITS || s.Flags&elf.SHF_ALLOC == 0 || addr < s.Addr || addr+ptrSize > s.Addr+s.Size {
+				continue
+			}
+			if contents[s] == nil {
+				data, err := s.Data()
+				if err != nil {
+					return 0, false
+				}
+				contents[s] = data
+			}
+			b := contents[s][addr-s.Addr:]
+			if ptrSize == 4 {
+				return uint64(f.ByteOrder.Uint32(b)), true
+			}
+			return f.ByteOrder.Uint64(b), true
+		}
+		return 0, false
+	}
+	// header reads the pointer and length of the string or slice at addr.
+	header := func(addr uint64) (ptr, n uint64) {
+		ptr, ok := word(addr)
+		if !ok {
+			return 0, 0
+		}
+		n, ok = word(addr + ptrSize)
+		if !ok {
+			return 0, 0
+		}
+		return ptr, n
+	}
+	var ranges []dataRange
+	add := func(v dwarfVar, start, n uint64) {
+		if start == 0 || n == 0 {
+			return
+		}
+		for i, s := range f.Sections {
+			if start >= s.Addr && start < s.Addr+s.Size {
+				if kinds[elf.SectionIndex(i)] == Rodata && s.Type != elf.SHT_NOBITS && n <= s.Addr+s.Size-start {
+					ranges = append(ranges, dataRange{name: v.name, pkg: PackageOf(v.name), section: elf.SectionIndex(i), start: start, end: start + n})
+				}
+				return
+			}
+		}
+	}
+	for _, v := range vars {
+		switch v.kind {
+		case varString:
+			ptr, n := header(v.addr)
+			add(v, ptr, n)
+		case varSlice:
+			ptr, n := header(v.addr)
+			if v.elem != 0 && n <= ^uint64(0)/v.elem {
+				add(v, ptr, n*v.elem)
+			}
+		case varEmbedFS:
+			// An embed.FS points at a slice of files, each a name, the
+			// contents and a 16-byte hash.
+			files, ok := word(v.addr)
+			if !ok || files == 0 {
+				continue
+			}
+			add(v, files, 3*ptrSize)
+			ptr, n := header(files)
+			size := 4*ptrSize + 16
+			if n > ^uint64(0)/size {
+				continue
+			}
+			add(v, ptr, n*size)
+			for i := uint64(0); i < n; i++ {
+				file := ptr + i*size
+				name, nameLen := header(file)
+				add(v, name, nameLen)
+				data, dataLen := header(file + 2*ptrSize)
+				add(v, data, dataLen)
+			}
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].start != ranges[j].start {
+			return ranges[i].start < ranges[j].start
+		}
+		return ranges[i].name < ranges[j].name
+	})
+	var (
+		out []dataRange
+		end uint64
+	)
+	for _, r := range ranges {
+		if r.end <= end || r.pkg == "" {
+			continue
+		}
+		r.size = r.end - max(r.start, end)
+		end = r.end
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/eth-act/riscv-compilation/cryptobackend"
+)
+
+const (
+	// ecallTag is the build tag of cryptobackend's ECALL backend.
+	ecallTag = "ecallcrypto"
+	// ecallBuild is the constraint cryptobackend selects the backend under.
+	ecallBuild = "riscv64 && " + ecallTag
+)
+
+// ecallPatches hand go-ethereum's keccak256 to the host. Every keccak256 in
+// go-ethereum, from the trie hasher to the KECCAK256 opcode and transaction
+// hashes, takes its state from crypto.NewKeccakState or from the pool behind
+// crypto.Keccak256. The crypto package cannot import cryptobackend, which
+// imports it, so it gets a KeccakState of its own that issues the keccak256
+// ECALL directly.
+var ecallPatches = []patch{
+	{module: geth, file: "crypto/crypto.go", tag: ecallTag, build: ecallBuild, edits: []edit{
+		replace("func NewKeccakState() KeccakState {\n\treturn sha3.NewLegacyKeccak256().(KeccakState)\n", "func NewKeccakState() KeccakState {\n\treturn new(ecallKeccak)\n"),
+		replace("\tNew: func() any {\n\t\treturn sha3.NewLegacyKeccak256().(KeccakState)\n", "\tNew: func() any {\n\t\treturn new(ecallKeccak)\n"),
+	}},
+	{module: geth, file: "crypto/keccak_ecall.go", tag: ecallTag, build: ecallBuild, add: fmt.Sprintf(ecallKeccakGo, cryptobackend.ECallKeccak256)},
+	{module: geth, file: "crypto/keccak_ecall_riscv64.s", tag: ecallTag, build: ecallBuild, add: ecallKeccakAsm},
+}
+
+const ecallKeccakGo = `package crypto
+
+import (
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ecallKeccak256Num is cryptobackend.ECallKeccak256.
+const ecallKeccak256Num = %#x
+
+// ecallKeccak256 hashes inLen bytes at in into the 32 bytes at out on the
+// host and returns the output length, or -1 if the host failed.
+func ecallKeccak256(num uint64, in unsafe.Pointer, inLen uint64, out unsafe.Pointer) int64
+
+// ecallKeccak is a KeccakState that keeps what is written and hashes it on
+// the host with a single ECALL when it is read.
+type ecallKeccak struct {
+	buf    []byte
+	digest common.Hash
+	read   int  // bytes of the digest read so far
+	done   bool // the digest is computed
+}
+
+func (k *ecallKeccak) Write(p []byte) (int, error) {
+	if k.done {
+		panic("crypto: Write after Read")
+	}
+	k.buf = append(k.buf, p...)
+	return len(p), nil
+}
+
+// Read reads the digest. Keccak can be squeezed for more, but the host only
+// returns the 32-byte digest, and nothing in go-ethereum reads further.
+func (k *ecallKeccak) Read(p []byte) (int, error) {
+	if !k.done {
+		k.digest, k.done = k.hash(), true
+	}
+	if len(p) > common.HashLength-k.read {
+		panic("crypto: read past the keccak256 digest")
+	}
+	n := copy(p, k.digest[k.read:])
+	k.read += n
+	return n, nil
+}
+
+func (k *ecallKeccak) Sum(b []byte) []byte {
+	h := k.hash()
+	return append(b, h[:]...)
+}
+
+func (k *ecallKeccak) Reset() {
+	k.buf, k.read, k.done = k.buf[:0], 0, false
+}
+
+func (k *ecallKeccak) Size() int { return common.HashLength }
+
+func (k *ecallKeccak) BlockSize() int { return 136 }
+
+func (k *ecallKeccak) hash() (h common.Hash) {
+	n := ecallKeccak256(ecallKeccak256Num, unsafe.Pointer(unsafe.SliceData(k.buf)), uint64(len(k.buf)), unsafe.Pointer(&h[0]))
+	if n != common.HashLength {
+		panic("crypto: host failed to hash")
+	}
+	return h
+}
+`
+
+const ecallKeccakAsm = `#include "textflag.h"
+
+// func ecallKeccak256(num uint64, in unsafe.Pointer, inLen uint64, out unsafe.Pointer) int64
+TEXT ·ecallKeccak256(SB),NOSPLIT,$0-40
+	MOV	num+0(FP), A7
+	MOV	in+8(FP), A0
+	MOV	inLen+16(FP), A1
+	MOV	out+24(FP), A2
+	MOV	$32, A3
+	ECALL
+	MOV	A0, ret+32(FP)
+	RET
+`
@@ -0,0 +1,289 @@
+// Command lazyinit writes the modules for the lazyinit build of
+// stateless-exec, which defers the heavy package initialisation of its
+// dependencies until first use.
+//
+// Before main runs, every package init has run: go-ethereum builds the jump
+// table of every fork, go-ipa precomputes its square root tables and decodes
+// a verkle point, fastssz hashes its zero tree, and encoding/gob registers its
+// types for a bloom filter file format nothing reads. None of it is needed to
+// execute a block against a Merkle Patricia trie.
+//
+// The Go command does not let an overlay replace files in the module cache,
+// so lazyinit copies each patched module into the output directory, linking
+// the Go files it leaves alone to the module cache, and writes a
+// go.mod that replaces the modules with the copies. Every patched file keeps
+// its original under the !lazyinit build constraint and gets a patched twin
+// under lazyinit, so the same modules build either way:
+//
+//	go run ./lazyinit -o /tmp/lazyinit
+//	go build -modfile /tmp/lazyinit/go.mod -tags lazyinit ./stateless-exec
+//
+// The same modules serve the ecallcrypto build on riscv64, in which
+// go-ethereum's crypto package hashes keccak256 by ECALL like the ECALL
+// backend of cryptobackend, so that trie hashing, the KECCAK256 opcode and
+// transaction hashes are accelerated too:
+//
+//	go build -modfile /tmp/lazyinit/go.mod -tags ecallcrypto ./stateless-exec
+//
+// A patch that no longer applies to the pinned version of its module is an
+// error, not a silent no-op.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultTag is the build tag that selects the patched files.
+const defaultTag = "lazyinit"
+
+// patch changes one file of a module, or leaves it out of the patched build
+// if exclude is set, or adds the file with the contents add.
+type patch struct {
+	module, file string
+	edits        []edit
+	exclude      bool
+	add          string
+	// tag names the patched twin of the file and build is the constraint
+	// it is built under, the original getting the negation. Both are
+	// lazyinit if empty; build defaults to tag.
+	tag, build string
+}
+
+// constraint returns the tag and build constraint of p.
+func (p patch) constraint() (tag, build string) {
+	tag, build = p.tag, p.build
+	if tag == "" {
+		tag = defaultTag
+	}
+	if build == "" {
+		build = tag
+	}
+	return tag, build
+}
+
+// edit replaces every match of old, which must match n times (once if n is
+// zero).
+type edit struct {
+	old *regexp.Regexp
+	new string
+	n   int
+}
+
+// replace replaces the literal text old with new, which must occur once.
+func replace(old, new string) edit {
+	return edit{old: regexp.MustCompile(regexp.QuoteMeta(old)), new: strings.ReplaceAll(new, "$", "$$")}
+}
+
+// replaceAll replaces the n matches of the regular expression pattern with
+// repl, which may refer to submatches.
+func replaceAll(pattern, repl string, n int) edit {
+	return edit{old: regexp.MustCompile(pattern), new: repl, n: n}
+}
+
+func main() {
+	out := flag.String("o", filepath.Join(os.TempDir(), defaultTag), "write the patched modules and go.mod to this directory")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lazyinit [-o dir]\n\nRun from the module root. Build with:\n%s\n", buildCommands("<dir>/go.mod"))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if err := run(*out); err != nil {
+		fmt.Fprintln(os.Stderr, "lazyinit:", err)
+		os.Exit(1)
+	}
+}
+
+func run(out string) error {
+	out, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(out); err != nil {
+		return err
+	}
+	byModule := make(map[string][]patch)
+	var modules []string
+	for _, p := range append(patches, ecallPatches...) {
+		if byModule[p.module] == nil {
+			modules = append(modules, p.module)
+		}
+		byModule[p.module] = append(byModule[p.module], p)
+	}
+	replaced := make(map[string]string)
+	for _, mod := range modules {
+		src, version, err := moduleDir(mod)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(out, filepath.FromSlash(mod))
+		if err := linkTree(src, dst); err != nil {
+			return err
+		}
+		for _, p := range byModule[mod] {
+			if err := apply(p, src, dst); err != nil {
+				return fmt.Errorf("%s@%s: %v", mod, version, err)
+			}
+		}
+		replaced[mod] = dst
+	}
+	if err := writeModFile(out, modules, replaced); err != nil {
+		return err
+	}
+	fmt.Printf("Patched %d modules in %s. Build with:\n%s", len(modules), out, buildCommands(filepath.Join(out, "go.mod")))
+	return nil
+}
+
+// buildCommands returns the commands that build the patched variants of
+// stateless-exec with modfile.
+func buildCommands(modfile string) string {
+	return fmt.Sprintf("  go build -modfile %[1]s -tags %[2]s ./stateless-exec\n"+
+		"  GOOS=linux GOARCH=riscv64 go build -modfile %[1]s -tags %[3]s ./stateless-exec\n", modfile, defaultTag, ecallTag)
+}
+
+// moduleDir returns the module cache directory and version of mod, after the
+// replacements of the main go.mod.
+func moduleDir(mod string) (dir, version string, err error) {
+	cmd := exec.Command("go", "list", "-m", "-json", mod)
+	cmd.Stderr = os.Stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("go list -m %s: %v", mod, err)
+	}
+	var m struct {
+		Dir, Version string
+		Replace      *struct{ Dir, Version string }
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return "", "", err
+	}
+	if m.Replace != nil {
+		m.Dir, m.Version = m.Replace.Dir, m.Replace.Version
+	}
+	if m.Dir == "" {
+		return "", "", fmt.Errorf("module %s is not downloaded; run go mod download", mod)
+	}
+	return m.Dir, m.Version, nil
+}
+
+// linkTree recreates the directories of src under dst, except testdata, with
+// a symlink for every Go file and a hard link or copy of every other file,
+// which go:embed does not read through symlinks.
+func linkTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir() && d.Name() == "testdata":
+			return filepath.SkipDir
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case strings.HasSuffix(path, ".go"):
+			return os.Symlink(path, target)
+		}
+		if os.Link(path, target) == nil {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, b, 0o644)
+	})
+}
+
+// apply writes the original of the patched file under the negation of its
+// build constraint and the patched copy next to it under the constraint.
+func apply(p patch, src, dst string) error {
+	tag, build := p.constraint()
+	target := filepath.Join(dst, filepath.FromSlash(p.file))
+	if p.add != "" {
+		if _, err := os.Lstat(target); err == nil {
+			return fmt.Errorf("%s: file to add already exists", p.file)
+		}
+		return os.WriteFile(target, constrain([]byte(p.add), build), 0o644)
+	}
+	b, err := os.ReadFile(filepath.Join(src, filepath.FromSlash(p.file)))
+	if err != nil {
+		return err
+	}
+	patched := string(b)
+	for _, e := range p.edits {
+		want := e.n
+		if want == 0 {
+			want = 1
+		}
+		if n := len(e.old.FindAllStringIndex(patched, -1)); n != want {
+			return fmt.Errorf("%s: patch no longer applies: %d matches of %q, want %d", p.file, n, e.old, want)
+		}
+		patched = e.old.ReplaceAllString(patched, e.new)
+	}
+	if err := os.Remove(target); err != nil {
+		return err
+	}
+	not := "!" + build
+	if strings.ContainsAny(build, " &|") {
+		not = "!(" + build + ")"
+	}
+	if err := os.WriteFile(target, constrain(b, not), 0o644); err != nil || p.exclude {
+		return err
+	}
+	twin := strings.TrimSuffix(target, ".go") + "_" + tag + ".go"
+	return os.WriteFile(twin, constrain([]byte(patched), build), 0o644)
+}
+
+var buildLine = regexp.MustCompile(`(?m)^//go:build (.*)$`)
+
+// constrain adds expr to the build constraint of a Go file.
+func constrain(src []byte, expr string) []byte {
+	if loc := buildLine.FindSubmatchIndex(src); loc != nil && strings.TrimSpace(string(src[:loc[0]])) == "" {
+		line := fmt.Sprintf("//go:build (%s) && %s", src[loc[2]:loc[3]], expr)
+		return append([]byte(string(src[:loc[0]])+line), src[loc[1]:]...)
+	}
+	return append([]byte("//go:build "+expr+"\n\n"), src...)
+}
+
+// writeModFile writes the main go.mod and go.sum to out, with each of
+// modules replaced by its patched copy.
+func writeModFile(out string, modules []string, replaced map[string]string) error {
+	b, err := os.ReadFile("go.mod")
+	if err != nil {
+		return err
+	}
+	patched := make(map[string]bool)
+	for _, mod := range modules {
+		patched[mod] = true
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		if f := strings.Fields(line); len(f) > 1 && f[0] == "replace" && patched[f[1]] {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", "// Patched by lazyinit.")
+	for _, mod := range modules {
+		lines = append(lines, fmt.Sprintf("replace %s => %s", mod, replaced[mod]))
+	}
+	if err := os.WriteFile(filepath.Join(out, "go.mod"), []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		return err
+	}
+	sum, err := os.ReadFile("go.sum")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(out, "go.sum"), sum, 0o644)
+}
@@ -0,0 +1,69 @@
+package main
+
+const (
+	geth      = "github.com/ethereum/go-ethereum"
+	goIPA     = "github.com/crate-crypto/go-ipa"
+	fastssz   = "github.com/ferranbt/fastssz"
+	bloomfilt = "github.com/holiman/bloomfilter/v2"
+)
+
+// patches are the changes of the lazyinit build, each of which moves work out
+// of package initialisation or drops an import nothing in the guest uses.
+var patches = []patch{
+	// The interpreter looks up the jump table of its fork once per call
+	// frame; building the 15 tables is most of the init time of core/vm.
+	{module: geth, file: "core/vm/jump_table.go", edits: []edit{
+		replaceAll(`(?m)^\t(\w+InstructionSet)( +)= (new\w+InstructionSet)\(\)$`, "\t${1}${2}= lazyJumpTable(${3})", 15),
+		replace("import (\n\t\"fmt\"\n", "import (\n\t\"fmt\"\n\t\"sync\"\n"),
+		replace("// JumpTable contains the EVM opcodes supported at a given fork.\n", `// lazyJumpTable returns a function that builds the jump table on first use
+// and returns the same table after.
+func lazyJumpTable(build func() JumpTable) func() *JumpTable {
+	var (
+		once  sync.Once
+		table JumpTable
+	)
+	return func() *JumpTable {
+		once.Do(func() { table = build() })
+		return &table
+	}
+}
+
+// JumpTable contains the EVM opcodes supported at a given fork.
+`),
+	}},
+	{module: geth, file: "core/vm/interpreter.go", edits: []edit{
+		replaceAll(`table = &(\w+InstructionSet)\n`, "table = ${1}()\n", 15),
+	}},
+	// The verkle point is only needed for binary and verkle tree keys.
+	{module: geth, file: "trie/utils/verkle.go", edits: []edit{
+		replace("\nfunc init() {\n", "\nvar index0PointOnce sync.Once\n\nfunc initIndex0Point() {\n"),
+		replaceAll(`(?m)^\tret\.Add\(ret, index0Point\)$`, "\tindex0PointOnce.Do(initIndex0Point)\n\tret.Add(ret, index0Point)", 2),
+	}},
+	// The square root tables are only read by SqrtPrecomp.
+	{module: goIPA, file: "bandersnatch/fp/sqrt.go", edits: []edit{
+		replace(`import "math/big"`, "import (\n\t\"math/big\"\n\t\"sync\"\n)"),
+		replace("\nfunc init() {\n", "\nvar sqrtPrecompOnce sync.Once\n\nfunc initSqrtPrecomp() {\n"),
+		replace("func SqrtPrecomp(x *Element) *Element {\n", "func SqrtPrecomp(x *Element) *Element {\n\tsqrtPrecompOnce.Do(initSqrtPrecomp)\n"),
+	}},
+	// The zero hashes are only read when merkleizing and by multiproofs.
+	{module: fastssz, file: "hasher.go", edits: []edit{
+		replace("\tzeroHashLevels = make(map[string]int)\n", "}\n\nvar zeroHashesOnce sync.Once\n\nfunc initZeroHashes() {\n\tzeroHashLevels = make(map[string]int)\n"),
+		replace("func (h *Hasher) merkleizeImpl(dst []byte, input []byte, limit uint64) []byte {\n", "func (h *Hasher) merkleizeImpl(dst []byte, input []byte, limit uint64) []byte {\n\tzeroHashesOnce.Do(initZeroHashes)\n"),
+	}},
+	{module: fastssz, file: "tree.go", edits: []edit{
+		replace("func (p *Multiproof) Compress() *CompressedMultiproof {\n", "func (p *Multiproof) Compress() *CompressedMultiproof {\n\tzeroHashesOnce.Do(initZeroHashes)\n"),
+		replace("func (c *CompressedMultiproof) Decompress() *Multiproof {\n", "func (c *CompressedMultiproof) Decompress() *Multiproof {\n\tzeroHashesOnce.Do(initZeroHashes)\n"),
+	}},
+	// UnmarshalSSZTest reads spec test YAML, and is the only importer of
+	// gopkg.in/yaml.v2.
+	{module: fastssz, file: "testutil.go", exclude: true},
+	// The snapshot bloom filters are never written to files, and the blank
+	// and conformance imports are the only importers of encoding/gob.
+	{module: bloomfilt, file: "fileio.go", edits: []edit{
+		replace("\t_ \"encoding/gob\" // make sure gob is available\n", ""),
+	}},
+	{module: bloomfilt, file: "conformance.go", edits: []edit{
+		replace("\t\"encoding/gob\"\n", ""),
+		replace("\t_ gob.GobDecoder             = (*Filter)(nil)\n\t_ gob.GobEncoder             = (*Filter)(nil)\n", ""),
+	}},
+}
@@ -0,0 +1,59 @@
+package linuxabi
+
+// Linux errno values. Syscalls return them negated.
+const (
+	EPERM     = 1
+	ENOENT    = 2
+	ESRCH     = 3
+	EINTR     = 4
+	EBADF     = 9
+	EAGAIN    = 11
+	ENOMEM    = 12
+	EACCES    = 13
+	EFAULT    = 14
+	EEXIST    = 17
+	ENOTDIR   = 20
+	EISDIR    = 21
+	EINVAL    = 22
+	ENOTTY    = 25
+	ESPIPE    = 29
+	EROFS     = 30
+	ERANGE    = 34
+	ENOSYS    = 38
+	ETIMEDOUT = 110
+)
+
+var errnoNames = map[int64]string{
+	EPERM:     "EPERM",
+	ENOENT:    "ENOENT",
+	ESRCH:     "ESRCH",
+	EINTR:     "EINTR",
+	EBADF:     "EBADF",
+	EAGAIN:    "EAGAIN",
+	ENOMEM:    "ENOMEM",
+	EACCES:    "EACCES",
+	EFAULT:    "EFAULT",
+	EEXIST:    "EEXIST",
+	ENOTDIR:   "ENOTDIR",
+	EISDIR:    "EISDIR",
+	EINVAL:    "EINVAL",
+	ENOTTY:    "ENOTTY",
+	ESPIPE:    "ESPIPE",
+	EROFS:     "EROFS",
+	ERANGE:    "ERANGE",
+	ENOSYS:    "ENOSYS",
+	ETIMEDOUT: "ETIMEDOUT",
+}
+
+// Errno returns the value of a named errno such as "ENOTTY".
+func Errno(name string) (int64, bool) {
+	for v, n := range errnoNames {
+		if n == name {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// errno encodes a negated errno as a syscall return value.
+func errno(e int64) uint64 { return uint64(-e) }
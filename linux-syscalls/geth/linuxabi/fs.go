@@ -0,0 +1,413 @@
+package linuxabi
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	sIFREG = 0o100000
+	sIFDIR = 0o040000
+	sIFCHR = 0o020000
+
+	epollIn     = 0x1
+	epollCtlAdd = 1
+	epollCtlDel = 2
+	epollCtlMod = 3
+)
+
+// file is an open file description. Regular files share the contents of
+// Config.Files and are read-only.
+type file struct {
+	name string
+	data []byte // regular file contents
+	dir  bool
+	off  int64
+	r    io.Reader // stdin
+	w    io.Writer // stdout, stderr
+
+	eventfd bool
+	count   uint64 // eventfd counter
+
+	epoll map[int]uint64 // registered fd -> user data
+}
+
+func (k *Kernel) install(f *file) uint64 {
+	fd := k.nextFD
+	for k.files[fd] != nil {
+		fd++
+	}
+	k.files[fd] = f
+	k.nextFD = fd + 1
+	return uint64(fd)
+}
+
+// hostState reports whether p is a pseudo-file describing the host rather
+// than a file, such as /proc/stat. These change from run to run, so they are
+// never taken from the host file system.
+func hostState(p string) bool {
+	for _, dir := range []string{"/proc", "/sys", "/dev"} {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup resolves a guest path against the in-memory files, falling back to
+// the host file system if HostFS is set.
+func (k *Kernel) lookup(name string) (data []byte, dir bool, ok bool) {
+	p := name
+	if !path.IsAbs(p) {
+		p = path.Join(k.cfg.Cwd, p)
+	}
+	p = path.Clean(p)
+	if data, ok := k.cfg.Files[p]; ok {
+		return data, false, true
+	}
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	for f := range k.cfg.Files {
+		if strings.HasPrefix(f, prefix) {
+			return nil, true, true
+		}
+	}
+	if !k.cfg.HostFS || hostState(p) {
+		return nil, false, false
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, false, false
+	}
+	if info.IsDir() {
+		return nil, true, true
+	}
+	data, err = os.ReadFile(name)
+	if err != nil {
+		return nil, false, false
+	}
+	return data, false, true
+}
+
+func (k *Kernel) sysOpenat(mem Memory, a [6]uint64) uint64 {
+	name, err := readString(mem, a[1], 4096)
+	if err != nil {
+		return errno(EFAULT)
+	}
+	if int32(a[0]) != atFDCWD && !path.IsAbs(name) {
+		return errno(ENOSYS)
+	}
+	if a[2]&3 != 0 || a[2]&0x40 != 0 { // O_WRONLY, O_RDWR, O_CREAT
+		return errno(EROFS)
+	}
+	data, dir, ok := k.lookup(name)
+	switch {
+	case !ok:
+		return errno(ENOENT)
+	case dir:
+		return k.install(&file{name: name, dir: true})
+	case data == nil:
+		data = []byte{}
+	}
+	return k.install(&file{name: name, data: data})
+}
+
+func (k *Kernel) sysRead(mem Memory, fd int, buf, count uint64, at int64) uint64 {
+	f, ok := k.files[fd]
+	if !ok {
+		return errno(EBADF)
+	}
+	if !inRange(buf, count) {
+		return errno(EFAULT)
+	}
+	switch {
+	case f.r != nil:
+		// A short read is allowed, so at most a page is read at a time.
+		b := make([]byte, min(count, pageSize))
+		n, err := f.r.Read(b)
+		if err != nil && err != io.EOF {
+			return errno(EINTR)
+		}
+		if mem.Write(buf, b[:n]) != nil {
+			return errno(EFAULT)
+		}
+		return uint64(n)
+	case f.eventfd:
+		if count < 8 {
+			return errno(EINVAL)
+		}
+		if f.count == 0 {
+			return errno(EAGAIN)
+		}
+		v := f.count
+		f.count = 0
+		if putUint64(mem, buf, v) != nil {
+			return errno(EFAULT)
+		}
+		return 8
+	case f.dir:
+		return errno(EISDIR)
+	case f.data == nil:
+		return errno(EBADF)
+	}
+	off := f.off
+	if at >= 0 {
+		off = at
+	}
+	if off >= int64(len(f.data)) {
+		return 0
+	}
+	data := f.data[off:]
+	if uint64(len(data)) > count {
+		data = data[:count]
+	}
+	if mem.Write(buf, data) != nil {
+		return errno(EFAULT)
+	}
+	if at < 0 {
+		f.off += int64(len(data))
+	}
+	return uint64(len(data))
+}
+
+func (k *Kernel) sysWrite(mem Memory, fd int, buf, count uint64) uint64 {
+	f, ok := k.files[fd]
+	if !ok {
+		return errno(EBADF)
+	}
+	if !inRange(buf, count) {
+		return errno(EFAULT)
+	}
+	switch {
+	case f.w != nil:
+		// The buffer is copied out a page at a time, so the length the guest
+		// passes never sizes a host allocation. Like Linux, a fault part way
+		// returns what was written before it.
+		var done uint64
+		b := make([]byte, min(count, pageSize))
+		for done < count {
+			chunk := b[:min(count-done, pageSize)]
+			if mem.Read(buf+done, chunk) != nil {
+				if done == 0 {
+					return errno(EFAULT)
+				}
+				break
+			}
+			n, _ := f.w.Write(chunk)
+			done += uint64(n)
+			if n < len(chunk) {
+				break
+			}
+		}
+		return done
+	case f.eventfd:
+		if count < 8 {
+			return errno(EINVAL)
+		}
+		v, err := getUint64(mem, buf)
+		if err != nil {
+			return errno(EFAULT)
+		}
+		f.count += v
+		k.pollWake(mem)
+		return 8
+	}
+	return errno(EBADF)
+}
+
+// inRange reports whether the guest range [addr, addr+size) does not wrap
+// around the address space.
+func inRange(addr, size uint64) bool {
+	return addr+size >= addr
+}
+
+// sysVector implements readv and writev on top of read and write.
+func (k *Kernel) sysVector(mem Memory, num uint64, a [6]uint64) uint64 {
+	var total uint64
+	for i := uint64(0); i < a[2]; i++ {
+		base, err1 := getUint64(mem, a[1]+16*i)
+		n, err2 := getUint64(mem, a[1]+16*i+8)
+		if err1 != nil || err2 != nil {
+			return errno(EFAULT)
+		}
+		var ret uint64
+		if num == sysReadv {
+			ret = k.sysRead(mem, int(a[0]), base, n, -1)
+		} else {
+			ret = k.sysWrite(mem, int(a[0]), base, n)
+		}
+		if int64(ret) < 0 {
+			if total > 0 {
+				break
+			}
+			return ret
+		}
+		total += ret
+		if ret < n {
+			break
+		}
+	}
+	return total
+}
+
+func (k *Kernel) sysLseek(fd int, off int64, whence int) uint64 {
+	f, ok := k.files[fd]
+	if !ok {
+		return errno(EBADF)
+	}
+	if f.data == nil {
+		return errno(ESPIPE)
+	}
+	switch whence {
+	case 0:
+	case 1:
+		off += f.off
+	case 2:
+		off += int64(len(f.data))
+	default:
+		return errno(EINVAL)
+	}
+	if off < 0 {
+		return errno(EINVAL)
+	}
+	f.off = off
+	return uint64(off)
+}
+
+// putStat writes a riscv64 struct stat.
+func putStat(mem Memory, addr uint64, mode uint32, size int64) uint64 {
+	var st [128]byte
+	binary.LittleEndian.PutUint64(st[8:], 1)     // st_ino
+	binary.LittleEndian.PutUint32(st[16:], mode) // st_mode
+	binary.LittleEndian.PutUint32(st[20:], 1)    // st_nlink
+	binary.LittleEndian.PutUint64(st[48:], uint64(size))
+	binary.LittleEndian.PutUint32(st[56:], pageSize) // st_blksize
+	binary.LittleEndian.PutUint64(st[64:], uint64(size+511)/512)
+	if mem.Write(addr, st[:]) != nil {
+		return errno(EFAULT)
+	}
+	return 0
+}
+
+func (k *Kernel) sysFstat(mem Memory, fd int, addr uint64) uint64 {
+	f, ok := k.files[fd]
+	if !ok {
+		return errno(EBADF)
+	}
+	switch {
+	case f.dir:
+		return putStat(mem, addr, sIFDIR|0o755, 0)
+	case f.data != nil:
+		return putStat(mem, addr, sIFREG|0o644, int64(len(f.data)))
+	}
+	return putStat(mem, addr, sIFCHR|0o620, 0)
+}
+
+func (k *Kernel) sysNewfstatat(mem Memory, a [6]uint64) uint64 {
+	name, err := readString(mem, a[1], 4096)
+	if err != nil {
+		return errno(EFAULT)
+	}
+	if name == "" && a[3]&atEmptyPath != 0 {
+		return k.sysFstat(mem, int(int32(a[0])), a[2])
+	}
+	if int32(a[0]) != atFDCWD && !path.IsAbs(name) {
+		return errno(ENOSYS)
+	}
+	data, dir, ok := k.lookup(name)
+	switch {
+	case !ok:
+		return errno(ENOENT)
+	case dir:
+		return putStat(mem, a[2], sIFDIR|0o755, 0)
+	}
+	return putStat(mem, a[2], sIFREG|0o644, int64(len(data)))
+}
+
+func (k *Kernel) sysEpollCtl(mem Memory, a [6]uint64) uint64 {
+	ep, ok := k.files[int(a[0])]
+	if !ok || ep.epoll == nil {
+		return errno(EBADF)
+	}
+	fd := int(a[2])
+	f, ok := k.files[fd]
+	if !ok {
+		return errno(EBADF)
+	}
+	if f.data != nil || f.dir {
+		// Like Linux, regular files cannot be polled.
+		return errno(EPERM)
+	}
+	switch a[1] {
+	case epollCtlAdd, epollCtlMod:
+		// struct epoll_event { u32 events; u64 data; } is not packed on
+		// riscv64, so data is at offset 8.
+		data, err := getUint64(mem, a[3]+8)
+		if err != nil {
+			return errno(EFAULT)
+		}
+		ep.epoll[fd] = data
+	case epollCtlDel:
+		delete(ep.epoll, fd)
+	default:
+		return errno(EINVAL)
+	}
+	return 0
+}
+
+func (k *Kernel) sysEpollPwait(mem Memory, a [6]uint64) (uint64, bool, error) {
+	ep, ok := k.files[int(a[0])]
+	if !ok || ep.epoll == nil {
+		return errno(EBADF), false, nil
+	}
+	if n := k.pollReady(mem, ep, a[1], a[2]); n != 0 || int32(a[3]) == 0 {
+		return n, false, nil
+	}
+	k.cur.epoll = [3]uint64{a[0], a[1], a[2]}
+	if timeout := int32(a[3]); timeout > 0 {
+		k.cur.deadline = k.clock + uint64(timeout)*1e6
+	}
+	return 0, true, nil
+}
+
+// pollReady stores the ready events of ep and returns their number. Only
+// eventfds ever become ready.
+func (k *Kernel) pollReady(mem Memory, ep *file, events, max uint64) uint64 {
+	fds := make([]int, 0, len(ep.epoll))
+	for fd := range ep.epoll {
+		fds = append(fds, fd)
+	}
+	sort.Ints(fds)
+
+	var n uint64
+	for _, fd := range fds {
+		if n >= max {
+			break
+		}
+		if f, ok := k.files[fd]; ok && f.eventfd && f.count > 0 {
+			putUint32(mem, events+16*n, epollIn)
+			putUint64(mem, events+16*n+8, ep.epoll[fd])
+			n++
+		}
+	}
+	return n
+}
+
+// pollWake wakes the threads blocked in epoll_pwait that have events ready.
+func (k *Kernel) pollWake(mem Memory) {
+	for _, t := range k.threads {
+		if t.state != threadBlocked || t.num != sysEpollPwait {
+			continue
+		}
+		ep, ok := k.files[int(t.epoll[0])]
+		if !ok {
+			continue
+		}
+		if n := k.pollReady(mem, ep, t.epoll[1], t.epoll[2]); n > 0 {
+			k.wake(t, n)
+		}
+	}
+}
@@ -0,0 +1,618 @@
+// Package linuxabi implements the Linux syscall ABI over in-memory state, the
+// way REPORT.md proposes a zkVM should: files are pre-loaded into an fd table,
+// the clock and getrandom are deterministic, threads created by clone are
+// scheduled cooperatively around futex waits, and any syscall can be replaced
+// by a stub.
+//
+// The package knows nothing about instruction sets. An emulator or test
+// harness plugs it in by implementing Host and Thread and calling
+// Kernel.Syscall for every trap. Syscall numbers follow the asm-generic table
+// used by riscv64.
+package linuxabi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/eth-act/riscv-compilation/deterministic"
+)
+
+// Memory is the guest address space.
+type Memory interface {
+	Read(addr uint64, buf []byte) error
+	Write(addr uint64, buf []byte) error
+	// Map makes a page-aligned range accessible, zero-filled.
+	Map(addr, size uint64)
+	Unmap(addr, size uint64)
+	// Free reports whether no address in the range is mapped.
+	Free(addr, size uint64) bool
+}
+
+// Thread is the register context of a guest thread.
+type Thread interface {
+	// SetReturn sets the value the pending syscall returns.
+	SetReturn(v uint64)
+	// Clone returns a copy of the thread that resumes after the same syscall
+	// with a zero return value. A non-zero sp replaces the stack pointer and
+	// tls, if setTLS is true, the thread pointer.
+	Clone(sp, tls uint64, setTLS bool) Thread
+}
+
+// Host is implemented by the emulator running the kernel.
+type Host interface {
+	Memory
+	// Switch makes t the thread that runs once Syscall returns.
+	Switch(t Thread)
+	// Halt stops the guest with the given exit status.
+	Halt(code int)
+}
+
+// Config describes the environment a guest runs in.
+type Config struct {
+	// Files maps absolute guest paths to file contents. Directories are
+	// implied by the paths of the files they contain.
+	Files map[string][]byte
+	// Cwd is the working directory relative paths are resolved against.
+	Cwd string
+	// HostFS lets the guest open host files that are not in Files, read-only.
+	// /proc, /sys and /dev are excluded: they describe the host.
+	// It breaks the in-memory model and only exists for convenience.
+	HostFS bool
+
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+
+	// Epoch is the wall clock time at boot.
+	Epoch time.Time
+	// TimeStep is how far the clock advances every time the guest reads it.
+	// The clock also jumps forward whenever all threads sleep, so timeouts
+	// expire immediately.
+	TimeStep time.Duration
+	// Seed seeds the generator behind getrandom and AT_RANDOM. The zero
+	// value selects deterministic.DefaultSeed.
+	Seed [32]byte
+
+	// Stubs replaces syscalls, by name, with a fixed return value. Errors are
+	// given as negative errno values, e.g. -ENOTTY.
+	Stubs map[string]int64
+
+	// Trace, if set, receives every syscall in strace format.
+	Trace io.Writer
+}
+
+// DefaultEpoch is the boot time used when Config.Epoch is zero. It matches
+// the zkvm board, as do the default time step and seed.
+var DefaultEpoch = time.Unix(0, deterministic.DefaultEpoch).UTC()
+
+// DefaultStubs returns the stubs needed by a Go or Rust program that has no
+// terminal and no vDSO.
+func DefaultStubs() map[string]int64 {
+	return map[string]int64{
+		"ioctl":         -ENOTTY,
+		"riscv_hwprobe": -ENOSYS,
+		"rseq":          -ENOSYS,
+		"statx":         -ENOSYS,
+		"clone3":        -ENOSYS,
+	}
+}
+
+// ErrDeadlock is returned when every guest thread is blocked without a
+// timeout.
+var ErrDeadlock = errors.New("deadlock: all guest threads are blocked")
+
+// Kernel is the syscall layer of one guest process.
+type Kernel struct {
+	cfg  Config
+	rand deterministic.Random
+
+	// Syscalls counts the syscalls issued by the guest, by number.
+	Syscalls map[uint64]int
+
+	brkStart, brk uint64
+	mmapTop       uint64
+	mmapBase      uint64
+	userTop       uint64
+	clock         uint64 // nanoseconds since boot
+
+	threads []*thread
+	cur     *thread
+	nextTID int
+
+	files  map[int]*file
+	nextFD int
+
+	sigactions [65][24]byte
+}
+
+// New creates a kernel for the given configuration.
+func New(cfg Config) *Kernel {
+	if cfg.Cwd == "" {
+		cfg.Cwd = "/"
+	}
+	if cfg.Epoch.IsZero() {
+		cfg.Epoch = DefaultEpoch
+	}
+	if cfg.TimeStep == 0 {
+		cfg.TimeStep = deterministic.DefaultTimeStep
+	}
+	if cfg.Seed == ([32]byte{}) {
+		cfg.Seed = deterministic.DefaultSeed
+	}
+	if cfg.Stdout == nil {
+		cfg.Stdout = io.Discard
+	}
+	if cfg.Stderr == nil {
+		cfg.Stderr = io.Discard
+	}
+	k := &Kernel{
+		cfg:      cfg,
+		Syscalls: make(map[uint64]int),
+		nextTID:  1000,
+		nextFD:   3,
+	}
+	k.rand.Seed(cfg.Seed)
+	k.files = map[int]*file{
+		0: {name: "stdin", r: cfg.Stdin},
+		1: {name: "stdout", w: cfg.Stdout},
+		2: {name: "stderr", w: cfg.Stderr},
+	}
+	if cfg.Stdin == nil {
+		k.files[0].r = eofReader{}
+	}
+	return k
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// Layout describes the guest address space: brk is the end of the loaded
+// image, mmapBase is where mappings without a usable hint are placed (growing
+// down) and userTop is the end of the user address space.
+type Layout struct {
+	Brk, MmapBase, UserTop uint64
+}
+
+// Start registers the initial thread of the guest.
+func (k *Kernel) Start(main Thread, layout Layout) {
+	k.brkStart, k.brk = layout.Brk, layout.Brk
+	k.mmapBase, k.mmapTop = layout.MmapBase, layout.MmapBase
+	k.userTop = layout.UserTop
+	k.cur = k.newThread(main)
+}
+
+// Random fills buf from the deterministic generator, e.g. for AT_RANDOM.
+func (k *Kernel) Random(buf []byte) {
+	k.rand.Read(buf)
+}
+
+func (k *Kernel) newThread(t Thread) *thread {
+	th := &thread{tid: k.nextTID, cpu: t}
+	k.nextTID++
+	k.threads = append(k.threads, th)
+	return th
+}
+
+// Syscall services syscall num of the current thread. When it returns, the
+// host continues with the thread passed to its last Switch call, which may
+// differ from the caller if the syscall blocked.
+func (k *Kernel) Syscall(host Host, num uint64, args [6]uint64) error {
+	k.Syscalls[num]++
+
+	var (
+		call string
+		t    = k.cur
+	)
+	if k.cfg.Trace != nil {
+		call = formatCall(host, num, args)
+		if num == sysExit || num == sysExitGroup {
+			fmt.Fprintf(k.cfg.Trace, "%d %s = ?\n", t.tid, call)
+		}
+	}
+	if ret, ok := k.cfg.Stubs[SyscallName(num)]; ok {
+		if k.cfg.Trace != nil {
+			fmt.Fprintf(k.cfg.Trace, "%d %s = %s\n", t.tid, call, formatRet(num, uint64(ret)))
+		}
+		t.cpu.SetReturn(uint64(ret))
+		return nil
+	}
+	ret, block, err := k.dispatch(host, num, args)
+	if err != nil {
+		return err
+	}
+	if block {
+		if k.cfg.Trace != nil {
+			fmt.Fprintf(k.cfg.Trace, "%d %s <unfinished ...>\n", t.tid, call)
+		}
+		t.state = threadBlocked
+		t.num = num
+		return k.schedule(host)
+	}
+	if num == sysExit || num == sysExitGroup || num == sysSchedYield {
+		return nil
+	}
+	if k.cfg.Trace != nil {
+		fmt.Fprintf(k.cfg.Trace, "%d %s = %s\n", t.tid, call, formatRet(num, ret))
+	}
+	t.cpu.SetReturn(ret)
+	return nil
+}
+
+// dispatch runs a single syscall for the current thread. If block is set, the
+// thread has to wait and the result is delivered by wake.
+func (k *Kernel) dispatch(host Host, num uint64, a [6]uint64) (ret uint64, block bool, err error) {
+	switch num {
+	case sysBrk:
+		return k.sysBrk(host, a[0]), false, nil
+	case sysMmap:
+		return k.sysMmap(host, a), false, nil
+	case sysMunmap:
+		if a[0]&pageMask != 0 {
+			return errno(EINVAL), false, nil
+		}
+		host.Unmap(a[0], a[1])
+		return 0, false, nil
+	case sysMremap:
+		return errno(ENOMEM), false, nil
+	case sysMprotect, sysMadvise:
+		return 0, false, nil
+
+	case sysOpenat:
+		return k.sysOpenat(host, a), false, nil
+	case sysClose:
+		if _, ok := k.files[int(a[0])]; !ok {
+			return errno(EBADF), false, nil
+		}
+		delete(k.files, int(a[0]))
+		return 0, false, nil
+	case sysRead:
+		return k.sysRead(host, int(a[0]), a[1], a[2], -1), false, nil
+	case sysPread64:
+		return k.sysRead(host, int(a[0]), a[1], a[2], int64(a[3])), false, nil
+	case sysReadv, sysWritev:
+		return k.sysVector(host, num, a), false, nil
+	case sysWrite:
+		return k.sysWrite(host, int(a[0]), a[1], a[2]), false, nil
+	case sysLseek:
+		return k.sysLseek(int(a[0]), int64(a[1]), int(a[2])), false, nil
+	case sysFstat:
+		return k.sysFstat(host, int(a[0]), a[1]), false, nil
+	case sysNewfstatat:
+		return k.sysNewfstatat(host, a), false, nil
+	case sysFcntl:
+		if _, ok := k.files[int(a[0])]; !ok {
+			return errno(EBADF), false, nil
+		}
+		if a[1] == 3 { // F_GETFL
+			return 2, false, nil // O_RDWR
+		}
+		return 0, false, nil
+	case sysReadlinkat, sysFaccessat, sysFaccessat2:
+		return errno(ENOENT), false, nil
+	case sysGetcwd:
+		return k.sysGetcwd(host, a[0], a[1]), false, nil
+
+	case sysEpollCreate1:
+		return k.install(&file{name: "epoll", epoll: map[int]uint64{}}), false, nil
+	case sysEpollCtl:
+		return k.sysEpollCtl(host, a), false, nil
+	case sysEpollPwait:
+		return k.sysEpollPwait(host, a)
+	case sysEventfd2:
+		return k.install(&file{name: "eventfd", eventfd: true, count: a[0]}), false, nil
+
+	case sysClone:
+		return k.sysClone(host, a), false, nil
+	case sysExit:
+		return 0, false, k.exitThread(host, int(int32(a[0])))
+	case sysExitGroup:
+		if k.cfg.Trace != nil {
+			fmt.Fprintf(k.cfg.Trace, "%d +++ exited with %d +++\n", k.cur.tid, int32(a[0]))
+		}
+		host.Halt(int(int32(a[0])))
+		return 0, false, nil
+	case sysFutex:
+		return k.sysFutex(host, a)
+	case sysSchedYield:
+		if k.cfg.Trace != nil {
+			fmt.Fprintf(k.cfg.Trace, "%d sched_yield() = 0\n", k.cur.tid)
+		}
+		k.cur.cpu.SetReturn(0)
+		return 0, false, k.schedule(host)
+	case sysNanosleep:
+		return k.sleepUntil(host, a[0], false)
+	case sysClockNanosleep:
+		return k.sleepUntil(host, a[2], a[1]&timerAbstime != 0)
+	case sysSetTidAddress:
+		k.cur.clearTID = a[0]
+		return uint64(k.cur.tid), false, nil
+	case sysGettid:
+		return uint64(k.cur.tid), false, nil
+	case sysGetpid:
+		return uint64(k.threads[0].tid), false, nil
+	case sysGetppid:
+		return 1, false, nil
+	case sysGetuid, sysGeteuid, sysGetgid, sysGetegid:
+		return 0, false, nil
+	case sysKill, sysTkill, sysTgkill:
+		// Signals are never delivered; the Go runtime only uses them for
+		// asynchronous preemption, which a cooperative scheduler does not need.
+		return 0, false, nil
+	case sysSetRobustList:
+		return 0, false, nil
+
+	case sysRtSigaction:
+		return k.sysRtSigaction(host, a), false, nil
+	case sysRtSigprocmask:
+		if a[2] != 0 {
+			var set [8]byte
+			host.Write(a[2], set[:])
+		}
+		return 0, false, nil
+	case sysSigaltstack:
+		if a[1] != 0 {
+			var ss [24]byte
+			binary.LittleEndian.PutUint32(ss[8:], 2) // SS_DISABLE
+			if host.Write(a[1], ss[:]) != nil {
+				return errno(EFAULT), false, nil
+			}
+		}
+		return 0, false, nil
+
+	case sysSchedGetaffin:
+		if a[1] < 8 {
+			return errno(EINVAL), false, nil
+		}
+		if putUint64(host, a[2], 1) != nil {
+			return errno(EFAULT), false, nil
+		}
+		return 8, false, nil
+	case sysClockGettime:
+		k.clock += uint64(k.cfg.TimeStep)
+		ns := k.clock
+		if a[0] == 0 || a[0] == 5 { // CLOCK_REALTIME, CLOCK_REALTIME_COARSE
+			ns += uint64(k.cfg.Epoch.UnixNano())
+		}
+		return putTimespec(host, a[1], ns), false, nil
+	case sysClockGetres:
+		if a[1] == 0 {
+			return 0, false, nil
+		}
+		return putTimespec(host, a[1], 1), false, nil
+	case sysGetrandom:
+		return k.sysGetrandom(host, a[0], a[1]), false, nil
+	case sysUname:
+		return k.sysUname(host, a[0]), false, nil
+	case sysPrlimit64:
+		return k.getrlimit(host, a[1], a[3]), false, nil
+	case sysGetrlimit:
+		return k.getrlimit(host, a[0], a[1]), false, nil
+	}
+	return errno(ENOSYS), false, nil
+}
+
+const (
+	pageSize = 4096
+	pageMask = pageSize - 1
+
+	atFDCWD     = -100
+	atEmptyPath = 0x1000
+
+	mapFixed     = 0x10
+	mapAnonymous = 0x20
+
+	timerAbstime = 1
+
+	stackSize = 8 << 20
+)
+
+func (k *Kernel) sysBrk(mem Memory, addr uint64) uint64 {
+	if addr < k.brkStart {
+		return k.brk
+	}
+	oldEnd := (k.brk + pageMask) &^ pageMask
+	newEnd := (addr + pageMask) &^ pageMask
+	switch {
+	case newEnd > oldEnd:
+		if !mem.Free(oldEnd, newEnd-oldEnd) {
+			return k.brk
+		}
+		mem.Map(oldEnd, newEnd-oldEnd)
+	case newEnd < oldEnd:
+		mem.Unmap(newEnd, oldEnd-newEnd)
+	}
+	k.brk = addr
+	return k.brk
+}
+
+func (k *Kernel) sysMmap(mem Memory, a [6]uint64) uint64 {
+	var (
+		hint   = a[0]
+		length = (a[1] + pageMask) &^ pageMask
+		flags  = a[3]
+		fd     = int(int32(a[4]))
+		off    = a[5]
+	)
+	if length == 0 || hint&pageMask != 0 {
+		return errno(EINVAL)
+	}
+	var addr uint64
+	switch {
+	case flags&mapFixed != 0:
+		// A fixed mapping replaces what is there, but only in user space.
+		if hint >= k.userTop || length > k.userTop-hint {
+			return errno(EINVAL)
+		}
+		mem.Unmap(hint, length)
+		addr = hint
+	case hint != 0 && hint < k.userTop && length <= k.userTop-hint && mem.Free(hint, length):
+		addr = hint
+	default:
+		// Search down from the top of the mmap area, never below the break
+		// and never wrapping below zero.
+		if length > k.mmapTop-k.brk {
+			return errno(ENOMEM)
+		}
+		addr = k.mmapTop - length
+		for !mem.Free(addr, length) {
+			if addr-k.brk < length {
+				return errno(ENOMEM)
+			}
+			addr -= length
+		}
+		k.mmapTop = addr
+	}
+	mem.Map(addr, length)
+	if flags&mapAnonymous == 0 {
+		f, ok := k.files[fd]
+		if !ok || f.data == nil {
+			mem.Unmap(addr, length)
+			return errno(EBADF)
+		}
+		if off < uint64(len(f.data)) {
+			data := f.data[off:]
+			if uint64(len(data)) > length {
+				data = data[:length]
+			}
+			mem.Write(addr, data)
+		}
+	}
+	return addr
+}
+
+// sysGetrandom fills the buffer a page at a time. Pages are whole words, so
+// the stream a guest sees is the same as from a single Random call.
+func (k *Kernel) sysGetrandom(mem Memory, buf, count uint64) uint64 {
+	if !inRange(buf, count) {
+		return errno(EFAULT)
+	}
+	var done uint64
+	b := make([]byte, min(count, pageSize))
+	for done < count {
+		chunk := b[:min(count-done, pageSize)]
+		k.Random(chunk)
+		if mem.Write(buf+done, chunk) != nil {
+			if done == 0 {
+				return errno(EFAULT)
+			}
+			break
+		}
+		done += uint64(len(chunk))
+	}
+	return done
+}
+
+func (k *Kernel) sysGetcwd(mem Memory, buf, size uint64) uint64 {
+	b := append([]byte(k.cfg.Cwd), 0)
+	if uint64(len(b)) > size {
+		return errno(ERANGE)
+	}
+	if mem.Write(buf, b) != nil {
+		return errno(EFAULT)
+	}
+	return uint64(len(b))
+}
+
+func putUint64(mem Memory, addr, v uint64) error {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return mem.Write(addr, b[:])
+}
+
+func getUint64(mem Memory, addr uint64) (uint64, error) {
+	var b [8]byte
+	err := mem.Read(addr, b[:])
+	return binary.LittleEndian.Uint64(b[:]), err
+}
+
+func putTimespec(mem Memory, addr, ns uint64) uint64 {
+	var ts [16]byte
+	binary.LittleEndian.PutUint64(ts[0:], ns/1e9)
+	binary.LittleEndian.PutUint64(ts[8:], ns%1e9)
+	if mem.Write(addr, ts[:]) != nil {
+		return errno(EFAULT)
+	}
+	return 0
+}
+
+func getTimespec(mem Memory, addr uint64) (uint64, bool) {
+	var ts [16]byte
+	if mem.Read(addr, ts[:]) != nil {
+		return 0, false
+	}
+	sec := binary.LittleEndian.Uint64(ts[0:])
+	nsec := binary.LittleEndian.Uint64(ts[8:])
+	return sec*1e9 + nsec, true
+}
+
+// readString reads a NUL-terminated string of at most max bytes.
+func readString(mem Memory, addr uint64, max int) (string, error) {
+	var s []byte
+	for len(s) < max {
+		// Read up to the end of the page, which is mapped if addr is.
+		chunk := make([]byte, pageSize-addr&pageMask)
+		if err := mem.Read(addr, chunk); err != nil {
+			return "", err
+		}
+		for i, c := range chunk {
+			if c == 0 {
+				return string(append(s, chunk[:i]...)), nil
+			}
+		}
+		s = append(s, chunk...)
+		addr += uint64(len(chunk))
+	}
+	return "", errors.New("string too long")
+}
+
+func (k *Kernel) sysRtSigaction(mem Memory, a [6]uint64) uint64 {
+	sig := a[0]
+	if sig == 0 || sig >= uint64(len(k.sigactions)) {
+		return errno(EINVAL)
+	}
+	if a[2] != 0 {
+		if mem.Write(a[2], k.sigactions[sig][:]) != nil {
+			return errno(EFAULT)
+		}
+	}
+	if a[1] != 0 {
+		if mem.Read(a[1], k.sigactions[sig][:]) != nil {
+			return errno(EFAULT)
+		}
+	}
+	return 0
+}
+
+func (k *Kernel) sysUname(mem Memory, addr uint64) uint64 {
+	var buf [6 * 65]byte
+	for i, s := range []string{"Linux", "zkvm", "6.1.0", "#1", "riscv64", "(none)"} {
+		copy(buf[i*65:], s)
+	}
+	if mem.Write(addr, buf[:]) != nil {
+		return errno(EFAULT)
+	}
+	return 0
+}
+
+func (k *Kernel) getrlimit(mem Memory, resource, old uint64) uint64 {
+	if old == 0 {
+		return 0
+	}
+	limit := ^uint64(0) // RLIM_INFINITY
+	switch resource {
+	case 3: // RLIMIT_STACK
+		limit = stackSize
+	case 7: // RLIMIT_NOFILE
+		limit = 1024
+	}
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[0:], limit)
+	binary.LittleEndian.PutUint64(buf[8:], limit)
+	if mem.Write(old, buf[:]) != nil {
+		return errno(EFAULT)
+	}
+	return 0
+}
@@ -0,0 +1,217 @@
+package linuxabi
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	cloneVM            = 0x100
+	cloneThread        = 0x10000
+	cloneSettls        = 0x80000
+	cloneParentSettid  = 0x100000
+	cloneChildCleartid = 0x200000
+	cloneChildSettid   = 0x1000000
+
+	futexWait       = 0
+	futexWake       = 1
+	futexWaitBitset = 9
+	futexWakeBitset = 10
+	futexRealtime   = 256
+)
+
+type threadState int
+
+const (
+	threadRunnable threadState = iota
+	threadBlocked
+	threadExited
+)
+
+// thread is a guest thread. Threads are scheduled cooperatively: the running
+// thread keeps the CPU until it blocks, yields or exits.
+type thread struct {
+	tid      int
+	cpu      Thread
+	state    threadState
+	clearTID uint64
+
+	// Why the thread is blocked.
+	num      uint64 // syscall number
+	futex    uint64 // futex address, 0 if not waiting on a futex
+	deadline uint64 // nanoseconds since boot, 0 for no timeout
+	epoll    [3]uint64
+}
+
+func putUint32(mem Memory, addr uint64, v uint32) error {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return mem.Write(addr, b[:])
+}
+
+func (k *Kernel) sysClone(mem Memory, a [6]uint64) uint64 {
+	flags := a[0]
+	if flags&(cloneVM|cloneThread) != cloneVM|cloneThread {
+		// Only threads are supported; there is no fork.
+		return errno(ENOSYS)
+	}
+	t := k.newThread(k.cur.cpu.Clone(a[1], a[3], flags&cloneSettls != 0))
+	if flags&cloneParentSettid != 0 {
+		putUint32(mem, a[2], uint32(t.tid))
+	}
+	if flags&cloneChildSettid != 0 {
+		putUint32(mem, a[4], uint32(t.tid))
+	}
+	if flags&cloneChildCleartid != 0 {
+		t.clearTID = a[4]
+	}
+	return uint64(t.tid)
+}
+
+// exitThread terminates the current thread. The process exits with code once
+// the last thread is gone.
+func (k *Kernel) exitThread(host Host, code int) error {
+	t := k.cur
+	t.state = threadExited
+	if t.clearTID != 0 {
+		putUint32(host, t.clearTID, 0)
+		k.futexWake(t.clearTID, 1)
+	}
+	for _, t := range k.threads {
+		if t.state != threadExited {
+			return k.schedule(host)
+		}
+	}
+	if k.cfg.Trace != nil {
+		fmt.Fprintf(k.cfg.Trace, "%d +++ exited with %d +++\n", t.tid, code)
+	}
+	host.Halt(code)
+	return nil
+}
+
+func (k *Kernel) sysFutex(mem Memory, a [6]uint64) (uint64, bool, error) {
+	addr, op := a[0], a[1]&0x7f
+	switch op {
+	case futexWait, futexWaitBitset:
+		var v [4]byte
+		if mem.Read(addr, v[:]) != nil {
+			return errno(EFAULT), false, nil
+		}
+		if binary.LittleEndian.Uint32(v[:]) != uint32(a[2]) {
+			return errno(EAGAIN), false, nil
+		}
+		var deadline uint64
+		if a[3] != 0 {
+			ns, ok := getTimespec(mem, a[3])
+			if !ok {
+				return errno(EFAULT), false, nil
+			}
+			if op == futexWait {
+				deadline = k.clock + ns
+			} else {
+				deadline = ns
+				if a[1]&futexRealtime != 0 {
+					deadline -= uint64(k.cfg.Epoch.UnixNano())
+				}
+			}
+			if deadline == 0 {
+				deadline = 1
+			}
+		}
+		k.cur.futex, k.cur.deadline = addr, deadline
+		return 0, true, nil
+	case futexWake, futexWakeBitset:
+		return uint64(k.futexWake(addr, int(a[2]))), false, nil
+	}
+	return errno(ENOSYS), false, nil
+}
+
+// futexWake wakes up to n threads waiting on addr.
+func (k *Kernel) futexWake(addr uint64, n int) int {
+	woken := 0
+	for _, t := range k.threads {
+		if woken >= n {
+			break
+		}
+		if t.state == threadBlocked && t.futex == addr {
+			k.wake(t, 0)
+			woken++
+		}
+	}
+	return woken
+}
+
+func (k *Kernel) sleepUntil(mem Memory, ts uint64, abs bool) (uint64, bool, error) {
+	ns, ok := getTimespec(mem, ts)
+	if !ok {
+		return errno(EFAULT), false, nil
+	}
+	if !abs {
+		ns += k.clock
+	}
+	if ns <= k.clock {
+		return 0, false, nil
+	}
+	k.cur.deadline = ns
+	return 0, true, nil
+}
+
+// wake makes a blocked thread runnable with the given syscall result.
+func (k *Kernel) wake(t *thread, ret uint64) {
+	t.state = threadRunnable
+	t.futex, t.deadline = 0, 0
+	t.cpu.SetReturn(ret)
+	if k.cfg.Trace != nil {
+		fmt.Fprintf(k.cfg.Trace, "%d <... %s resumed>) = %s\n", t.tid, SyscallName(t.num), formatRet(t.num, ret))
+	}
+}
+
+// timeout wakes a thread whose deadline has passed.
+func (k *Kernel) timeout(t *thread) {
+	if t.futex != 0 {
+		k.wake(t, errno(ETIMEDOUT))
+		return
+	}
+	k.wake(t, 0)
+}
+
+// schedule switches to the next runnable thread in round-robin order. If
+// every thread is blocked, the clock jumps to the earliest deadline.
+func (k *Kernel) schedule(host Host) error {
+	start := 0
+	for i, t := range k.threads {
+		if t == k.cur {
+			start = i
+		}
+	}
+	for {
+		var (
+			n    = len(k.threads)
+			next *thread
+		)
+		for i := 1; i <= n; i++ {
+			t := k.threads[(start+i)%n]
+			if t.state == threadBlocked && t.deadline != 0 && t.deadline <= k.clock {
+				k.timeout(t)
+			}
+			if t.state == threadRunnable && next == nil {
+				next = t
+			}
+		}
+		if next != nil {
+			k.cur = next
+			host.Switch(next.cpu)
+			return nil
+		}
+		var first *thread
+		for _, t := range k.threads {
+			if t.state == threadBlocked && t.deadline != 0 && (first == nil || t.deadline < first.deadline) {
+				first = t
+			}
+		}
+		if first == nil {
+			return ErrDeadlock
+		}
+		k.clock = first.deadline
+	}
+}
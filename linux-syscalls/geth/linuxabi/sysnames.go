@@ -0,0 +1,333 @@
+package linuxabi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Linux syscall numbers for riscv64 (asm-generic).
+const (
+	sysGetcwd         = 17
+	sysEventfd2       = 19
+	sysEpollCreate1   = 20
+	sysEpollCtl       = 21
+	sysEpollPwait     = 22
+	sysDup            = 23
+	sysDup3           = 24
+	sysFcntl          = 25
+	sysIoctl          = 29
+	sysFaccessat      = 48
+	sysOpenat         = 56
+	sysClose          = 57
+	sysPipe2          = 59
+	sysLseek          = 62
+	sysRead           = 63
+	sysWrite          = 64
+	sysReadv          = 65
+	sysWritev         = 66
+	sysPread64        = 67
+	sysReadlinkat     = 78
+	sysNewfstatat     = 79
+	sysFstat          = 80
+	sysExit           = 93
+	sysExitGroup      = 94
+	sysSetTidAddress  = 96
+	sysFutex          = 98
+	sysSetRobustList  = 99
+	sysNanosleep      = 101
+	sysClockGettime   = 113
+	sysClockGetres    = 114
+	sysClockNanosleep = 115
+	sysSchedGetaffin  = 123
+	sysSchedYield     = 124
+	sysKill           = 129
+	sysTkill          = 130
+	sysTgkill         = 131
+	sysSigaltstack    = 132
+	sysRtSigaction    = 134
+	sysRtSigprocmask  = 135
+	sysRtSigreturn    = 139
+	sysUname          = 160
+	sysGetrlimit      = 163
+	sysGetpid         = 172
+	sysGetppid        = 173
+	sysGetuid         = 174
+	sysGeteuid        = 175
+	sysGetgid         = 176
+	sysGetegid        = 177
+	sysGettid         = 178
+	sysBrk            = 214
+	sysMunmap         = 215
+	sysMremap         = 216
+	sysClone          = 220
+	sysMmap           = 222
+	sysMprotect       = 226
+	sysMadvise        = 233
+	sysRiscvHwprobe   = 258
+	sysPrlimit64      = 261
+	sysGetrandom      = 278
+	sysStatx          = 291
+	sysRseq           = 293
+	sysClone3         = 435
+	sysFaccessat2     = 439
+)
+
+// argKind selects how a syscall argument is rendered in the trace.
+type argKind int
+
+const (
+	argHex argKind = iota
+	argInt
+	argStr
+	argBuf // input buffer, its length is the next argument
+	argDirFD
+	argOpenFlags
+	argProt
+	argMmapFlags
+	argCloneFlags
+	argFutexOp
+	argSignal
+)
+
+type sysDesc struct {
+	name   string
+	args   []argKind
+	retHex bool
+}
+
+var sysTable = map[uint64]sysDesc{
+	sysGetcwd:         {"getcwd", []argKind{argHex, argInt}, false},
+	sysEventfd2:       {"eventfd2", []argKind{argInt, argHex}, false},
+	sysEpollCreate1:   {"epoll_create1", []argKind{argHex}, false},
+	sysEpollCtl:       {"epoll_ctl", []argKind{argInt, argInt, argInt, argHex}, false},
+	sysEpollPwait:     {"epoll_pwait", []argKind{argInt, argHex, argInt, argInt, argHex}, false},
+	sysDup:            {"dup", []argKind{argInt}, false},
+	sysDup3:           {"dup3", []argKind{argInt, argInt, argHex}, false},
+	sysFcntl:          {"fcntl", []argKind{argInt, argInt, argHex}, false},
+	sysIoctl:          {"ioctl", []argKind{argInt, argHex, argHex}, false},
+	sysFaccessat:      {"faccessat", []argKind{argDirFD, argStr, argHex}, false},
+	sysOpenat:         {"openat", []argKind{argDirFD, argStr, argOpenFlags, argHex}, false},
+	sysClose:          {"close", []argKind{argInt}, false},
+	sysPipe2:          {"pipe2", []argKind{argHex, argHex}, false},
+	sysLseek:          {"lseek", []argKind{argInt, argInt, argInt}, false},
+	sysRead:           {"read", []argKind{argInt, argHex, argInt}, false},
+	sysWrite:          {"write", []argKind{argInt, argBuf, argInt}, false},
+	sysReadv:          {"readv", []argKind{argInt, argHex, argInt}, false},
+	sysWritev:         {"writev", []argKind{argInt, argHex, argInt}, false},
+	sysPread64:        {"pread64", []argKind{argInt, argHex, argInt, argInt}, false},
+	sysReadlinkat:     {"readlinkat", []argKind{argDirFD, argStr, argHex, argInt}, false},
+	sysNewfstatat:     {"newfstatat", []argKind{argDirFD, argStr, argHex, argHex}, false},
+	sysFstat:          {"fstat", []argKind{argInt, argHex}, false},
+	sysExit:           {"exit", []argKind{argInt}, false},
+	sysExitGroup:      {"exit_group", []argKind{argInt}, false},
+	sysSetTidAddress:  {"set_tid_address", []argKind{argHex}, false},
+	sysFutex:          {"futex", []argKind{argHex, argFutexOp, argInt, argHex}, false},
+	sysSetRobustList:  {"set_robust_list", []argKind{argHex, argInt}, false},
+	sysNanosleep:      {"nanosleep", []argKind{argHex, argHex}, false},
+	sysClockGettime:   {"clock_gettime", []argKind{argInt, argHex}, false},
+	sysClockGetres:    {"clock_getres", []argKind{argInt, argHex}, false},
+	sysClockNanosleep: {"clock_nanosleep", []argKind{argInt, argInt, argHex, argHex}, false},
+	sysSchedGetaffin:  {"sched_getaffinity", []argKind{argInt, argInt, argHex}, false},
+	sysSchedYield:     {"sched_yield", nil, false},
+	sysKill:           {"kill", []argKind{argInt, argSignal}, false},
+	sysTkill:          {"tkill", []argKind{argInt, argSignal}, false},
+	sysTgkill:         {"tgkill", []argKind{argInt, argInt, argSignal}, false},
+	sysSigaltstack:    {"sigaltstack", []argKind{argHex, argHex}, false},
+	sysRtSigaction:    {"rt_sigaction", []argKind{argSignal, argHex, argHex, argInt}, false},
+	sysRtSigprocmask:  {"rt_sigprocmask", []argKind{argInt, argHex, argHex, argInt}, false},
+	sysRtSigreturn:    {"rt_sigreturn", nil, false},
+	sysUname:          {"uname", []argKind{argHex}, false},
+	sysGetrlimit:      {"getrlimit", []argKind{argInt, argHex}, false},
+	sysGetpid:         {"getpid", nil, false},
+	sysGetppid:        {"getppid", nil, false},
+	sysGetuid:         {"getuid", nil, false},
+	sysGeteuid:        {"geteuid", nil, false},
+	sysGetgid:         {"getgid", nil, false},
+	sysGetegid:        {"getegid", nil, false},
+	sysGettid:         {"gettid", nil, false},
+	sysBrk:            {"brk", []argKind{argHex}, true},
+	sysMunmap:         {"munmap", []argKind{argHex, argInt}, false},
+	sysMremap:         {"mremap", []argKind{argHex, argInt, argInt, argHex}, true},
+	sysClone:          {"clone", []argKind{argCloneFlags, argHex, argHex, argHex, argHex}, false},
+	sysMmap:           {"mmap", []argKind{argHex, argInt, argProt, argMmapFlags, argInt, argHex}, true},
+	sysMprotect:       {"mprotect", []argKind{argHex, argInt, argProt}, false},
+	sysMadvise:        {"madvise", []argKind{argHex, argInt, argInt}, false},
+	sysRiscvHwprobe:   {"riscv_hwprobe", []argKind{argHex, argInt, argInt, argHex, argHex}, false},
+	sysPrlimit64:      {"prlimit64", []argKind{argInt, argInt, argHex, argHex}, false},
+	sysGetrandom:      {"getrandom", []argKind{argHex, argInt, argHex}, false},
+	sysStatx:          {"statx", []argKind{argDirFD, argStr, argHex, argHex, argHex}, false},
+	sysRseq:           {"rseq", []argKind{argHex, argInt, argHex, argHex}, false},
+	sysClone3:         {"clone3", []argKind{argHex, argInt}, false},
+	sysFaccessat2:     {"faccessat2", []argKind{argDirFD, argStr, argHex, argHex}, false},
+}
+
+// SyscallName returns the name of a riscv64 Linux syscall number.
+func SyscallName(num uint64) string {
+	if desc, ok := sysTable[num]; ok {
+		return desc.name
+	}
+	return fmt.Sprintf("syscall_%d", num)
+}
+
+// SyscallNumber returns the number of the named syscall.
+func SyscallNumber(name string) (uint64, bool) {
+	for num, desc := range sysTable {
+		if desc.name == name {
+			return num, true
+		}
+	}
+	return 0, false
+}
+
+type flagName struct {
+	bit  uint64
+	name string
+}
+
+var (
+	openFlags = []flagName{
+		{0x40, "O_CREAT"}, {0x80, "O_EXCL"}, {0x100, "O_NOCTTY"}, {0x200, "O_TRUNC"},
+		{0x400, "O_APPEND"}, {0x800, "O_NONBLOCK"}, {0x10000, "O_DIRECTORY"},
+		{0x20000, "O_NOFOLLOW"}, {0x80000, "O_CLOEXEC"},
+	}
+	protFlags = []flagName{{1, "PROT_READ"}, {2, "PROT_WRITE"}, {4, "PROT_EXEC"}}
+	mmapFlags = []flagName{
+		{0x01, "MAP_SHARED"}, {0x02, "MAP_PRIVATE"}, {0x10, "MAP_FIXED"},
+		{0x20, "MAP_ANONYMOUS"}, {0x100, "MAP_GROWSDOWN"}, {0x800, "MAP_DENYWRITE"},
+		{0x4000, "MAP_NORESERVE"}, {0x8000, "MAP_POPULATE"}, {0x20000, "MAP_STACK"},
+		{0x40000, "MAP_HUGETLB"}, {0x100000, "MAP_FIXED_NOREPLACE"},
+	}
+	cloneFlags = []flagName{
+		{0x100, "CLONE_VM"}, {0x200, "CLONE_FS"}, {0x400, "CLONE_FILES"},
+		{0x800, "CLONE_SIGHAND"}, {0x1000, "CLONE_PIDFD"}, {0x2000, "CLONE_PTRACE"},
+		{0x4000, "CLONE_VFORK"}, {0x8000, "CLONE_PARENT"}, {0x10000, "CLONE_THREAD"},
+		{0x20000, "CLONE_NEWNS"}, {0x40000, "CLONE_SYSVSEM"}, {0x80000, "CLONE_SETTLS"},
+		{0x100000, "CLONE_PARENT_SETTID"}, {0x200000, "CLONE_CHILD_CLEARTID"},
+		{0x400000, "CLONE_DETACHED"}, {0x800000, "CLONE_UNTRACED"},
+		{0x1000000, "CLONE_CHILD_SETTID"},
+	}
+	futexCmds = map[uint64]string{
+		0: "FUTEX_WAIT", 1: "FUTEX_WAKE", 9: "FUTEX_WAIT_BITSET", 10: "FUTEX_WAKE_BITSET",
+	}
+	signalNames = []string{"", "SIGHUP", "SIGINT", "SIGQUIT", "SIGILL", "SIGTRAP", "SIGABRT",
+		"SIGBUS", "SIGFPE", "SIGKILL", "SIGUSR1", "SIGSEGV", "SIGUSR2", "SIGPIPE", "SIGALRM",
+		"SIGTERM", "SIGSTKFLT", "SIGCHLD", "SIGCONT", "SIGSTOP", "SIGTSTP", "SIGTTIN",
+		"SIGTTOU", "SIGURG", "SIGXCPU", "SIGXFSZ", "SIGVTALRM", "SIGPROF", "SIGWINCH",
+		"SIGIO", "SIGPWR", "SIGSYS"}
+)
+
+// formatFlags renders v as `A|B|0x40`, listing unknown bits in hex.
+func formatFlags(v uint64, names []flagName, zero string) string {
+	if v == 0 {
+		return zero
+	}
+	var parts []string
+	for _, f := range names {
+		if v&f.bit != 0 {
+			parts = append(parts, f.name)
+			v &^= f.bit
+		}
+	}
+	if v != 0 {
+		parts = append(parts, fmt.Sprintf("%#x", v))
+	}
+	return strings.Join(parts, "|")
+}
+
+// formatCall renders a syscall the way strace does, so that emulator traces
+// can be fed to the syscall-diff and syscall-tier tools.
+func formatCall(mem Memory, num uint64, args [6]uint64) string {
+	desc, ok := sysTable[num]
+	if !ok {
+		return fmt.Sprintf("syscall_%d(%#x, %#x, %#x, %#x, %#x, %#x)", num, args[0], args[1], args[2], args[3], args[4], args[5])
+	}
+	parts := make([]string, len(desc.args))
+	for i, kind := range desc.args {
+		v := args[i]
+		switch kind {
+		case argInt:
+			parts[i] = strconv.FormatInt(int64(v), 10)
+		case argStr:
+			s, err := readString(mem, v, 4096)
+			if err != nil {
+				parts[i] = fmt.Sprintf("%#x", v)
+			} else {
+				parts[i] = strconv.Quote(s)
+			}
+		case argBuf:
+			n := args[i+1]
+			if n > 32 {
+				n = 32
+			}
+			buf := make([]byte, n)
+			if err := mem.Read(v, buf); err != nil {
+				parts[i] = fmt.Sprintf("%#x", v)
+				break
+			}
+			parts[i] = strconv.Quote(string(buf))
+			if args[i+1] > 32 {
+				parts[i] += "..."
+			}
+		case argDirFD:
+			if int32(v) == atFDCWD {
+				parts[i] = "AT_FDCWD"
+			} else {
+				parts[i] = strconv.FormatInt(int64(int32(v)), 10)
+			}
+		case argOpenFlags:
+			acc := [...]string{"O_RDONLY", "O_WRONLY", "O_RDWR", "O_ACCMODE"}[v&3]
+			if rest := formatFlags(v&^3, openFlags, ""); rest != "" {
+				acc += "|" + rest
+			}
+			parts[i] = acc
+		case argProt:
+			parts[i] = formatFlags(v, protFlags, "PROT_NONE")
+		case argMmapFlags:
+			parts[i] = formatFlags(v, mmapFlags, "0")
+		case argCloneFlags:
+			parts[i] = "flags=" + formatFlags(v&^0xff, cloneFlags, "0")
+			if sig := v & 0xff; sig != 0 {
+				parts[i] += "|" + signalName(sig)
+			}
+		case argFutexOp:
+			name, ok := futexCmds[v&0x7f]
+			if !ok {
+				name = fmt.Sprintf("%d", v&0x7f)
+			}
+			if v&128 != 0 {
+				name += "_PRIVATE"
+			}
+			if v&256 != 0 {
+				name += "|FUTEX_CLOCK_REALTIME"
+			}
+			parts[i] = name
+		case argSignal:
+			parts[i] = signalName(v)
+		default:
+			parts[i] = fmt.Sprintf("%#x", v)
+		}
+	}
+	return desc.name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func signalName(sig uint64) string {
+	if sig < uint64(len(signalNames)) && sig > 0 {
+		return signalNames[sig]
+	}
+	return fmt.Sprintf("SIG%d", sig)
+}
+
+// formatRet renders a syscall result, decoding negative errno values.
+func formatRet(num uint64, ret uint64) string {
+	if e := -int64(ret); e > 0 && e < 4096 {
+		if name, ok := errnoNames[e]; ok {
+			return "-1 " + name
+		}
+		return fmt.Sprintf("-1 errno %d", e)
+	}
+	if sysTable[num].retHex {
+		return fmt.Sprintf("%#x", ret)
+	}
+	return strconv.FormatInt(int64(ret), 10)
+}
@@ -0,0 +1,102 @@
+// Package publicvalues defines the public values stateless-exec commits to, in
+// the role of the values an SP1 or RISC0 guest commits for its verifier: a
+// commitment to the input, and the parts of the execution result a verifier
+// checks, in a fixed byte layout that needs no JSON or RLP to read.
+//
+// Hashing goes through cryptobackend.Default, like the rest of the guest.
+//
+// The layout is 136 bytes:
+//
+//	offset  size  field
+//	     0    32  input commitment, keccak256(parent state root || env hash || transactions hash)
+//	    32    32  state root
+//	    64    32  receipts root
+//	    96     8  gas used, big-endian
+//	   104    32  requests hash, zero before Prague
+package publicvalues
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/eth-act/riscv-compilation/cryptobackend"
+)
+
+// Size is the length of the encoded public values.
+const Size = 136
+
+// Values are the public values of one execution.
+type Values struct {
+	Input        common.Hash
+	StateRoot    common.Hash
+	ReceiptsRoot common.Hash
+	GasUsed      uint64
+	RequestsHash common.Hash
+}
+
+// InputCommitment commits to an execution's input: the state root of the
+// pre-state, the keccak256 hash of the block environment and the hash of the
+// transaction list from TransactionsHash.
+func InputCommitment(parentStateRoot, envHash, txsHash common.Hash) common.Hash {
+	buf := make([]byte, 0, 3*common.HashLength)
+	buf = append(buf, parentStateRoot[:]...)
+	buf = append(buf, envHash[:]...)
+	buf = append(buf, txsHash[:]...)
+	return cryptobackend.Default.Keccak256(buf)
+}
+
+// TransactionsHash hashes a transaction list given by its transaction hashes,
+// in order. Entries that could not be decoded are given as the zero hash.
+func TransactionsHash(hashes []common.Hash) common.Hash {
+	buf := make([]byte, 0, len(hashes)*common.HashLength)
+	for _, h := range hashes {
+		buf = append(buf, h[:]...)
+	}
+	return cryptobackend.Default.Keccak256(buf)
+}
+
+// Encode returns the fixed layout of v.
+func (v *Values) Encode() []byte {
+	b := make([]byte, 0, Size)
+	b = append(b, v.Input[:]...)
+	b = append(b, v.StateRoot[:]...)
+	b = append(b, v.ReceiptsRoot[:]...)
+	b = binary.BigEndian.AppendUint64(b, v.GasUsed)
+	b = append(b, v.RequestsHash[:]...)
+	return b
+}
+
+// Decode parses the fixed layout.
+func Decode(b []byte) (*Values, error) {
+	if len(b) != Size {
+		return nil, fmt.Errorf("public values are %d bytes, want %d", len(b), Size)
+	}
+	var v Values
+	copy(v.Input[:], b[0:32])
+	copy(v.StateRoot[:], b[32:64])
+	copy(v.ReceiptsRoot[:], b[64:96])
+	v.GasUsed = binary.BigEndian.Uint64(b[96:104])
+	copy(v.RequestsHash[:], b[104:136])
+	return &v, nil
+}
+
+// Diff returns a description of every field that differs between v and want,
+// or nil if they are equal.
+func (v *Values) Diff(want *Values) []string {
+	var diffs []string
+	hash := func(name string, got, want common.Hash) {
+		if got != want {
+			diffs = append(diffs, fmt.Sprintf("%s: got %s, want %s", name, got, want))
+		}
+	}
+	hash("input", v.Input, want.Input)
+	hash("stateRoot", v.StateRoot, want.StateRoot)
+	hash("receiptsRoot", v.ReceiptsRoot, want.ReceiptsRoot)
+	if v.GasUsed != want.GasUsed {
+		diffs = append(diffs, fmt.Sprintf("gasUsed: got %d, want %d", v.GasUsed, want.GasUsed))
+	}
+	hash("requestsHash", v.RequestsHash, want.RequestsHash)
+	return diffs
+}
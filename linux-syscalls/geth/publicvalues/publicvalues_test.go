@@ -0,0 +1,86 @@
+package publicvalues
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var testValues = Values{
+	Input:        common.Hash{0: 0x01, 31: 0x02},
+	StateRoot:    common.Hash{0: 0x03, 31: 0x04},
+	ReceiptsRoot: common.Hash{0: 0x05, 31: 0x06},
+	GasUsed:      0x0102030405060708,
+	RequestsHash: common.Hash{0: 0x07, 31: 0x08},
+}
+
+// The layout is a contract with verifiers, so every field is checked at its
+// offset.
+func TestEncodeLayout(t *testing.T) {
+	b := testValues.Encode()
+	if len(b) != Size {
+		t.Fatalf("encoded %d bytes, want %d", len(b), Size)
+	}
+	for _, f := range []struct {
+		name   string
+		offset int
+		want   []byte
+	}{
+		{"input", 0, testValues.Input[:]},
+		{"stateRoot", 32, testValues.StateRoot[:]},
+		{"receiptsRoot", 64, testValues.ReceiptsRoot[:]},
+		{"gasUsed", 96, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"requestsHash", 104, testValues.RequestsHash[:]},
+	} {
+		if got := b[f.offset : f.offset+len(f.want)]; !bytes.Equal(got, f.want) {
+			t.Errorf("%s at %d: %x, want %x", f.name, f.offset, got, f.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	v, err := Decode(testValues.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *v != testValues {
+		t.Errorf("decoded %+v, want %+v", *v, testValues)
+	}
+	for _, n := range []int{0, Size - 1, Size + 1} {
+		if _, err := Decode(make([]byte, n)); err == nil {
+			t.Errorf("%d bytes: no error", n)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	same := testValues
+	if diffs := testValues.Diff(&same); diffs != nil {
+		t.Errorf("equal values differ: %q", diffs)
+	}
+	other := testValues
+	other.StateRoot = common.Hash{}
+	other.GasUsed++
+	diffs := testValues.Diff(&other)
+	if len(diffs) != 2 || !strings.HasPrefix(diffs[0], "stateRoot: ") || !strings.HasPrefix(diffs[1], "gasUsed: ") {
+		t.Errorf("diffs %q, want stateRoot and gasUsed", diffs)
+	}
+}
+
+func TestInputCommitment(t *testing.T) {
+	var (
+		root = common.Hash{1}
+		env  = common.Hash{2}
+		txs  = TransactionsHash([]common.Hash{{3}, {4}})
+	)
+	if want := crypto.Keccak256Hash(common.Hash{3}.Bytes(), common.Hash{4}.Bytes()); txs != want {
+		t.Errorf("transactions hash %s, want %s", txs, want)
+	}
+	want := crypto.Keccak256Hash(root[:], env[:], txs[:])
+	if got := InputCommitment(root, env, txs); got != want {
+		t.Errorf("input commitment %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,175 @@
+package rv64
+
+// Encoders for the 32-bit instruction formats, used to expand RVC
+// instructions into their base equivalents.
+
+func encR(op, rd, f3, rs1, rs2, f7 uint32) uint32 {
+	return f7<<25 | rs2<<20 | rs1<<15 | f3<<12 | rd<<7 | op
+}
+
+func encI(op, rd, f3, rs1 uint32, imm int32) uint32 {
+	return uint32(imm)<<20 | rs1<<15 | f3<<12 | rd<<7 | op
+}
+
+func encS(op, f3, rs1, rs2 uint32, imm int32) uint32 {
+	u := uint32(imm)
+	return (u>>5&0x7f)<<25 | rs2<<20 | rs1<<15 | f3<<12 | (u&0x1f)<<7 | op
+}
+
+func encB(f3, rs1, rs2 uint32, imm int32) uint32 {
+	u := uint32(imm)
+	return (u>>12&1)<<31 | (u>>5&0x3f)<<25 | rs2<<20 | rs1<<15 | f3<<12 |
+		(u>>1&0xf)<<8 | (u>>11&1)<<7 | 0x63
+}
+
+func encJ(rd uint32, imm int32) uint32 {
+	u := uint32(imm)
+	return (u>>20&1)<<31 | (u>>1&0x3ff)<<21 | (u>>11&1)<<20 | (u>>12&0xff)<<12 | rd<<7 | 0x6f
+}
+
+// bit extracts bits [lo, lo+n) of c.
+func bit(c uint16, lo, n uint) uint32 {
+	return uint32(c>>lo) & (1<<n - 1)
+}
+
+// sext sign-extends the low n bits of v.
+func sext(v uint32, n uint) int32 {
+	return int32(v<<(32-n)) >> (32 - n)
+}
+
+// expand translates a 16-bit RV64C instruction into the equivalent 32-bit
+// instruction. It returns 0 for reserved or illegal encodings.
+func expand(c uint16) uint32 {
+	var (
+		rd  = bit(c, 7, 5)
+		rs2 = bit(c, 2, 5)
+		rdp = bit(c, 2, 3) + 8 // rd' / rs2'
+		rsp = bit(c, 7, 3) + 8 // rs1' / rd'
+	)
+	switch c&3<<3 | c>>13 {
+	// Quadrant 0
+	case 0<<3 | 0: // C.ADDI4SPN
+		imm := bit(c, 11, 2)<<4 | bit(c, 7, 4)<<6 | bit(c, 6, 1)<<2 | bit(c, 5, 1)<<3
+		if imm == 0 {
+			return 0
+		}
+		return encI(0x13, rdp, 0, 2, int32(imm))
+	case 0<<3 | 1: // C.FLD
+		return encI(0x07, rdp, 3, rsp, int32(bit(c, 10, 3)<<3|bit(c, 5, 2)<<6))
+	case 0<<3 | 2: // C.LW
+		return encI(0x03, rdp, 2, rsp, int32(bit(c, 10, 3)<<3|bit(c, 6, 1)<<2|bit(c, 5, 1)<<6))
+	case 0<<3 | 3: // C.LD
+		return encI(0x03, rdp, 3, rsp, int32(bit(c, 10, 3)<<3|bit(c, 5, 2)<<6))
+	case 0<<3 | 5: // C.FSD
+		return encS(0x27, 3, rsp, rdp, int32(bit(c, 10, 3)<<3|bit(c, 5, 2)<<6))
+	case 0<<3 | 6: // C.SW
+		return encS(0x23, 2, rsp, rdp, int32(bit(c, 10, 3)<<3|bit(c, 6, 1)<<2|bit(c, 5, 1)<<6))
+	case 0<<3 | 7: // C.SD
+		return encS(0x23, 3, rsp, rdp, int32(bit(c, 10, 3)<<3|bit(c, 5, 2)<<6))
+
+	// Quadrant 1
+	case 1<<3 | 0: // C.ADDI, C.NOP
+		return encI(0x13, rd, 0, rd, sext(bit(c, 12, 1)<<5|bit(c, 2, 5), 6))
+	case 1<<3 | 1: // C.ADDIW
+		if rd == 0 {
+			return 0
+		}
+		return encI(0x1b, rd, 0, rd, sext(bit(c, 12, 1)<<5|bit(c, 2, 5), 6))
+	case 1<<3 | 2: // C.LI
+		return encI(0x13, rd, 0, 0, sext(bit(c, 12, 1)<<5|bit(c, 2, 5), 6))
+	case 1<<3 | 3:
+		if rd == 2 { // C.ADDI16SP
+			imm := sext(bit(c, 12, 1)<<9|bit(c, 6, 1)<<4|bit(c, 5, 1)<<6|bit(c, 3, 2)<<7|bit(c, 2, 1)<<5, 10)
+			if imm == 0 {
+				return 0
+			}
+			return encI(0x13, 2, 0, 2, imm)
+		}
+		// C.LUI
+		imm := sext(bit(c, 12, 1)<<17|bit(c, 2, 5)<<12, 18)
+		if imm == 0 {
+			return 0
+		}
+		return uint32(imm)&0xfffff000 | rd<<7 | 0x37
+	case 1<<3 | 4:
+		shamt := int32(bit(c, 12, 1)<<5 | bit(c, 2, 5))
+		switch bit(c, 10, 2) {
+		case 0: // C.SRLI
+			return encI(0x13, rsp, 5, rsp, shamt)
+		case 1: // C.SRAI
+			return encI(0x13, rsp, 5, rsp, shamt|0x400)
+		case 2: // C.ANDI
+			return encI(0x13, rsp, 7, rsp, sext(uint32(shamt), 6))
+		}
+		f3 := [...]uint32{0, 4, 6, 7}[bit(c, 5, 2)]
+		if bit(c, 12, 1) == 0 { // C.SUB, C.XOR, C.OR, C.AND
+			f7 := uint32(0)
+			if f3 == 0 {
+				f7 = 0x20
+			}
+			return encR(0x33, rsp, f3, rsp, rdp, f7)
+		}
+		switch bit(c, 5, 2) {
+		case 0: // C.SUBW
+			return encR(0x3b, rsp, 0, rsp, rdp, 0x20)
+		case 1: // C.ADDW
+			return encR(0x3b, rsp, 0, rsp, rdp, 0)
+		}
+		return 0
+	case 1<<3 | 5: // C.J
+		return encJ(0, cjImm(c))
+	case 1<<3 | 6: // C.BEQZ
+		return encB(0, rsp, 0, cbImm(c))
+	case 1<<3 | 7: // C.BNEZ
+		return encB(1, rsp, 0, cbImm(c))
+
+	// Quadrant 2
+	case 2<<3 | 0: // C.SLLI
+		return encI(0x13, rd, 1, rd, int32(bit(c, 12, 1)<<5|bit(c, 2, 5)))
+	case 2<<3 | 1: // C.FLDSP
+		return encI(0x07, rd, 3, 2, int32(bit(c, 12, 1)<<5|bit(c, 5, 2)<<3|bit(c, 2, 3)<<6))
+	case 2<<3 | 2: // C.LWSP
+		if rd == 0 {
+			return 0
+		}
+		return encI(0x03, rd, 2, 2, int32(bit(c, 12, 1)<<5|bit(c, 4, 3)<<2|bit(c, 2, 2)<<6))
+	case 2<<3 | 3: // C.LDSP
+		if rd == 0 {
+			return 0
+		}
+		return encI(0x03, rd, 3, 2, int32(bit(c, 12, 1)<<5|bit(c, 5, 2)<<3|bit(c, 2, 3)<<6))
+	case 2<<3 | 4:
+		if bit(c, 12, 1) == 0 {
+			if rs2 == 0 { // C.JR
+				if rd == 0 {
+					return 0
+				}
+				return encI(0x67, 0, 0, rd, 0)
+			}
+			return encR(0x33, rd, 0, 0, rs2, 0) // C.MV
+		}
+		switch {
+		case rd == 0 && rs2 == 0: // C.EBREAK
+			return 0x00100073
+		case rs2 == 0: // C.JALR
+			return encI(0x67, 1, 0, rd, 0)
+		}
+		return encR(0x33, rd, 0, rd, rs2, 0) // C.ADD
+	case 2<<3 | 5: // C.FSDSP
+		return encS(0x27, 3, 2, rs2, int32(bit(c, 10, 3)<<3|bit(c, 7, 3)<<6))
+	case 2<<3 | 6: // C.SWSP
+		return encS(0x23, 2, 2, rs2, int32(bit(c, 9, 4)<<2|bit(c, 7, 2)<<6))
+	case 2<<3 | 7: // C.SDSP
+		return encS(0x23, 3, 2, rs2, int32(bit(c, 10, 3)<<3|bit(c, 7, 3)<<6))
+	}
+	return 0
+}
+
+func cjImm(c uint16) int32 {
+	return sext(bit(c, 12, 1)<<11|bit(c, 11, 1)<<4|bit(c, 9, 2)<<8|bit(c, 8, 1)<<10|
+		bit(c, 7, 1)<<6|bit(c, 6, 1)<<7|bit(c, 3, 3)<<1|bit(c, 2, 1)<<5, 12)
+}
+
+func cbImm(c uint16) int32 {
+	return sext(bit(c, 12, 1)<<8|bit(c, 10, 2)<<3|bit(c, 5, 2)<<6|bit(c, 3, 2)<<1|bit(c, 2, 1)<<5, 9)
+}
@@ -0,0 +1,144 @@
+package rv64
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// StackTop is the highest address of the initial thread's stack, just
+	// below the end of the Sv39 user address space.
+	StackTop  = 0x3f_ffff_f000
+	StackSize = 8 << 20
+)
+
+// Auxiliary vector entries passed to the guest.
+const (
+	atNull   = 0
+	atPhdr   = 3
+	atPhent  = 4
+	atPhnum  = 5
+	atPagesz = 6
+	atEntry  = 9
+	atUID    = 11
+	atEUID   = 12
+	atGID    = 13
+	atEGID   = 14
+	atHwcap  = 16
+	atSecure = 23
+	atRandom = 25
+	atExecfn = 31
+)
+
+// hwcapIMAFDC advertises the RV64GC extensions: one bit per letter.
+const hwcapIMAFDC = 1<<('I'-'A') | 1<<('M'-'A') | 1<<('A'-'A') | 1<<('F'-'A') | 1<<('D'-'A') | 1<<('C'-'A')
+
+// Program describes a loaded ELF image.
+type Program struct {
+	Path  string
+	Entry uint64
+	Brk   uint64 // first page after the highest loaded segment
+	phdr  uint64
+	phnum uint64
+	File  *elf.File
+}
+
+// LoadELF maps the loadable segments of a statically linked RV64 executable
+// into mem.
+func LoadELF(mem *Memory, path string, data []byte) (*Program, error) {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if f.Machine != elf.EM_RISCV || f.Class != elf.ELFCLASS64 {
+		return nil, fmt.Errorf("%s is not a RV64 executable (%v, %v)", path, f.Machine, f.Class)
+	}
+	if f.Type != elf.ET_EXEC {
+		return nil, errors.New("only statically linked executables are supported")
+	}
+	prog := &Program{Path: path, Entry: f.Entry, File: f, phnum: uint64(len(f.Progs))}
+	for _, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			return nil, errors.New("dynamically linked executables are not supported")
+		case elf.PT_PHDR:
+			prog.phdr = p.Vaddr
+		case elf.PT_LOAD:
+			mem.Map(p.Vaddr, p.Memsz)
+			buf := make([]byte, p.Filesz)
+			if _, err := io.ReadFull(p.Open(), buf); err != nil {
+				return nil, fmt.Errorf("could not read segment at %#x: %v", p.Vaddr, err)
+			}
+			if err := mem.Write(p.Vaddr, buf); err != nil {
+				return nil, err
+			}
+			if end := (p.Vaddr + p.Memsz + pageMask) &^ pageMask; end > prog.Brk {
+				prog.Brk = end
+			}
+			if p.Off == 0 && prog.phdr == 0 {
+				prog.phdr = p.Vaddr + binary.LittleEndian.Uint64(data[32:40])
+			}
+		}
+	}
+	return prog, nil
+}
+
+// SetupStack maps the initial stack and lays out argc, argv, envp and the
+// auxiliary vector the way the Linux kernel does. It returns the initial
+// stack pointer.
+func SetupStack(mem *Memory, prog *Program, argv, envp []string, random [16]byte) (uint64, error) {
+	mem.Map(StackTop-StackSize, StackSize)
+
+	sp := uint64(StackTop)
+	push := func(b []byte) uint64 {
+		sp -= uint64(len(b))
+		mem.Write(sp, b)
+		return sp
+	}
+	pushString := func(s string) uint64 {
+		return push(append([]byte(s), 0))
+	}
+	randomAddr := push(random[:])
+	execfn := pushString(prog.Path)
+	var argvAddrs, envpAddrs []uint64
+	for _, s := range argv {
+		argvAddrs = append(argvAddrs, pushString(s))
+	}
+	for _, s := range envp {
+		envpAddrs = append(envpAddrs, pushString(s))
+	}
+	auxv := []uint64{
+		atPhdr, prog.phdr,
+		atPhent, 56,
+		atPhnum, prog.phnum,
+		atPagesz, pageSize,
+		atEntry, prog.Entry,
+		atUID, 0, atEUID, 0, atGID, 0, atEGID, 0,
+		atHwcap, hwcapIMAFDC,
+		atSecure, 0,
+		atRandom, randomAddr,
+		atExecfn, execfn,
+		atNull, 0,
+	}
+	var words []uint64
+	words = append(words, uint64(len(argv)))
+	words = append(words, argvAddrs...)
+	words = append(words, 0)
+	words = append(words, envpAddrs...)
+	words = append(words, 0)
+	words = append(words, auxv...)
+
+	sp = (sp - uint64(len(words))*8) &^ 15
+	buf := make([]byte, len(words)*8)
+	for i, w := range words {
+		binary.LittleEndian.PutUint64(buf[i*8:], w)
+	}
+	if err := mem.Write(sp, buf); err != nil {
+		return 0, err
+	}
+	return sp, nil
+}
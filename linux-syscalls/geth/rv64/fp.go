@@ -0,0 +1,383 @@
+package rv64
+
+import (
+	"math"
+)
+
+const (
+	canonicalNaN32 = 0x7fc00000
+	canonicalNaN64 = 0x7ff8000000000000
+	nanBox         = 0xffffffff00000000
+
+	fflagNV = 0x10 // invalid operation
+)
+
+// Rounding modes
+const (
+	rmRNE = 0
+	rmRTZ = 1
+	rmRDN = 2
+	rmRUP = 3
+	rmRMM = 4
+	rmDYN = 7
+)
+
+func (h *Hart) getS(r uint32) float32 {
+	v := h.F[r]
+	if v&nanBox != nanBox {
+		return math.Float32frombits(canonicalNaN32)
+	}
+	return math.Float32frombits(uint32(v))
+}
+
+func (h *Hart) setS(r uint32, f float32) {
+	bits := math.Float32bits(f)
+	if f != f {
+		bits = canonicalNaN32
+	}
+	h.F[r] = nanBox | uint64(bits)
+}
+
+func (h *Hart) getD(r uint32) float64 { return math.Float64frombits(h.F[r]) }
+
+func (h *Hart) setD(r uint32, f float64) {
+	if f != f {
+		h.F[r] = canonicalNaN64
+		return
+	}
+	h.F[r] = math.Float64bits(f)
+}
+
+// fp executes the F and D extension instructions. Arithmetic always rounds to
+// nearest-even; the rounding mode is honoured for float-to-integer
+// conversions, which is where the Go compiler relies on it.
+func (m *Machine) fp(h *Hart, inst uint32, pc uint64) error {
+	var (
+		rd     = (inst >> 7) & 31
+		rs1    = (inst >> 15) & 31
+		rs2    = (inst >> 20) & 31
+		funct3 = (inst >> 12) & 7
+		funct7 = inst >> 25
+		x      = &h.X
+	)
+	switch inst & 0x7f {
+	case 0x07: // FLW, FLD
+		addr := x[rs1] + uint64(immI(inst))
+		switch funct3 {
+		case 2:
+			v, err := m.Mem.Load(addr, 4)
+			if err != nil {
+				return err
+			}
+			h.F[rd] = nanBox | v
+		case 3:
+			v, err := m.Mem.Load(addr, 8)
+			if err != nil {
+				return err
+			}
+			h.F[rd] = v
+		default:
+			return &IllegalError{pc, inst}
+		}
+		return nil
+	case 0x27: // FSW, FSD
+		addr := x[rs1] + uint64(immS(inst))
+		switch funct3 {
+		case 2:
+			return m.Mem.Store(addr, 4, h.F[rs2])
+		case 3:
+			return m.Mem.Store(addr, 8, h.F[rs2])
+		}
+		return &IllegalError{pc, inst}
+	case 0x43, 0x47, 0x4b, 0x4f: // FMADD, FMSUB, FNMSUB, FNMADD
+		rs3 := inst >> 27
+		negProd, negAdd := false, false
+		switch inst & 0x7f {
+		case 0x47:
+			negAdd = true
+		case 0x4b:
+			negProd = true
+		case 0x4f:
+			negProd, negAdd = true, true
+		}
+		switch funct7 & 3 {
+		case 0:
+			a, b, c := float64(h.getS(rs1)), float64(h.getS(rs2)), float64(h.getS(rs3))
+			if negProd {
+				a = -a
+			}
+			if negAdd {
+				c = -c
+			}
+			h.setS(rd, float32(math.FMA(a, b, c)))
+		case 1:
+			a, b, c := h.getD(rs1), h.getD(rs2), h.getD(rs3)
+			if negProd {
+				a = -a
+			}
+			if negAdd {
+				c = -c
+			}
+			h.setD(rd, math.FMA(a, b, c))
+		default:
+			return &IllegalError{pc, inst}
+		}
+		return nil
+	}
+	// OP-FP
+	rm := funct3
+	if rm == rmDYN {
+		rm = (h.FCSR >> 5) & 7
+	}
+	switch funct7 {
+	case 0x00:
+		h.setS(rd, h.getS(rs1)+h.getS(rs2))
+	case 0x01:
+		h.setD(rd, h.getD(rs1)+h.getD(rs2))
+	case 0x04:
+		h.setS(rd, h.getS(rs1)-h.getS(rs2))
+	case 0x05:
+		h.setD(rd, h.getD(rs1)-h.getD(rs2))
+	case 0x08:
+		h.setS(rd, h.getS(rs1)*h.getS(rs2))
+	case 0x09:
+		h.setD(rd, h.getD(rs1)*h.getD(rs2))
+	case 0x0c:
+		h.setS(rd, h.getS(rs1)/h.getS(rs2))
+	case 0x0d:
+		h.setD(rd, h.getD(rs1)/h.getD(rs2))
+	case 0x2c:
+		h.setS(rd, float32(math.Sqrt(float64(h.getS(rs1)))))
+	case 0x2d:
+		h.setD(rd, math.Sqrt(h.getD(rs1)))
+	case 0x10: // FSGNJ.S
+		a, b := uint32(h.F[rs1]), uint32(h.F[rs2])
+		if h.F[rs1]&nanBox != nanBox {
+			a = canonicalNaN32
+		}
+		if h.F[rs2]&nanBox != nanBox {
+			b = canonicalNaN32
+		}
+		const sign = 1 << 31
+		switch funct3 {
+		case 0:
+			a = a&^sign | b&sign
+		case 1:
+			a = a&^sign | ^b&sign
+		case 2:
+			a ^= b & sign
+		default:
+			return &IllegalError{pc, inst}
+		}
+		h.F[rd] = nanBox | uint64(a)
+	case 0x11: // FSGNJ.D
+		a, b := h.F[rs1], h.F[rs2]
+		const sign = 1 << 63
+		switch funct3 {
+		case 0:
+			a = a&^sign | b&sign
+		case 1:
+			a = a&^sign | ^b&sign
+		case 2:
+			a ^= b & sign
+		default:
+			return &IllegalError{pc, inst}
+		}
+		h.F[rd] = a
+	case 0x14: // FMIN.S, FMAX.S
+		h.setS(rd, float32(fminmax(float64(h.getS(rs1)), float64(h.getS(rs2)), funct3 == 1)))
+	case 0x15: // FMIN.D, FMAX.D
+		h.setD(rd, fminmax(h.getD(rs1), h.getD(rs2), funct3 == 1))
+	case 0x20: // FCVT.S.D
+		h.setS(rd, float32(h.getD(rs1)))
+	case 0x21: // FCVT.D.S
+		h.setD(rd, float64(h.getS(rs1)))
+	case 0x50, 0x51: // FLE, FLT, FEQ
+		var a, b float64
+		if funct7 == 0x50 {
+			a, b = float64(h.getS(rs1)), float64(h.getS(rs2))
+		} else {
+			a, b = h.getD(rs1), h.getD(rs2)
+		}
+		switch funct3 {
+		case 0:
+			x[rd] = b2u(a <= b)
+		case 1:
+			x[rd] = b2u(a < b)
+		case 2:
+			x[rd] = b2u(a == b)
+		default:
+			return &IllegalError{pc, inst}
+		}
+	case 0x60, 0x61: // FCVT.{W,WU,L,LU}.{S,D}
+		var f float64
+		if funct7 == 0x60 {
+			f = float64(h.getS(rs1))
+		} else {
+			f = h.getD(rs1)
+		}
+		v, invalid := fcvtInt(f, rs2, rm)
+		if invalid {
+			h.FCSR |= fflagNV
+		}
+		x[rd] = v
+	case 0x68, 0x69: // FCVT.{S,D}.{W,WU,L,LU}
+		var f float64
+		single := funct7 == 0x68
+		switch rs2 {
+		case 0:
+			f = float64(int32(x[rs1]))
+		case 1:
+			f = float64(uint32(x[rs1]))
+		case 2:
+			if single {
+				h.setS(rd, float32(int64(x[rs1])))
+				break
+			}
+			f = float64(int64(x[rs1]))
+		case 3:
+			if single {
+				h.setS(rd, float32(x[rs1]))
+				break
+			}
+			f = float64(x[rs1])
+		default:
+			return &IllegalError{pc, inst}
+		}
+		if single && rs2 < 2 {
+			h.setS(rd, float32(f))
+		} else if !single {
+			h.setD(rd, f)
+		}
+	case 0x70: // FMV.X.W, FCLASS.S
+		switch funct3 {
+		case 0:
+			x[rd] = sext32(uint32(h.F[rs1]))
+		case 1:
+			f := h.getS(rs1)
+			x[rd] = fclass(float64(f), math.Float32bits(f)&(1<<22) == 0, 0x1p-126)
+		default:
+			return &IllegalError{pc, inst}
+		}
+	case 0x71: // FMV.X.D, FCLASS.D
+		switch funct3 {
+		case 0:
+			x[rd] = h.F[rs1]
+		case 1:
+			x[rd] = fclass(h.getD(rs1), h.F[rs1]&(1<<51) == 0, 0x1p-1022)
+		default:
+			return &IllegalError{pc, inst}
+		}
+	case 0x78: // FMV.W.X
+		h.F[rd] = nanBox | uint64(uint32(x[rs1]))
+	case 0x79: // FMV.D.X
+		h.F[rd] = x[rs1]
+	default:
+		return &IllegalError{pc, inst}
+	}
+	x[0] = 0
+	return nil
+}
+
+// fminmax implements FMIN/FMAX: a single NaN operand is ignored and -0 is
+// smaller than +0.
+func fminmax(a, b float64, max bool) float64 {
+	switch {
+	case a != a && b != b:
+		return math.NaN()
+	case a != a:
+		return b
+	case b != b:
+		return a
+	case a == b:
+		if max == math.Signbit(a) {
+			return b
+		}
+		return a
+	case max == (a > b):
+		return a
+	}
+	return b
+}
+
+// fcvtInt converts f to the integer type selected by kind (0=W, 1=WU, 2=L,
+// 3=LU) with rounding mode rm, saturating like the hardware does. The result
+// is sign-extended to 64 bits.
+func fcvtInt(f float64, kind, rm uint32) (uint64, bool) {
+	if f != f {
+		return [...]uint64{math.MaxInt32, ^uint64(0), math.MaxInt64, ^uint64(0)}[kind&3], true
+	}
+	switch rm {
+	case rmRTZ:
+		f = math.Trunc(f)
+	case rmRDN:
+		f = math.Floor(f)
+	case rmRUP:
+		f = math.Ceil(f)
+	case rmRMM:
+		f = math.Round(f)
+	default:
+		f = math.RoundToEven(f)
+	}
+	switch kind {
+	case 0:
+		switch {
+		case f > math.MaxInt32:
+			return math.MaxInt32, true
+		case f < math.MinInt32:
+			return sext32(1 << 31), true
+		}
+		return uint64(int64(f)), false
+	case 1:
+		switch {
+		case f > math.MaxUint32:
+			return ^uint64(0), true
+		case f < 0:
+			return 0, true
+		}
+		return sext32(uint32(f)), false
+	case 2:
+		switch {
+		case f >= 1<<63:
+			return math.MaxInt64, true
+		case f < -(1 << 63):
+			return 1 << 63, true
+		}
+		return uint64(int64(f)), false
+	default:
+		switch {
+		case f >= 1<<64:
+			return ^uint64(0), true
+		case f < 0:
+			return 0, true
+		}
+		return uint64(f), false
+	}
+}
+
+// fclass returns the FCLASS bit mask of f. signaling tells the NaN kinds
+// apart and minNormal is the smallest normal value of the source format.
+func fclass(f float64, signaling bool, minNormal float64) uint64 {
+	switch {
+	case f != f:
+		if signaling {
+			return 1 << 8
+		}
+		return 1 << 9
+	case math.IsInf(f, -1):
+		return 1 << 0
+	case math.IsInf(f, 1):
+		return 1 << 7
+	case f == 0 && math.Signbit(f):
+		return 1 << 3
+	case f == 0:
+		return 1 << 4
+	case f < 0 && f > -minNormal:
+		return 1 << 2
+	case f > 0 && f < minNormal:
+		return 1 << 5
+	case f < 0:
+		return 1 << 1
+	}
+	return 1 << 6
+}
@@ -0,0 +1,546 @@
+package rv64
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// Register ABI names used by the kernel interface.
+const (
+	RegRA = 1
+	RegSP = 2
+	RegTP = 4
+	RegA0 = 10
+	RegA7 = 17
+	RegG  = 27 // s11, which holds the running goroutine in Go code
+)
+
+// Hart is the architectural state of one hardware thread. Every guest thread
+// created with clone gets its own hart; they share the machine's memory.
+type Hart struct {
+	X    [32]uint64 // integer registers, X[0] is always zero
+	F    [32]uint64 // floating-point registers, single values are NaN-boxed
+	PC   uint64
+	FCSR uint32
+
+	// LR/SC reservation
+	resValid bool
+	resAddr  uint64
+}
+
+// ErrIllegal is returned for instructions the emulator does not implement.
+var ErrIllegal = errors.New("illegal instruction")
+
+// errEcall and errEbreak make step hand control back to the machine.
+var (
+	errEcall  = errors.New("ecall")
+	errEbreak = errors.New("ebreak")
+)
+
+// IllegalError reports an undecodable instruction.
+type IllegalError struct {
+	PC   uint64
+	Inst uint32
+}
+
+func (e *IllegalError) Error() string {
+	return fmt.Sprintf("illegal instruction %#08x at pc %#x", e.Inst, e.PC)
+}
+
+func (e *IllegalError) Unwrap() error { return ErrIllegal }
+
+// step executes a single instruction of h. It returns errEcall or errEbreak
+// with the PC already advanced past the instruction.
+func (m *Machine) step(h *Hart) error {
+	pc := h.PC
+	lo, err := m.Mem.fetch(pc)
+	if err != nil {
+		return err
+	}
+	var (
+		inst uint32
+		next uint64
+	)
+	if lo&3 == 3 {
+		hi, err := m.Mem.fetch(pc + 2)
+		if err != nil {
+			return err
+		}
+		inst, next = lo|hi<<16, pc+4
+	} else {
+		if inst = expand(uint16(lo)); inst == 0 {
+			return &IllegalError{pc, lo}
+		}
+		next = pc + 2
+	}
+	x := &h.X
+	rd := (inst >> 7) & 31
+	rs1 := (inst >> 15) & 31
+	rs2 := (inst >> 20) & 31
+	funct3 := (inst >> 12) & 7
+	funct7 := inst >> 25
+
+	switch inst & 0x7f {
+	case 0x37: // LUI
+		x[rd] = uint64(int64(int32(inst & 0xfffff000)))
+	case 0x17: // AUIPC
+		x[rd] = pc + uint64(int64(int32(inst&0xfffff000)))
+	case 0x6f: // JAL
+		x[rd] = next
+		next = pc + uint64(immJ(inst))
+	case 0x67: // JALR
+		target := (x[rs1] + uint64(immI(inst))) &^ 1
+		x[rd] = next
+		next = target
+	case 0x63: // BRANCH
+		a, b := x[rs1], x[rs2]
+		var taken bool
+		switch funct3 {
+		case 0:
+			taken = a == b
+		case 1:
+			taken = a != b
+		case 4:
+			taken = int64(a) < int64(b)
+		case 5:
+			taken = int64(a) >= int64(b)
+		case 6:
+			taken = a < b
+		case 7:
+			taken = a >= b
+		default:
+			return &IllegalError{pc, inst}
+		}
+		if taken {
+			next = pc + uint64(immB(inst))
+		}
+	case 0x03: // LOAD
+		addr := x[rs1] + uint64(immI(inst))
+		var v uint64
+		switch funct3 {
+		case 0:
+			v, err = m.Mem.Load(addr, 1)
+			v = uint64(int64(int8(v)))
+		case 1:
+			v, err = m.Mem.Load(addr, 2)
+			v = uint64(int64(int16(v)))
+		case 2:
+			v, err = m.Mem.Load(addr, 4)
+			v = uint64(int64(int32(v)))
+		case 3:
+			v, err = m.Mem.Load(addr, 8)
+		case 4:
+			v, err = m.Mem.Load(addr, 1)
+		case 5:
+			v, err = m.Mem.Load(addr, 2)
+		case 6:
+			v, err = m.Mem.Load(addr, 4)
+		default:
+			return &IllegalError{pc, inst}
+		}
+		if err != nil {
+			return err
+		}
+		x[rd] = v
+	case 0x23: // STORE
+		if funct3 > 3 {
+			return &IllegalError{pc, inst}
+		}
+		if err := m.Mem.Store(x[rs1]+uint64(immS(inst)), 1<<funct3, x[rs2]); err != nil {
+			return err
+		}
+	case 0x13: // OP-IMM
+		a, imm := x[rs1], uint64(immI(inst))
+		switch funct3 {
+		case 0:
+			x[rd] = a + imm
+		case 1:
+			x[rd] = a << (imm & 63)
+		case 2:
+			x[rd] = b2u(int64(a) < int64(imm))
+		case 3:
+			x[rd] = b2u(a < imm)
+		case 4:
+			x[rd] = a ^ imm
+		case 5:
+			if inst>>26 == 0x10 {
+				x[rd] = uint64(int64(a) >> (imm & 63))
+			} else {
+				x[rd] = a >> (imm & 63)
+			}
+		case 6:
+			x[rd] = a | imm
+		case 7:
+			x[rd] = a & imm
+		}
+	case 0x1b: // OP-IMM-32
+		a, imm := uint32(x[rs1]), uint32(immI(inst))
+		switch funct3 {
+		case 0:
+			x[rd] = sext32(a + imm)
+		case 1:
+			x[rd] = sext32(a << (imm & 31))
+		case 5:
+			if funct7 == 0x20 {
+				x[rd] = uint64(int64(int32(a) >> (imm & 31)))
+			} else {
+				x[rd] = sext32(a >> (imm & 31))
+			}
+		default:
+			return &IllegalError{pc, inst}
+		}
+	case 0x33: // OP
+		a, b := x[rs1], x[rs2]
+		switch funct7 {
+		case 0x00:
+			switch funct3 {
+			case 0:
+				x[rd] = a + b
+			case 1:
+				x[rd] = a << (b & 63)
+			case 2:
+				x[rd] = b2u(int64(a) < int64(b))
+			case 3:
+				x[rd] = b2u(a < b)
+			case 4:
+				x[rd] = a ^ b
+			case 5:
+				x[rd] = a >> (b & 63)
+			case 6:
+				x[rd] = a | b
+			case 7:
+				x[rd] = a & b
+			}
+		case 0x20:
+			switch funct3 {
+			case 0:
+				x[rd] = a - b
+			case 5:
+				x[rd] = uint64(int64(a) >> (b & 63))
+			default:
+				return &IllegalError{pc, inst}
+			}
+		case 0x01:
+			x[rd] = mulDiv(funct3, a, b)
+		default:
+			return &IllegalError{pc, inst}
+		}
+	case 0x3b: // OP-32
+		a, b := uint32(x[rs1]), uint32(x[rs2])
+		switch funct7 {
+		case 0x00:
+			switch funct3 {
+			case 0:
+				x[rd] = sext32(a + b)
+			case 1:
+				x[rd] = sext32(a << (b & 31))
+			case 5:
+				x[rd] = sext32(a >> (b & 31))
+			default:
+				return &IllegalError{pc, inst}
+			}
+		case 0x20:
+			switch funct3 {
+			case 0:
+				x[rd] = sext32(a - b)
+			case 5:
+				x[rd] = uint64(int64(int32(a) >> (b & 31)))
+			default:
+				return &IllegalError{pc, inst}
+			}
+		case 0x01:
+			v, ok := mulDivW(funct3, a, b)
+			if !ok {
+				return &IllegalError{pc, inst}
+			}
+			x[rd] = v
+		default:
+			return &IllegalError{pc, inst}
+		}
+	case 0x0f: // MISC-MEM: FENCE, FENCE.I
+	case 0x73: // SYSTEM
+		if funct3 == 0 {
+			h.PC = next
+			switch inst >> 20 {
+			case 0:
+				return errEcall
+			case 1:
+				return errEbreak
+			}
+			h.PC = pc
+			return &IllegalError{pc, inst}
+		}
+		if err := m.csr(h, inst, rd, rs1, funct3); err != nil {
+			return err
+		}
+	case 0x2f: // AMO
+		if err := m.amo(h, inst, rd, rs1, rs2, funct3); err != nil {
+			return err
+		}
+	case 0x07, 0x27, 0x43, 0x47, 0x4b, 0x4f, 0x53: // F and D extensions
+		if err := m.fp(h, inst, pc); err != nil {
+			return err
+		}
+	default:
+		return &IllegalError{pc, inst}
+	}
+	x[0] = 0
+	h.PC = next
+	return nil
+}
+
+// CSR numbers
+const (
+	csrFflags  = 0x001
+	csrFrm     = 0x002
+	csrFcsr    = 0x003
+	csrCycle   = 0xc00
+	csrTime    = 0xc01
+	csrInstret = 0xc02
+)
+
+func (m *Machine) csr(h *Hart, inst, rd, rs1, funct3 uint32) error {
+	var old uint64
+	num := inst >> 20
+	switch num {
+	case csrFflags:
+		old = uint64(h.FCSR & 0x1f)
+	case csrFrm:
+		old = uint64(h.FCSR>>5) & 7
+	case csrFcsr:
+		old = uint64(h.FCSR & 0xff)
+	case csrCycle, csrInstret, csrTime:
+		// Time is the retired instruction count, which keeps runs
+		// reproducible.
+		old = m.Instret
+	default:
+		return &IllegalError{h.PC, inst}
+	}
+	src := h.X[rs1]
+	if funct3 >= 5 {
+		src = uint64(rs1)
+	}
+	var v uint64
+	switch funct3 & 3 {
+	case 1:
+		v = src
+	case 2:
+		v = old | src
+	case 3:
+		v = old &^ src
+	}
+	write := funct3&3 == 1 || rs1 != 0
+	if write {
+		switch num {
+		case csrFflags:
+			h.FCSR = h.FCSR&^0x1f | uint32(v&0x1f)
+		case csrFrm:
+			h.FCSR = h.FCSR&^0xe0 | uint32(v&7)<<5
+		case csrFcsr:
+			h.FCSR = uint32(v & 0xff)
+		default:
+			return &IllegalError{h.PC, inst}
+		}
+	}
+	h.X[rd] = old
+	return nil
+}
+
+func (m *Machine) amo(h *Hart, inst, rd, rs1, rs2, funct3 uint32) error {
+	var size int
+	switch funct3 {
+	case 2:
+		size = 4
+	case 3:
+		size = 8
+	default:
+		return &IllegalError{h.PC, inst}
+	}
+	addr := h.X[rs1]
+	if addr&uint64(size-1) != 0 {
+		return fmt.Errorf("misaligned atomic access at %#x (pc %#x)", addr, h.PC)
+	}
+	extend := func(v uint64) uint64 {
+		if size == 4 {
+			return uint64(int64(int32(v)))
+		}
+		return v
+	}
+	funct5 := inst >> 27
+	switch funct5 {
+	case 0x02: // LR
+		v, err := m.Mem.Load(addr, size)
+		if err != nil {
+			return err
+		}
+		h.resValid, h.resAddr = true, addr
+		h.X[rd] = extend(v)
+		return nil
+	case 0x03: // SC
+		if h.resValid && h.resAddr == addr {
+			if err := m.Mem.Store(addr, size, h.X[rs2]); err != nil {
+				return err
+			}
+			h.X[rd] = 0
+		} else {
+			h.X[rd] = 1
+		}
+		h.resValid = false
+		return nil
+	}
+	old, err := m.Mem.Load(addr, size)
+	if err != nil {
+		return err
+	}
+	a, b := extend(old), extend(h.X[rs2])
+	var v uint64
+	switch funct5 {
+	case 0x01:
+		v = b
+	case 0x00:
+		v = a + b
+	case 0x04:
+		v = a ^ b
+	case 0x0c:
+		v = a & b
+	case 0x08:
+		v = a | b
+	case 0x10:
+		v = a
+		if int64(b) < int64(a) {
+			v = b
+		}
+	case 0x14:
+		v = a
+		if int64(b) > int64(a) {
+			v = b
+		}
+	case 0x18, 0x1c:
+		ua, ub := a, b
+		if size == 4 {
+			ua, ub = uint64(uint32(a)), uint64(uint32(b))
+		}
+		v = a
+		if (funct5 == 0x18 && ub < ua) || (funct5 == 0x1c && ub > ua) {
+			v = b
+		}
+	default:
+		return &IllegalError{h.PC, inst}
+	}
+	if err := m.Mem.Store(addr, size, v); err != nil {
+		return err
+	}
+	h.X[rd] = a
+	return nil
+}
+
+func mulDiv(funct3 uint32, a, b uint64) uint64 {
+	switch funct3 {
+	case 0: // MUL
+		return a * b
+	case 1: // MULH
+		hi, _ := bits.Mul64(a, b)
+		if int64(a) < 0 {
+			hi -= b
+		}
+		if int64(b) < 0 {
+			hi -= a
+		}
+		return hi
+	case 2: // MULHSU
+		hi, _ := bits.Mul64(a, b)
+		if int64(a) < 0 {
+			hi -= b
+		}
+		return hi
+	case 3: // MULHU
+		hi, _ := bits.Mul64(a, b)
+		return hi
+	case 4: // DIV
+		switch {
+		case b == 0:
+			return ^uint64(0)
+		case int64(a) == -1<<63 && int64(b) == -1:
+			return a
+		}
+		return uint64(int64(a) / int64(b))
+	case 5: // DIVU
+		if b == 0 {
+			return ^uint64(0)
+		}
+		return a / b
+	case 6: // REM
+		switch {
+		case b == 0:
+			return a
+		case int64(a) == -1<<63 && int64(b) == -1:
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	default: // REMU
+		if b == 0 {
+			return a
+		}
+		return a % b
+	}
+}
+
+func mulDivW(funct3 uint32, a, b uint32) (uint64, bool) {
+	switch funct3 {
+	case 0: // MULW
+		return sext32(a * b), true
+	case 4: // DIVW
+		switch {
+		case b == 0:
+			return ^uint64(0), true
+		case int32(a) == -1<<31 && int32(b) == -1:
+			return sext32(a), true
+		}
+		return uint64(int64(int32(a) / int32(b))), true
+	case 5: // DIVUW
+		if b == 0 {
+			return ^uint64(0), true
+		}
+		return sext32(a / b), true
+	case 6: // REMW
+		switch {
+		case b == 0:
+			return sext32(a), true
+		case int32(a) == -1<<31 && int32(b) == -1:
+			return 0, true
+		}
+		return uint64(int64(int32(a) % int32(b))), true
+	case 7: // REMUW
+		if b == 0 {
+			return sext32(a), true
+		}
+		return sext32(a % b), true
+	}
+	return 0, false
+}
+
+func immI(inst uint32) int64 { return int64(int32(inst)) >> 20 }
+
+func immS(inst uint32) int64 {
+	return int64(int32(inst))>>25<<5 | int64((inst>>7)&0x1f)
+}
+
+func immB(inst uint32) int64 {
+	return int64(int32(inst))>>31<<12 | int64((inst>>7)&1)<<11 |
+		int64((inst>>25)&0x3f)<<5 | int64((inst>>8)&0xf)<<1
+}
+
+func immJ(inst uint32) int64 {
+	return int64(int32(inst))>>31<<20 | int64((inst>>12)&0xff)<<12 |
+		int64((inst>>20)&1)<<11 | int64((inst>>21)&0x3ff)<<1
+}
+
+func sext32(v uint32) uint64 { return uint64(int64(int32(v))) }
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,175 @@
+package rv64
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// InitProfiler measures what package initialisation costs before main.main
+// runs: the instructions retired and the heap allocations made inside each
+// package's init functions, including the functions they call. Set it as
+// Machine.Init before calling Run.
+//
+// The init functions are the compiler's pkg.init, the pkg.init.N functions
+// written as func init and the pkg.map.init.N functions that build map
+// literals. They are tracked on a shadow call stack per goroutine; allocations
+// are counted at the entry of runtime.mallocgc.
+type InitProfiler struct {
+	entries  map[uint64]string // init function entry -> package
+	mallocgc uint64
+	main     uint64
+	stacks   *shadowStacks
+	costs    map[string]*InitCost
+
+	// BeforeMain is the number of instructions retired before main.main was
+	// entered, including the runtime's own start-up.
+	BeforeMain uint64
+}
+
+// InitCost is the initialisation cost of one package.
+type InitCost struct {
+	Package      string
+	Instructions uint64
+	Allocs       uint64
+	Bytes        uint64
+}
+
+// NewInitProfiler finds the init functions of prog.
+func NewInitProfiler(prog *Program) (*InitProfiler, error) {
+	syms, err := prog.File.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	p := &InitProfiler{
+		entries: make(map[uint64]string),
+		stacks:  newShadowStacks(syms),
+		costs:   make(map[string]*InitCost),
+	}
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) != elf.STT_FUNC || s.Value == 0 {
+			continue
+		}
+		switch s.Name {
+		case "runtime.mallocgc":
+			p.mallocgc = s.Value
+		case "main.main":
+			p.main = s.Value
+		default:
+			if pkg := initPackage(s.Name); pkg != "" {
+				p.entries[s.Value] = pkg
+			}
+		}
+	}
+	if p.main == 0 {
+		return nil, errors.New("program has no main.main")
+	}
+	return p, nil
+}
+
+// initPackage returns the package of an init function, or "" if sym is not
+// one.
+func initPackage(sym string) string {
+	name := sym
+	if i := strings.LastIndexByte(name, '.'); i >= 0 && isDigits(name[i+1:]) {
+		name = name[:i]
+	}
+	pkg, ok := strings.CutSuffix(name, ".map.init")
+	if !ok {
+		pkg, ok = strings.CutSuffix(name, ".init")
+	}
+	// Methods named init are not package initialisers.
+	if !ok || strings.ContainsAny(pkg, "()") {
+		return ""
+	}
+	return pkg
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// step is called before h executes the instruction at its PC, with the number
+// of instructions retired so far.
+func (p *InitProfiler) step(h *Hart, instret uint64) {
+	if p.BeforeMain != 0 {
+		return
+	}
+	pc := h.PC
+	if pc == p.main {
+		p.BeforeMain = instret
+		return
+	}
+	stack := p.stacks.unwind(h)
+	if pkg, ok := p.entries[pc]; ok {
+		p.stacks.enter(h, 0, pkg)
+		stack = p.stacks.stack(h)
+	}
+	if len(stack) == 0 {
+		return
+	}
+	c := p.cost(stack[len(stack)-1].label)
+	c.Instructions++
+	if pc == p.mallocgc {
+		// func mallocgc(size uintptr, typ *_type, needzero bool): a0 = size
+		c.Allocs++
+		c.Bytes += h.X[RegA0]
+	}
+}
+
+func (p *InitProfiler) cost(pkg string) *InitCost {
+	c := p.costs[pkg]
+	if c == nil {
+		c = &InitCost{Package: pkg}
+		p.costs[pkg] = c
+	}
+	return c
+}
+
+// Costs returns the cost of every package that ran init code, highest
+// instruction count first.
+func (p *InitProfiler) Costs() []InitCost {
+	out := make([]InitCost, 0, len(p.costs))
+	for _, c := range p.costs {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Instructions != out[j].Instructions {
+			return out[i].Instructions > out[j].Instructions
+		}
+		return out[i].Package < out[j].Package
+	})
+	return out
+}
+
+// WriteInitReport writes the top costs, or all of them if top <= 0.
+func WriteInitReport(w io.Writer, p *InitProfiler, top int) {
+	costs := p.Costs()
+	var total InitCost
+	for _, c := range costs {
+		total.Instructions += c.Instructions
+		total.Allocs += c.Allocs
+		total.Bytes += c.Bytes
+	}
+	fmt.Fprintf(w, "before main.main: %d instructions, %d in init functions of %d packages (%d allocations, %d bytes)\n",
+		p.BeforeMain, total.Instructions, len(costs), total.Allocs, total.Bytes)
+	if top > 0 && top < len(costs) {
+		costs = costs[:top]
+	}
+	fmt.Fprintf(w, "%12s %7s %8s %10s  %s\n", "instructions", "share", "allocs", "bytes", "package")
+	for _, c := range costs {
+		share := 0.0
+		if total.Instructions > 0 {
+			share = 100 * float64(c.Instructions) / float64(total.Instructions)
+		}
+		fmt.Fprintf(w, "%12d %6.2f%% %8d %10d  %s\n", c.Instructions, share, c.Allocs, c.Bytes, c.Package)
+	}
+}
@@ -0,0 +1,249 @@
+package rv64
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/eth-act/riscv-compilation/cryptobackend"
+)
+
+// KeccakCounter counts keccak256 hashes and the bytes fed to them, split by
+// what was being hashed (the source) and by the step of the program that ran
+// at the time (the phase). Set it as Machine.Keccak before calling Run.
+//
+// The counts are taken at the entry of the golang.org/x/crypto/sha3 methods
+// every keccak256 in go-ethereum goes through: Write adds its length to the
+// bytes hashed and Read, which finishes a hash, adds a call. Builds with the
+// ECALL crypto backend hash on the host instead, and each keccak256 ECALL
+// adds a call and its input length. The source and phase are those of the
+// innermost marker functions on a shadow call stack of the goroutine,
+// maintained from the entries and return addresses of the marker functions.
+type KeccakCounter struct {
+	entries map[uint64]keccakEntry
+	markers []keccakMarker
+	stacks  *shadowStacks
+	counts  map[[2]string]*KeccakCount
+}
+
+// KeccakCount is the work done for one source in one phase. ECalls is the
+// part of Calls made through the ECALL backend.
+type KeccakCount struct {
+	Phase, Source string
+	Calls, Bytes  uint64
+	ECalls        uint64
+}
+
+type keccakEntry struct {
+	kind   int
+	marker int // index in markers
+}
+
+// keccakMarker is what a marker function marks: a phase, a source or both.
+type keccakMarker struct {
+	phase, source string
+}
+
+const (
+	keccakWrite = iota
+	keccakRead
+	keccakMark
+)
+
+type keccakPrefixes []struct {
+	name     string
+	prefixes []string
+}
+
+// match returns the name of the first entry with a prefix of sym.
+func (l keccakPrefixes) match(sym string) string {
+	for _, s := range l {
+		for _, p := range s.prefixes {
+			if strings.HasPrefix(sym, p) {
+				return s.name
+			}
+		}
+	}
+	return ""
+}
+
+// keccakSources maps symbol prefixes to the source they hash for.
+var keccakSources = keccakPrefixes{
+	{"trie nodes", []string{"github.com/ethereum/go-ethereum/trie.(*hasher).", "github.com/ethereum/go-ethereum/trie.(*StackTrie).", "main.writeProofNodes", "main.writeWitness"}},
+	{"keys", []string{
+		"github.com/ethereum/go-ethereum/trie.(*StateTrie).hashKey", "github.com/ethereum/go-ethereum/core/state.",
+		"main.writeStateTrie", "main.writeBinaryTrie", "main.verifyAccountProof",
+	}},
+	{"KECCAK256 opcode", []string{"github.com/ethereum/go-ethereum/core/vm.opKeccak256"}},
+	{"logs", []string{
+		"github.com/ethereum/go-ethereum/core/types.(*Bloom).", "github.com/ethereum/go-ethereum/core/types.bloomValues",
+		"github.com/ethereum/go-ethereum/core/types.CreateBloom", "github.com/ethereum/go-ethereum/core/types.MergeBloom",
+		"main.rlpHash",
+	}},
+	{"transactions", []string{"github.com/ethereum/go-ethereum/core/types.", "github.com/eth-act/riscv-compilation/cryptobackend.(*signer)."}},
+	{"public values", []string{"main.hashEnv", "github.com/eth-act/riscv-compilation/publicvalues."}},
+}
+
+// keccakPhases maps symbol prefixes to the phases of stateless-exec. The
+// commit phase is marked by StateDB.commit, which StateDB.Commit is inlined
+// into. The goroutines it starts to hash the storage tries inherit its
+// frames, so their hashing counts there too.
+var keccakPhases = keccakPrefixes{
+	{"input", []string{"main.obtainInput", "main.loadTransactions"}},
+	{"pre-state", []string{"main.MakePreState", "main.MakeProofState", "main.MakeWitnessState"}},
+	{"execution", []string{"main.(*Prestate).Apply"}},
+	{"commit", []string{"github.com/ethereum/go-ethereum/core/state.(*StateDB).commit"}},
+	{"public values", []string{"main.hashEnv", "main.publicValues"}},
+}
+
+// NewKeccakCounter finds the keccak methods and marker functions of prog.
+func NewKeccakCounter(prog *Program) (*KeccakCounter, error) {
+	syms, err := prog.File.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	c := &KeccakCounter{
+		entries: make(map[uint64]keccakEntry),
+		stacks:  newShadowStacks(syms),
+		counts:  make(map[[2]string]*KeccakCount),
+	}
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) != elf.STT_FUNC || s.Value == 0 {
+			continue
+		}
+		switch s.Name {
+		case "golang.org/x/crypto/sha3.(*state).Write":
+			c.entries[s.Value] = keccakEntry{kind: keccakWrite}
+			continue
+		case "golang.org/x/crypto/sha3.(*state).Read":
+			c.entries[s.Value] = keccakEntry{kind: keccakRead}
+			continue
+		}
+		phase, source := keccakPhases.match(s.Name), keccakSources.match(s.Name)
+		if phase != "" || source != "" {
+			c.entries[s.Value] = keccakEntry{kind: keccakMark, marker: len(c.markers)}
+			c.markers = append(c.markers, keccakMarker{phase, source})
+		}
+	}
+	for _, e := range c.entries {
+		if e.kind == keccakRead {
+			return c, nil
+		}
+	}
+	return nil, errors.New("program does not use golang.org/x/crypto/sha3")
+}
+
+// step is called before h executes the instruction at its PC.
+func (c *KeccakCounter) step(h *Hart) {
+	stack := c.stacks.unwind(h)
+	e, ok := c.entries[h.PC]
+	if !ok {
+		return
+	}
+	switch e.kind {
+	case keccakWrite:
+		// func (d *state) Write(p []byte): a0 = d, a1 = p.ptr, a2 = p.len
+		c.count(stack).Bytes += h.X[RegA0+2]
+	case keccakRead:
+		c.count(stack).Calls++
+	default:
+		// The kind of a frame is the index of its marker.
+		c.stacks.enter(h, e.marker, "")
+	}
+}
+
+// ecall is called when h traps with an ECALL, before the kernel serves it.
+func (c *KeccakCounter) ecall(h *Hart) {
+	if h.X[RegA7] != cryptobackend.ECallKeccak256 {
+		return
+	}
+	// a0 = input address, a1 = input length
+	k := c.count(c.stacks.stack(h))
+	k.Calls++
+	k.ECalls++
+	k.Bytes += h.X[RegA0+1]
+}
+
+// count returns the counter of the phase and source on top of stack.
+func (c *KeccakCounter) count(stack []shadowFrame) *KeccakCount {
+	phase, source := "other", "other"
+	var havePhase, haveSource bool
+	for i := len(stack) - 1; i >= 0 && !(havePhase && haveSource); i-- {
+		m := c.markers[stack[i].kind]
+		if m.phase != "" && !havePhase {
+			phase, havePhase = m.phase, true
+		}
+		if m.source != "" && !haveSource {
+			source, haveSource = m.source, true
+		}
+	}
+	key := [2]string{phase, source}
+	k := c.counts[key]
+	if k == nil {
+		k = &KeccakCount{Phase: phase, Source: source}
+		c.counts[key] = k
+	}
+	return k
+}
+
+// Counts returns the counts sorted by phase and source.
+func (c *KeccakCounter) Counts() []KeccakCount {
+	out := make([]KeccakCount, 0, len(c.counts))
+	for _, k := range c.counts {
+		out = append(out, *k)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Phase != out[j].Phase {
+			return out[i].Phase < out[j].Phase
+		}
+		return out[i].Source < out[j].Source
+	})
+	return out
+}
+
+// WriteKeccakReport writes the counts per phase and source, with totals per
+// phase and per source.
+func WriteKeccakReport(w io.Writer, counts []KeccakCount) {
+	var total KeccakCount
+	phases := make(map[string]*KeccakCount)
+	sources := make(map[string]*KeccakCount)
+	for _, k := range counts {
+		for _, t := range []*KeccakCount{&total, sum(phases, k.Phase), sum(sources, k.Source)} {
+			t.Calls += k.Calls
+			t.Bytes += k.Bytes
+			t.ECalls += k.ECalls
+		}
+	}
+	fmt.Fprintf(w, "keccak256: %d calls (%d by ECALL), %d bytes hashed\n", total.Calls, total.ECalls, total.Bytes)
+	fmt.Fprintf(w, "%-14s %-18s %10s %10s %12s\n", "phase", "source", "calls", "ecalls", "bytes")
+	for _, k := range counts {
+		fmt.Fprintf(w, "%-14s %-18s %10d %10d %12d\n", k.Phase, k.Source, k.Calls, k.ECalls, k.Bytes)
+	}
+	fmt.Fprintf(w, "\n%-33s %10s %10s %12s\n", "source", "calls", "ecalls", "bytes")
+	for _, name := range sortedKeys(sources) {
+		fmt.Fprintf(w, "%-33s %10d %10d %12d\n", name, sources[name].Calls, sources[name].ECalls, sources[name].Bytes)
+	}
+	fmt.Fprintf(w, "\n%-33s %10s %10s %12s\n", "phase", "calls", "ecalls", "bytes")
+	for _, name := range sortedKeys(phases) {
+		fmt.Fprintf(w, "%-33s %10d %10d %12d\n", name, phases[name].Calls, phases[name].ECalls, phases[name].Bytes)
+	}
+}
+
+func sum(m map[string]*KeccakCount, key string) *KeccakCount {
+	if m[key] == nil {
+		m[key] = &KeccakCount{}
+	}
+	return m[key]
+}
+
+func sortedKeys(m map[string]*KeccakCount) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
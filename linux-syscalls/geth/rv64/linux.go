@@ -0,0 +1,75 @@
+package rv64
+
+import (
+	"github.com/eth-act/riscv-compilation/linuxabi"
+)
+
+const (
+	// userTop is the end of the Sv39 user address space.
+	userTop = 1 << 38
+	// mmapBase is where mappings without a usable hint are placed, growing
+	// down, leaving a gap below the main stack.
+	mmapBase = StackTop - StackSize - 1<<30
+)
+
+// Linux connects a linuxabi kernel to the machine, serving every ECALL from
+// the in-memory syscall layer.
+type Linux struct {
+	ABI *linuxabi.Kernel
+}
+
+// NewLinux creates a kernel for the given configuration.
+func NewLinux(cfg linuxabi.Config) *Linux {
+	return &Linux{ABI: linuxabi.New(cfg)}
+}
+
+// Start sets up the stack of prog and makes its initial thread the running
+// hart of m.
+func (l *Linux) Start(m *Machine, prog *Program, argv, envp []string) error {
+	var random [16]byte
+	l.ABI.Random(random[:])
+	sp, err := SetupStack(m.Mem, prog, argv, envp, random)
+	if err != nil {
+		return err
+	}
+	h := &Hart{PC: prog.Entry}
+	h.X[RegSP] = sp
+	l.ABI.Start(h, linuxabi.Layout{Brk: prog.Brk, MmapBase: mmapBase, UserTop: userTop})
+	m.Switch(h)
+	return nil
+}
+
+// Syscall implements Kernel.
+func (l *Linux) Syscall(m *Machine, h *Hart) error {
+	var args [6]uint64
+	copy(args[:], h.X[RegA0:RegA0+6])
+	return l.ABI.Syscall(host{m}, h.X[RegA7], args)
+}
+
+// host exposes a machine to the syscall layer.
+type host struct{ m *Machine }
+
+func (h host) Read(addr uint64, buf []byte) error  { return h.m.Mem.Read(addr, buf) }
+func (h host) Write(addr uint64, buf []byte) error { return h.m.Mem.Write(addr, buf) }
+func (h host) Map(addr, size uint64)               { h.m.Mem.Map(addr, size) }
+func (h host) Unmap(addr, size uint64)             { h.m.Mem.Unmap(addr, size) }
+func (h host) Free(addr, size uint64) bool         { return h.m.Mem.Free(addr, size) }
+func (h host) Switch(t linuxabi.Thread)            { h.m.Switch(t.(*Hart)) }
+func (h host) Halt(code int)                       { h.m.Halt(code) }
+
+// SetReturn implements linuxabi.Thread.
+func (h *Hart) SetReturn(v uint64) { h.X[RegA0] = v }
+
+// Clone implements linuxabi.Thread.
+func (h *Hart) Clone(sp, tls uint64, setTLS bool) linuxabi.Thread {
+	c := *h
+	c.resValid = false
+	if sp != 0 {
+		c.X[RegSP] = sp
+	}
+	if setTLS {
+		c.X[RegTP] = tls
+	}
+	c.X[RegA0] = 0
+	return &c
+}
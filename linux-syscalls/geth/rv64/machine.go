@@ -0,0 +1,100 @@
+// Package rv64 is a user-mode RV64GC emulator for running the statically
+// linked linux/riscv64 builds of the stateless executors without QEMU.
+//
+// The machine interprets RV64IMAFDC plus Zicsr and Zifencei and hands every
+// ECALL to a Kernel, which plays the role of the zkVM syscall dispatcher
+// described in REPORT.md.
+package rv64
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kernel services the ECALLs of a machine. When Syscall is called, the PC of
+// h already points past the ECALL instruction; the syscall number is in a7
+// and the arguments in a0-a5.
+type Kernel interface {
+	Syscall(m *Machine, h *Hart) error
+}
+
+// Machine is an emulated RV64 core with a shared address space. Multiple
+// harts may exist (one per guest thread), but only the current one runs; the
+// kernel switches between them on blocking syscalls.
+type Machine struct {
+	Mem     *Memory
+	Kernel  Kernel
+	Instret uint64 // retired instructions across all harts
+	Limit   uint64 // if non-zero, Run fails with ErrLimit after this many instructions
+
+	// Profiler, if set, counts every retired instruction by PC.
+	Profiler *Profiler
+	// Keccak, if set, counts keccak256 hashes.
+	Keccak *KeccakCounter
+	// Init, if set, measures package initialisation.
+	Init *InitProfiler
+
+	cur      *Hart
+	halted   bool
+	exitCode int
+}
+
+// ErrLimit is returned by Run when the instruction limit is reached.
+var ErrLimit = errors.New("instruction limit reached")
+
+// NewMachine creates a machine with an empty address space.
+func NewMachine(kernel Kernel) *Machine {
+	return &Machine{Mem: NewMemory(), Kernel: kernel}
+}
+
+// Current returns the running hart.
+func (m *Machine) Current() *Hart { return m.cur }
+
+// Switch makes h the running hart.
+func (m *Machine) Switch(h *Hart) { m.cur = h }
+
+// Halt stops the machine after the current instruction.
+func (m *Machine) Halt(code int) {
+	m.halted, m.exitCode = true, code
+}
+
+// ExitCode returns the status passed to Halt.
+func (m *Machine) ExitCode() int { return m.exitCode }
+
+// Run executes the current hart until the machine halts or faults.
+func (m *Machine) Run() error {
+	for !m.halted {
+		h := m.cur
+		if m.Profiler != nil {
+			m.Profiler.add(h.PC)
+		}
+		if m.Keccak != nil {
+			m.Keccak.step(h)
+		}
+		if m.Init != nil {
+			m.Init.step(h, m.Instret)
+		}
+		err := m.step(h)
+		m.Instret++
+		if m.Instret == m.Limit {
+			return ErrLimit
+		}
+		if err == nil {
+			continue
+		}
+		switch err {
+		case errEcall:
+			if m.Keccak != nil {
+				m.Keccak.ecall(h)
+			}
+			if err := m.Kernel.Syscall(m, h); err != nil {
+				return err
+			}
+		case errEbreak:
+			return fmt.Errorf("ebreak at pc %#x", h.PC-4)
+		default:
+			return fmt.Errorf("pc %#x: %w", h.PC, err)
+		}
+	}
+	return nil
+}
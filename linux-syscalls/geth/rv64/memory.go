@@ -0,0 +1,278 @@
+package rv64
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+const (
+	pageBits = 12
+	pageSize = 1 << pageBits
+	pageMask = pageSize - 1
+)
+
+// PageSize is the page granularity of the emulated address space.
+const PageSize = pageSize
+
+type page [pageSize]byte
+
+// span is a half-open range [start, end) of mapped addresses.
+type span struct {
+	start, end uint64
+}
+
+// Memory is a sparse, lazily allocated 64-bit address space. Ranges are mapped
+// explicitly (ELF segments, stack, mmap, brk); pages inside a mapped range are
+// only backed by host memory once touched, so the large reservations the Go
+// runtime makes are cheap. Accesses outside any mapped range fault.
+type Memory struct {
+	pages map[uint64]*page
+	spans []span // sorted, non-overlapping, non-adjacent
+
+	// Single-entry caches for data accesses and instruction fetches.
+	dataNum, fetchNum   uint64
+	dataPage, fetchPage *page
+}
+
+// NewMemory returns an empty address space.
+func NewMemory() *Memory {
+	return &Memory{
+		pages:    make(map[uint64]*page),
+		dataNum:  ^uint64(0),
+		fetchNum: ^uint64(0),
+	}
+}
+
+// Fault is returned for accesses to unmapped memory.
+type Fault struct {
+	Addr  uint64
+	Write bool
+}
+
+func (f *Fault) Error() string {
+	if f.Write {
+		return fmt.Sprintf("write to unmapped address %#x", f.Addr)
+	}
+	return fmt.Sprintf("read from unmapped address %#x", f.Addr)
+}
+
+// Map makes [addr, addr+size) accessible, rounded out to page boundaries.
+// Mapping an already mapped range is allowed and keeps its contents.
+func (m *Memory) Map(addr, size uint64) {
+	if size == 0 {
+		return
+	}
+	start, end := addr&^pageMask, (addr+size+pageMask)&^pageMask
+	i := sort.Search(len(m.spans), func(i int) bool { return m.spans[i].end >= start })
+	j := i
+	for j < len(m.spans) && m.spans[j].start <= end {
+		if m.spans[j].start < start {
+			start = m.spans[j].start
+		}
+		if m.spans[j].end > end {
+			end = m.spans[j].end
+		}
+		j++
+	}
+	m.spans = append(m.spans[:i], append([]span{{start, end}}, m.spans[j:]...)...)
+}
+
+// Unmap removes [addr, addr+size) from the address space and releases its
+// pages.
+func (m *Memory) Unmap(addr, size uint64) {
+	if size == 0 {
+		return
+	}
+	start, end := addr&^pageMask, (addr+size+pageMask)&^pageMask
+	var spans []span
+	for _, s := range m.spans {
+		if s.end <= start || s.start >= end {
+			spans = append(spans, s)
+			continue
+		}
+		if s.start < start {
+			spans = append(spans, span{s.start, start})
+		}
+		if s.end > end {
+			spans = append(spans, span{end, s.end})
+		}
+	}
+	m.spans = spans
+	if (end-start)>>pageBits < uint64(len(m.pages)) {
+		for pn := start >> pageBits; pn < end>>pageBits; pn++ {
+			delete(m.pages, pn)
+		}
+	} else {
+		for pn := range m.pages {
+			if pn >= start>>pageBits && pn < end>>pageBits {
+				delete(m.pages, pn)
+			}
+		}
+	}
+	m.dataNum, m.fetchNum = ^uint64(0), ^uint64(0)
+}
+
+// Mapped reports whether every address in [addr, addr+size) is mapped.
+func (m *Memory) Mapped(addr, size uint64) bool {
+	i := sort.Search(len(m.spans), func(i int) bool { return m.spans[i].end > addr })
+	return i < len(m.spans) && m.spans[i].start <= addr && addr+size <= m.spans[i].end
+}
+
+// Free reports whether no address in [addr, addr+size) is mapped.
+func (m *Memory) Free(addr, size uint64) bool {
+	i := sort.Search(len(m.spans), func(i int) bool { return m.spans[i].end > addr })
+	return i == len(m.spans) || m.spans[i].start >= addr+size
+}
+
+// Resident returns the number of bytes backed by host memory.
+func (m *Memory) Resident() uint64 {
+	return uint64(len(m.pages)) * pageSize
+}
+
+// MappedBytes returns the total size of all mapped ranges.
+func (m *Memory) MappedBytes() uint64 {
+	var total uint64
+	for _, s := range m.spans {
+		total += s.end - s.start
+	}
+	return total
+}
+
+// lookup returns the page holding addr, allocating it if it lies in a mapped
+// range.
+func (m *Memory) lookup(addr uint64, write bool) (*page, error) {
+	pn := addr >> pageBits
+	if p, ok := m.pages[pn]; ok {
+		return p, nil
+	}
+	if !m.Mapped(addr&^pageMask, pageSize) {
+		return nil, &Fault{Addr: addr, Write: write}
+	}
+	p := new(page)
+	m.pages[pn] = p
+	return p, nil
+}
+
+func (m *Memory) data(addr uint64, write bool) (*page, error) {
+	if pn := addr >> pageBits; pn == m.dataNum {
+		return m.dataPage, nil
+	}
+	p, err := m.lookup(addr, write)
+	if err != nil {
+		return nil, err
+	}
+	m.dataNum, m.dataPage = addr>>pageBits, p
+	return p, nil
+}
+
+// Read copies len(buf) bytes starting at addr into buf.
+func (m *Memory) Read(addr uint64, buf []byte) error {
+	for len(buf) > 0 {
+		p, err := m.data(addr, false)
+		if err != nil {
+			return err
+		}
+		n := copy(buf, p[addr&pageMask:])
+		buf, addr = buf[n:], addr+uint64(n)
+	}
+	return nil
+}
+
+// Write copies buf into memory starting at addr.
+func (m *Memory) Write(addr uint64, buf []byte) error {
+	for len(buf) > 0 {
+		p, err := m.data(addr, true)
+		if err != nil {
+			return err
+		}
+		n := copy(p[addr&pageMask:], buf)
+		buf, addr = buf[n:], addr+uint64(n)
+	}
+	return nil
+}
+
+// ReadString reads a NUL-terminated string of at most max bytes.
+func (m *Memory) ReadString(addr uint64, max int) (string, error) {
+	var out []byte
+	for len(out) < max {
+		p, err := m.data(addr, false)
+		if err != nil {
+			return "", err
+		}
+		chunk := p[addr&pageMask:]
+		for i, c := range chunk {
+			if c == 0 {
+				return string(append(out, chunk[:i]...)), nil
+			}
+		}
+		out = append(out, chunk...)
+		addr += uint64(len(chunk))
+	}
+	return string(out[:max]), nil
+}
+
+// Load reads a little-endian value of size 1, 2, 4 or 8 bytes.
+func (m *Memory) Load(addr uint64, size int) (uint64, error) {
+	off := addr & pageMask
+	if off+uint64(size) > pageSize {
+		var buf [8]byte
+		if err := m.Read(addr, buf[:size]); err != nil {
+			return 0, err
+		}
+		return binary.LittleEndian.Uint64(buf[:]), nil
+	}
+	p, err := m.data(addr, false)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(p[off]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(p[off:])), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(p[off:])), nil
+	default:
+		return binary.LittleEndian.Uint64(p[off:]), nil
+	}
+}
+
+// Store writes a little-endian value of size 1, 2, 4 or 8 bytes.
+func (m *Memory) Store(addr uint64, size int, v uint64) error {
+	off := addr & pageMask
+	if off+uint64(size) > pageSize {
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], v)
+		return m.Write(addr, buf[:size])
+	}
+	p, err := m.data(addr, true)
+	if err != nil {
+		return err
+	}
+	switch size {
+	case 1:
+		p[off] = byte(v)
+	case 2:
+		binary.LittleEndian.PutUint16(p[off:], uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(p[off:], uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(p[off:], v)
+	}
+	return nil
+}
+
+// fetch reads 16 bits of instruction memory.
+func (m *Memory) fetch(addr uint64) (uint32, error) {
+	pn := addr >> pageBits
+	if pn != m.fetchNum {
+		p, err := m.lookup(addr, false)
+		if err != nil {
+			return 0, err
+		}
+		m.fetchNum, m.fetchPage = pn, p
+	}
+	off := addr & pageMask
+	return uint32(m.fetchPage[off]) | uint32(m.fetchPage[off+1])<<8, nil
+}
@@ -0,0 +1,101 @@
+package rv64
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// WritePprof writes syms as a gzipped pprof profile with a single
+// "instructions" sample type, so it can be explored with `go tool pprof`.
+// Every function becomes one location at its entry address.
+func WritePprof(w io.Writer, syms []Symbol) error {
+	var (
+		p     protoBuf
+		index = map[string]int64{"": 0}
+		table = []string{""}
+	)
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return index[s]
+	}
+	valueType := func(typ, unit string) []byte {
+		var vt protoBuf
+		vt.int(1, str(typ))
+		vt.int(2, str(unit))
+		return vt.b
+	}
+	p.bytes(1, valueType("instructions", "count")) // sample_type
+	for i, s := range syms {
+		id := uint64(i + 1)
+
+		var sample protoBuf
+		sample.packed(1, id)                     // location_id
+		sample.packed(2, uint64(s.Instructions)) // value
+		p.bytes(2, sample.b)
+
+		var line protoBuf
+		line.uint(1, id) // function_id
+		var loc protoBuf
+		loc.uint(1, id)     // id
+		loc.uint(3, s.Addr) // address
+		loc.bytes(4, line.b)
+		p.bytes(4, loc.b)
+
+		var fn protoBuf
+		fn.uint(1, id)         // id
+		fn.int(2, str(s.Name)) // name
+		fn.int(3, str(s.Name)) // system_name
+		p.bytes(5, fn.b)
+	}
+	p.bytes(11, valueType("instructions", "count")) // period_type
+	p.int(12, 1)                                    // period
+	for _, s := range table {
+		p.bytes(6, []byte(s)) // string_table
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(p.b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// protoBuf is a minimal protocol buffer encoder for the profile.proto
+// messages.
+type protoBuf struct {
+	b []byte
+}
+
+func (p *protoBuf) varint(v uint64) {
+	for v >= 0x80 {
+		p.b = append(p.b, byte(v)|0x80)
+		v >>= 7
+	}
+	p.b = append(p.b, byte(v))
+}
+
+func (p *protoBuf) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	p.varint(uint64(field) << 3)
+	p.varint(v)
+}
+
+func (p *protoBuf) int(field int, v int64) { p.uint(field, uint64(v)) }
+
+func (p *protoBuf) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *protoBuf) packed(field int, v uint64) {
+	var inner protoBuf
+	inner.varint(v)
+	p.bytes(field, inner.b)
+}
@@ -0,0 +1,50 @@
+package rv64
+
+import (
+	"github.com/eth-act/riscv-compilation/cryptobackend"
+)
+
+// Precompiles serves the ECALLs of the cryptobackend ECALL backend on the host
+// and passes every other ECALL on to Kernel, the way a zkVM handles its
+// precompile calls next to its syscalls. Each call retires as a single
+// instruction.
+type Precompiles struct {
+	Kernel
+	Backend cryptobackend.Backend
+	// Calls counts the precompile ECALLs, by number.
+	Calls map[uint64]int
+}
+
+// NewPrecompiles serves precompile ECALLs with b in front of kernel.
+func NewPrecompiles(kernel Kernel, b cryptobackend.Backend) *Precompiles {
+	return &Precompiles{Kernel: kernel, Backend: b, Calls: make(map[uint64]int)}
+}
+
+// Syscall implements Kernel.
+func (p *Precompiles) Syscall(m *Machine, h *Hart) error {
+	num := h.X[RegA7]
+	if _, ok := cryptobackend.ECallName(num); !ok {
+		return p.Kernel.Syscall(m, h)
+	}
+	p.Calls[num]++
+	// a0-a3: input address and length, output address and size
+	a := h.X[RegA0 : RegA0+4]
+	in, inLen, out, outLen := a[0], a[1], a[2], a[3]
+	if inLen > 0 && !m.Mem.Mapped(in, inLen) {
+		return &Fault{Addr: in}
+	}
+	input := make([]byte, inLen)
+	if err := m.Mem.Read(in, input); err != nil {
+		return err
+	}
+	result, err := cryptobackend.Serve(p.Backend, num, input)
+	if err != nil || uint64(len(result)) > outLen {
+		h.X[RegA0] = ^uint64(0)
+		return nil
+	}
+	if err := m.Mem.Write(out, result); err != nil {
+		return err
+	}
+	h.X[RegA0] = uint64(len(result))
+	return nil
+}
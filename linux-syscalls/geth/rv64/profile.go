@@ -0,0 +1,185 @@
+package rv64
+
+import (
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Profiler counts retired instructions per program counter in the executable
+// segment of a program. Set it as Machine.Profiler before calling Run.
+type Profiler struct {
+	prog   *Program
+	base   uint64
+	counts []uint64 // indexed by (pc-base)/2
+	other  uint64   // instructions outside the executable segment
+}
+
+// NewProfiler creates a profiler covering the executable segment of prog.
+func NewProfiler(prog *Program) (*Profiler, error) {
+	for _, p := range prog.File.Progs {
+		if p.Type == elf.PT_LOAD && p.Flags&elf.PF_X != 0 {
+			return &Profiler{prog: prog, base: p.Vaddr, counts: make([]uint64, (p.Memsz+1)/2)}, nil
+		}
+	}
+	return nil, errors.New("program has no executable segment")
+}
+
+func (p *Profiler) add(pc uint64) {
+	if i := (pc - p.base) >> 1; i < uint64(len(p.counts)) {
+		p.counts[i]++
+		return
+	}
+	p.other++
+}
+
+// Symbol is a function from the ELF symbol table with the instructions
+// retired inside it.
+type Symbol struct {
+	Name         string
+	Addr, Size   uint64
+	Instructions uint64
+}
+
+// Attribute sums the counted instructions per function symbol. The
+// result is sorted by instruction count, highest first; instructions outside
+// any symbol are reported as "[unknown]".
+func (p *Profiler) Attribute() ([]Symbol, error) {
+	syms, err := p.prog.File.Symbols()
+	if err != nil {
+		return nil, err
+	}
+	var funcs []Symbol
+	for _, s := range syms {
+		if elf.ST_TYPE(s.Info) == elf.STT_FUNC && s.Value != 0 {
+			funcs = append(funcs, Symbol{Name: s.Name, Addr: s.Value, Size: s.Size})
+		}
+	}
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].Addr < funcs[j].Addr })
+
+	unknown := Symbol{Name: "[unknown]", Instructions: p.other}
+	for i, n := range p.counts {
+		if n == 0 {
+			continue
+		}
+		pc := p.base + uint64(i)*2
+		j := sort.Search(len(funcs), func(j int) bool { return funcs[j].Addr > pc }) - 1
+		if j < 0 || (funcs[j].Size != 0 && pc >= funcs[j].Addr+funcs[j].Size) {
+			unknown.Instructions += n
+			continue
+		}
+		funcs[j].Instructions += n
+	}
+	var out []Symbol
+	for _, f := range funcs {
+		if f.Instructions > 0 {
+			out = append(out, f)
+		}
+	}
+	if unknown.Instructions > 0 {
+		out = append(out, unknown)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Instructions > out[j].Instructions })
+	return out, nil
+}
+
+// categories groups symbols into the components that matter when sizing a
+// zkVM proof. The first matching prefix wins, so specific entries come first.
+var categories = []struct {
+	name     string
+	prefixes []string
+}{
+	{"keccak", []string{
+		"golang.org/x/crypto/sha3.", "crypto/internal/fips140/sha3.", "crypto/sha3.",
+		"github.com/ethereum/go-ethereum/crypto.Keccak", "github.com/ethereum/go-ethereum/crypto.NewKeccakState",
+		"github.com/ethereum/go-ethereum/crypto/keccak.",
+	}},
+	{"secp256k1", []string{
+		"github.com/ethereum/go-ethereum/crypto/secp256k1.", "github.com/decred/dcrd/dcrec/secp256k1",
+		"github.com/ethereum/go-ethereum/crypto.Ecrecover", "github.com/ethereum/go-ethereum/crypto.SigToPub",
+	}},
+	{"other crypto", []string{"crypto/", "golang.org/x/crypto/", "github.com/ethereum/go-ethereum/crypto",
+		"github.com/consensys/", "github.com/crate-crypto/", "github.com/ethereum/c-kzg-4844", "github.com/supranational/"}},
+	{"trie hashing", []string{"github.com/ethereum/go-ethereum/trie.(*hasher)", "github.com/ethereum/go-ethereum/trie.newHasher"}},
+	{"trie", []string{"github.com/ethereum/go-ethereum/trie", "github.com/ethereum/go-ethereum/triedb"}},
+	{"evm interpreter", []string{"github.com/ethereum/go-ethereum/core/vm."}},
+	{"state", []string{"github.com/ethereum/go-ethereum/core/state"}},
+	{"rlp", []string{"github.com/ethereum/go-ethereum/rlp"}},
+	{"json", []string{"encoding/json.", "github.com/ethereum/go-ethereum/common/hexutil", "github.com/ethereum/go-ethereum/common/math"}},
+	{"decompression", []string{"compress/", "hash/adler32.", "hash/crc32.", "encoding/base64.", "bufio."}},
+	{"big integers", []string{"math/big.", "github.com/holiman/uint256."}},
+	{"gc", []string{
+		"runtime.gc", "runtime.scanobject", "runtime.scanblock", "runtime.scanstack", "runtime.scanframe",
+		"runtime.markroot", "runtime.greyobject", "runtime.findObject", "runtime.(*gcWork)", "runtime.(*gcBits)",
+		"runtime.sweep", "runtime.bgsweep", "runtime.(*sweepLocked)", "runtime.(*mspan).sweep", "runtime.bgscavenge",
+		"runtime.(*scavenger", "runtime.wbBuf", "runtime.bulkBarrier", "runtime.gcWriteBarrier", "runtime.typePointers",
+		"runtime.(*mspan).typePointers", "runtime.(*typePointers)", "runtime.spanOf", "runtime.heapBitsSetType",
+		"runtime.(*mheap).nextSpanForSweep", "runtime.(*gcControllerState)", "runtime.(*pageAlloc).scavenge",
+	}},
+	{"allocation", []string{
+		"runtime.mallocgc", "runtime.newobject", "runtime.makeslice", "runtime.growslice", "runtime.makemap",
+		"runtime.(*mcache)", "runtime.(*mcentral)", "runtime.(*mheap)", "runtime.(*pageAlloc)", "runtime.(*fixalloc)",
+		"runtime.memclr", "runtime.nextFreeFast", "runtime.(*mspan)", "runtime.heapSetType", "runtime.rawstring",
+		"runtime.rawbyteslice", "runtime.newarray", "runtime.(*mspan).init",
+	}},
+	{"runtime", []string{"runtime.", "internal/", "sync.", "sync/", "aeshash", "gogo", "memeqbody"}},
+	{"ethereum", []string{"github.com/ethereum/go-ethereum"}},
+}
+
+// Category returns the component a symbol belongs to.
+func Category(name string) string {
+	for _, c := range categories {
+		for _, p := range c.prefixes {
+			if strings.HasPrefix(name, p) {
+				return c.name
+			}
+		}
+	}
+	return "other"
+}
+
+// WriteTop writes the n functions with the most instructions, followed by the
+// totals per category.
+func WriteTop(w io.Writer, syms []Symbol, n int) {
+	var total uint64
+	for _, s := range syms {
+		total += s.Instructions
+	}
+	if total == 0 {
+		return
+	}
+	pct := func(v uint64) float64 { return 100 * float64(v) / float64(total) }
+
+	fmt.Fprintf(w, "%d instructions\n\n", total)
+	fmt.Fprintf(w, "%14s %7s %7s  %s\n", "instructions", "flat%", "sum%", "function")
+	var sum uint64
+	for i, s := range syms {
+		if i == n {
+			break
+		}
+		sum += s.Instructions
+		fmt.Fprintf(w, "%14d %6.2f%% %6.2f%%  %s\n", s.Instructions, pct(s.Instructions), pct(sum), s.Name)
+	}
+
+	byCat := make(map[string]uint64)
+	for _, s := range syms {
+		byCat[Category(s.Name)] += s.Instructions
+	}
+	cats := make([]string, 0, len(byCat))
+	for c := range byCat {
+		cats = append(cats, c)
+	}
+	sort.Slice(cats, func(i, j int) bool {
+		if byCat[cats[i]] != byCat[cats[j]] {
+			return byCat[cats[i]] > byCat[cats[j]]
+		}
+		return cats[i] < cats[j]
+	})
+	fmt.Fprintf(w, "\n%14s %7s  %s\n", "instructions", "share", "category")
+	for _, c := range cats {
+		fmt.Fprintf(w, "%14d %6.2f%%  %s\n", byCat[c], pct(byCat[c]), c)
+	}
+}
@@ -0,0 +1,113 @@
+package rv64
+
+import "debug/elf"
+
+// shadowStacks follows calls of marker functions on each goroutine, from
+// their entries and return addresses, without decoding the rest of the
+// program. Goroutines are told apart by their g: a hart runs many of them in
+// turn, so a stack per hart would mix their frames. A goroutine starts with
+// the frames of the goroutine that created it, which stay until it exits, so
+// the work it does for a marker function is counted there. The g0 and signal
+// goroutine of each thread are not created by newproc1, and the runtime's work
+// on them, such as growing a stack, counts for the goroutine the hart ran
+// last.
+type shadowStacks struct {
+	frames   map[uint64][]shadowFrame // by g
+	newproc1 uint64                   // entry of runtime.newproc1
+	spawns   map[*Hart]spawn          // calls of newproc1 in progress
+	last     map[*Hart]uint64         // the goroutine h ran last
+}
+
+type shadowFrame struct {
+	entry, ra uint64
+	kind      int
+	label     string
+}
+
+// spawn is a call of runtime.newproc1, which returns the g it creates.
+type spawn struct {
+	ra, parent uint64
+}
+
+func newShadowStacks(syms []elf.Symbol) *shadowStacks {
+	s := &shadowStacks{
+		frames: make(map[uint64][]shadowFrame),
+		spawns: make(map[*Hart]spawn),
+		last:   make(map[*Hart]uint64),
+	}
+	for _, sym := range syms {
+		if sym.Name == "runtime.newproc1" && elf.ST_TYPE(sym.Info) == elf.STT_FUNC {
+			s.newproc1 = sym.Value
+		}
+	}
+	return s
+}
+
+// unwind pops the frames the goroutine running on h has returned from and
+// returns its stack. It is called before h executes the instruction at its
+// PC.
+func (s *shadowStacks) unwind(h *Hart) []shadowFrame {
+	s.follow(h)
+	g := s.goroutine(h)
+	stack := s.frames[g]
+	// Returning to a frame also unwinds the frames above it that returned
+	// without passing through their own return address, by a panic or a
+	// tail call.
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].ra == h.PC {
+			stack = stack[:i]
+			s.frames[g] = stack
+			break
+		}
+	}
+	return stack
+}
+
+// follow gives a goroutine the frames of its creator once newproc1 returns
+// it. The frames have no return address, as the goroutine never returns into
+// them.
+func (s *shadowStacks) follow(h *Hart) {
+	if sp, ok := s.spawns[h]; ok && h.PC == sp.ra {
+		// func newproc1(fn *funcval, callergp *g, ...) *g
+		parent := s.frames[sp.parent]
+		stack := make([]shadowFrame, len(parent))
+		for i, f := range parent {
+			f.ra = 0
+			stack[i] = f
+		}
+		s.frames[h.X[RegA0]] = stack
+		delete(s.spawns, h)
+	}
+	if h.PC == s.newproc1 && s.newproc1 != 0 {
+		s.spawns[h] = spawn{ra: h.X[RegRA], parent: h.X[RegA0+1]}
+	}
+}
+
+// goroutine returns the goroutine whose stack the instruction at the PC of h
+// counts on.
+func (s *shadowStacks) goroutine(h *Hart) uint64 {
+	g := h.X[RegG]
+	if _, ok := s.frames[g]; ok {
+		s.last[h] = g
+		return g
+	}
+	return s.last[h]
+}
+
+// stack returns the stack of the goroutine running on h.
+func (s *shadowStacks) stack(h *Hart) []shadowFrame {
+	return s.frames[s.goroutine(h)]
+}
+
+// enter pushes a frame for the marker function the goroutine running on h is
+// entering.
+func (s *shadowStacks) enter(h *Hart, kind int, label string) {
+	g := s.goroutine(h)
+	stack := s.frames[g]
+	ra := h.X[RegRA]
+	// A function that grows its stack restarts at its entry.
+	if n := len(stack); n > 0 && stack[n-1].entry == h.PC && stack[n-1].ra == ra {
+		return
+	}
+	s.frames[g] = append(stack, shadowFrame{entry: h.PC, ra: ra, kind: kind, label: label})
+}
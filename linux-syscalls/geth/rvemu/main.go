@@ -0,0 +1,412 @@
+// Command rvemu runs a statically linked linux/riscv64 executable, such as the
+// stateless-exec build, in the rv64 user-mode emulator. Every ECALL is served
+// in-process by the linuxabi syscall layer, so syscall traces can be collected
+// without a RISC-V VM.
+//
+// With -trace, syscalls are logged in strace format and the log can be fed
+// directly to syscall-diff and syscall-tier. With -minimize, the program is
+// re-run with every syscall it uses stubbed out in turn, to find the smallest
+// syscall surface that still produces the same output. With -check, it is run
+// twice to confirm that the run is bit-identical: the clock and random source
+// are virtual and seeded, so any difference means the guest depends on
+// something outside its input.
+//
+// Builds with the `ecallcrypto` tag hand keccak256, ecrecover and the other
+// cryptobackend operations to the host by ECALL; rvemu serves them natively.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eth-act/riscv-compilation/cryptobackend"
+	"github.com/eth-act/riscv-compilation/linuxabi"
+	"github.com/eth-act/riscv-compilation/rv64"
+)
+
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+func main() {
+	var (
+		trace    = flag.String("trace", "", "write an strace-style syscall log to this file (- for stderr)")
+		stats    = flag.Bool("stats", false, "print instruction and syscall counts when the program exits")
+		hostFS   = flag.Bool("hostfs", true, "let the guest open host files that were not preloaded")
+		minimize = flag.Bool("minimize", false, "find the syscalls the program cannot do without")
+		top      = flag.Int("top", 0, "print the N functions that retired the most instructions")
+		profile  = flag.String("profile", "", "write a pprof instruction profile to this file")
+		seed     = flag.String("seed", "", "hex seed for getrandom and AT_RANDOM (default: the zkvm board's)")
+		check    = flag.Bool("check", false, "run the program twice and fail unless both runs are identical")
+		keccak   = flag.Bool("keccak", false, "print keccak256 calls and bytes hashed per phase and source")
+		initTop  = flag.Int("init", 0, "print the N packages whose initialisation retired the most instructions")
+		env      listFlag
+		preload  listFlag
+		stubs    listFlag
+	)
+	flag.Var(&env, "env", "set a guest environment variable (KEY=VALUE, repeatable)")
+	flag.Var(&preload, "preload", "load a host file or directory into the in-memory file system (repeatable)")
+	flag.Var(&stubs, "stub", "replace a syscall with a fixed result, e.g. ioctl=-ENOTTY (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: rvemu [flags] <elf> [args...]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	elfPath := flag.Arg(0)
+	data, err := os.ReadFile(elfPath)
+	if err != nil {
+		fatal(err)
+	}
+	cfg := linuxabi.Config{
+		Files:  make(map[string][]byte),
+		HostFS: *hostFS,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Stubs:  linuxabi.DefaultStubs(),
+	}
+	if *seed != "" {
+		b, err := hex.DecodeString(*seed)
+		if err != nil || len(b) > len(cfg.Seed) {
+			fatal(fmt.Errorf("invalid seed %q, want up to %d hex bytes", *seed, len(cfg.Seed)))
+		}
+		copy(cfg.Seed[:], b)
+	}
+	for _, p := range preload {
+		if err := loadFiles(cfg.Files, p); err != nil {
+			fatal(err)
+		}
+	}
+	for _, s := range stubs {
+		name, ret, err := parseStub(s)
+		if err != nil {
+			fatal(err)
+		}
+		cfg.Stubs[name] = ret
+	}
+	prog := program{elf: data, path: elfPath, argv: flag.Args(), envp: env}
+
+	if *minimize {
+		if err := minimizeSyscalls(prog, cfg); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *check {
+		if err := checkDeterminism(prog, cfg); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	switch *trace {
+	case "":
+	case "-":
+		cfg.Trace = os.Stderr
+	default:
+		f, err := os.Create(*trace)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		cfg.Trace = f
+	}
+	prog.profile = *top > 0 || *profile != ""
+	prog.keccak = *keccak
+	prog.init = *initTop > 0
+	start := time.Now()
+	m, kernel, runErr := prog.run(cfg, 0)
+	if *stats && m != nil {
+		printStats(os.Stderr, m, kernel, time.Since(start))
+	}
+	if runErr != nil {
+		fatal(runErr)
+	}
+	if prog.profile {
+		if err := writeProfile(m, *top, *profile); err != nil {
+			fatal(err)
+		}
+	}
+	if prog.keccak {
+		rv64.WriteKeccakReport(os.Stderr, m.Keccak.Counts())
+	}
+	if prog.init {
+		rv64.WriteInitReport(os.Stderr, m.Init, *initTop)
+	}
+	if f, ok := cfg.Trace.(*os.File); ok && f != os.Stderr {
+		f.Close()
+	}
+	os.Exit(m.ExitCode())
+}
+
+// program is a guest executable with its command line.
+type program struct {
+	elf        []byte
+	path       string
+	argv, envp []string
+	profile    bool // count instructions per PC
+	keccak     bool // count keccak256 hashes
+	init       bool // measure package initialisation
+}
+
+// run executes the program on a fresh machine.
+func (p program) run(cfg linuxabi.Config, limit uint64) (*rv64.Machine, *rv64.Linux, error) {
+	kernel := rv64.NewLinux(cfg)
+	m := rv64.NewMachine(rv64.NewPrecompiles(kernel, cryptobackend.Go))
+	m.Limit = limit
+	prog, err := rv64.LoadELF(m.Mem, p.path, p.elf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := kernel.Start(m, prog, p.argv, p.envp); err != nil {
+		return nil, nil, err
+	}
+	if p.profile {
+		if m.Profiler, err = rv64.NewProfiler(prog); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.keccak {
+		if m.Keccak, err = rv64.NewKeccakCounter(prog); err != nil {
+			return nil, nil, err
+		}
+	}
+	if p.init {
+		if m.Init, err = rv64.NewInitProfiler(prog); err != nil {
+			return nil, nil, err
+		}
+	}
+	return m, kernel, m.Run()
+}
+
+// writeProfile prints the top functions and writes the pprof profile.
+func writeProfile(m *rv64.Machine, top int, path string) error {
+	syms, err := m.Profiler.Attribute()
+	if err != nil {
+		return err
+	}
+	if top > 0 {
+		rv64.WriteTop(os.Stderr, syms, top)
+	}
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := rv64.WritePprof(f, syms); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadFiles adds a host file, or every file below a host directory, to files
+// under the same path relative to the guest's root directory.
+func loadFiles(files map[string][]byte, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[path.Join("/", filepath.ToSlash(p))] = data
+		return nil
+	})
+}
+
+// parseStub parses name=value, where value is a number or a negated errno
+// name such as -ENOTTY.
+func parseStub(s string) (string, int64, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", 0, fmt.Errorf("invalid stub %q, want name=value", s)
+	}
+	if _, ok := linuxabi.SyscallNumber(name); !ok {
+		return "", 0, fmt.Errorf("unknown syscall %q", name)
+	}
+	if e, ok := linuxabi.Errno(strings.TrimPrefix(value, "-")); ok {
+		return name, -e, nil
+	}
+	ret, err := strconv.ParseInt(value, 0, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid stub value %q", value)
+	}
+	return name, ret, nil
+}
+
+// minimizeSyscalls runs the program once to record its output and the
+// syscalls it makes, then greedily stubs each syscall with -ENOSYS and keeps
+// the stub whenever the output and exit status are unchanged.
+func minimizeSyscalls(prog program, cfg linuxabi.Config) error {
+	run := func(stubs map[string]int64, limit uint64) (string, *rv64.Machine, *rv64.Linux, error) {
+		var out bytes.Buffer
+		c := cfg
+		c.Stdin, c.Stdout, c.Stderr = nil, &out, io.Discard
+		c.Stubs = stubs
+		m, kernel, err := prog.run(c, limit)
+		return out.String(), m, kernel, err
+	}
+	baseOut, base, kernel, err := run(cfg.Stubs, 0)
+	if err != nil {
+		return fmt.Errorf("baseline run failed: %v", err)
+	}
+	var used []string
+	for num := range kernel.ABI.Syscalls {
+		name := linuxabi.SyscallName(num)
+		if _, stubbed := cfg.Stubs[name]; !stubbed {
+			used = append(used, name)
+		}
+	}
+	sort.Strings(used)
+	fmt.Printf("Baseline: %d instructions, %d syscalls used\n\n", base.Instret, len(used))
+
+	stubs := make(map[string]int64)
+	for name, ret := range cfg.Stubs {
+		stubs[name] = ret
+	}
+	var required, optional []string
+	for _, name := range used {
+		stubs[name] = -linuxabi.ENOSYS
+		out, m, _, err := run(stubs, 4*base.Instret)
+		switch {
+		case err == nil && out == baseOut && m.ExitCode() == base.ExitCode():
+			optional = append(optional, name)
+			fmt.Printf("  %-20s optional\n", name)
+		default:
+			delete(stubs, name)
+			required = append(required, name)
+			reason := "output differs"
+			switch {
+			case errors.Is(err, rv64.ErrLimit):
+				reason = "does not terminate"
+			case err != nil:
+				reason = err.Error()
+			case m.ExitCode() != base.ExitCode():
+				reason = fmt.Sprintf("exit status %d", m.ExitCode())
+			}
+			fmt.Printf("  %-20s required (%s)\n", name, reason)
+		}
+	}
+	fmt.Printf("\nRequired (%d): %s\n", len(required), strings.Join(required, " "))
+	fmt.Printf("Optional (%d): %s\n", len(optional), strings.Join(optional, " "))
+	return nil
+}
+
+// checkDeterminism runs the program twice and compares everything a run
+// produces: output, exit status, retired instructions and the full syscall
+// trace, arguments and results included.
+func checkDeterminism(prog program, cfg linuxabi.Config) error {
+	type run struct {
+		out, trace bytes.Buffer
+		m          *rv64.Machine
+	}
+	var runs [2]run
+	for i := range runs {
+		r := &runs[i]
+		c := cfg
+		c.Stdin, c.Stdout, c.Stderr, c.Trace = nil, &r.out, &r.out, &r.trace
+		m, _, err := prog.run(c, 0)
+		if err != nil {
+			return fmt.Errorf("run %d failed: %v", i+1, err)
+		}
+		r.m = m
+	}
+	a, b := &runs[0], &runs[1]
+	if a.m.Instret != b.m.Instret {
+		return fmt.Errorf("not deterministic: %d instructions, then %d", a.m.Instret, b.m.Instret)
+	}
+	if a.m.ExitCode() != b.m.ExitCode() {
+		return fmt.Errorf("not deterministic: exit status %d, then %d", a.m.ExitCode(), b.m.ExitCode())
+	}
+	if la, lb, n, differ := firstDiff(a.trace.String(), b.trace.String()); differ {
+		return fmt.Errorf("not deterministic: syscall %d differs:\n  %s\n  %s", n, la, lb)
+	}
+	if !bytes.Equal(a.out.Bytes(), b.out.Bytes()) {
+		return fmt.Errorf("not deterministic: output differs")
+	}
+	fmt.Printf("Deterministic: %d instructions, %d syscalls, exit status %d\n",
+		a.m.Instret, strings.Count(a.trace.String(), "\n"), a.m.ExitCode())
+	return nil
+}
+
+// firstDiff returns the first differing lines of two texts and their line
+// number, counting from 1.
+func firstDiff(a, b string) (string, string, int, bool) {
+	la, lb := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for i := 0; i < len(la) || i < len(lb); i++ {
+		var x, y string
+		if i < len(la) {
+			x = la[i]
+		}
+		if i < len(lb) {
+			y = lb[i]
+		}
+		if x != y {
+			return x, y, i + 1, true
+		}
+	}
+	return "", "", 0, false
+}
+
+func printStats(w io.Writer, m *rv64.Machine, kernel *rv64.Linux, elapsed time.Duration) {
+	fmt.Fprintf(w, "instructions: %d (%.1f MIPS)\n", m.Instret, float64(m.Instret)/elapsed.Seconds()/1e6)
+	fmt.Fprintf(w, "memory:       %d KiB resident, %d MiB mapped\n", m.Mem.Resident()>>10, m.Mem.MappedBytes()>>20)
+
+	counts := kernel.ABI.Syscalls
+	nums := make([]uint64, 0, len(counts))
+	for num := range counts {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool {
+		if counts[nums[i]] != counts[nums[j]] {
+			return counts[nums[i]] > counts[nums[j]]
+		}
+		return nums[i] < nums[j]
+	})
+	fmt.Fprintf(w, "syscalls:\n")
+	for _, num := range nums {
+		fmt.Fprintf(w, "  %-20s %d\n", linuxabi.SyscallName(num), counts[num])
+	}
+
+	calls := m.Kernel.(*rv64.Precompiles).Calls
+	if len(calls) == 0 {
+		return
+	}
+	nums = nums[:0]
+	for num := range calls {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	fmt.Fprintf(w, "precompile ECALLs:\n")
+	for _, num := range nums {
+		name, _ := cryptobackend.ECallName(num)
+		fmt.Fprintf(w, "  %-20s %d\n", name, calls[num])
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "rvemu: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,203 @@
+//go:build tamago && riscv64
+
+// Package semihosting implements the subset of RISC-V semihosting needed to
+// run a bare metal program like a command line tool: host file access,
+// standard output and an exit status. Requests trap to the debugger or
+// emulator, e.g. `qemu-system-riscv64 -semihosting`, which performs them on
+// the host.
+//
+// See the RISC-V Semihosting specification,
+// https://github.com/riscv-non-isa/riscv-semihosting, and the Arm semihosting
+// operations it reuses.
+package semihosting
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"unsafe"
+)
+
+// Semihosting operation numbers.
+const (
+	SysOpen         = 0x01
+	SysClose        = 0x02
+	SysWrite        = 0x05
+	SysRead         = 0x06
+	SysFlen         = 0x0c
+	SysErrno        = 0x13
+	SysExitExtended = 0x20
+)
+
+// Open modes, as in ISO C fopen.
+const (
+	ModeRead  = 1 // "rb"
+	ModeWrite = 5 // "wb"
+)
+
+// adpStoppedApplicationExit is the SysExitExtended reason for a normal exit
+// with a status code.
+const adpStoppedApplicationExit = 0x20026
+
+// Console is the special file name for the host's standard input and output.
+const Console = ":tt"
+
+// call issues a semihosting request with a pointer to its parameter block.
+func call(op uintptr, param unsafe.Pointer) uintptr
+
+// Error is a failed semihosting request, with the host's errno.
+type Error struct {
+	Op    string
+	Errno int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("semihosting %s: errno %d", e.Op, e.Errno)
+}
+
+func hostError(op string) error {
+	return &Error{Op: op, Errno: int(call(SysErrno, nil))}
+}
+
+// Open opens a host file and returns its handle.
+func Open(path string, mode int) (int, error) {
+	name := append([]byte(path), 0)
+	param := [3]uintptr{uintptr(unsafe.Pointer(&name[0])), uintptr(mode), uintptr(len(path))}
+	fd := int(call(SysOpen, unsafe.Pointer(&param)))
+	runtime.KeepAlive(name)
+	if fd == -1 {
+		return -1, hostError("open " + path)
+	}
+	return fd, nil
+}
+
+// Close closes a host file handle.
+func Close(fd int) error {
+	param := [1]uintptr{uintptr(fd)}
+	if call(SysClose, unsafe.Pointer(&param)) != 0 {
+		return hostError("close")
+	}
+	return nil
+}
+
+// Read reads up to len(b) bytes from a host file. It returns 0 at end of
+// file.
+func Read(fd int, b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	param := [3]uintptr{uintptr(fd), uintptr(unsafe.Pointer(&b[0])), uintptr(len(b))}
+	left := call(SysRead, unsafe.Pointer(&param))
+	runtime.KeepAlive(b)
+	if left > uintptr(len(b)) {
+		return 0, hostError("read")
+	}
+	return len(b) - int(left), nil
+}
+
+// Write writes b to a host file.
+func Write(fd int, b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	param := [3]uintptr{uintptr(fd), uintptr(unsafe.Pointer(&b[0])), uintptr(len(b))}
+	left := call(SysWrite, unsafe.Pointer(&param))
+	runtime.KeepAlive(b)
+	if left != 0 {
+		return len(b) - int(left), hostError("write")
+	}
+	return len(b), nil
+}
+
+// ReadFile reads a whole host file.
+func ReadFile(path string) ([]byte, error) {
+	fd, err := Open(path, ModeRead)
+	if err != nil {
+		return nil, err
+	}
+	defer Close(fd)
+
+	param := [1]uintptr{uintptr(fd)}
+	size := int(call(SysFlen, unsafe.Pointer(&param)))
+	if size < 0 {
+		return nil, hostError("flen " + path)
+	}
+	data := make([]byte, size)
+	for off := 0; off < size; {
+		n, err := Read(fd, data[off:])
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return data[:off], nil
+		}
+		off += n
+	}
+	return data, nil
+}
+
+// File is a host file opened for reading, as an io.ReadCloser.
+type File struct {
+	fd int
+}
+
+// OpenFile opens a host file for reading.
+func OpenFile(path string) (*File, error) {
+	fd, err := Open(path, ModeRead)
+	if err != nil {
+		return nil, err
+	}
+	return &File{fd}, nil
+}
+
+func (f *File) Read(b []byte) (int, error) {
+	n, err := Read(f.fd, b)
+	if err == nil && n == 0 && len(b) > 0 {
+		return 0, io.EOF
+	}
+	return n, err
+}
+
+func (f *File) Close() error {
+	return Close(f.fd)
+}
+
+// WriteFile writes data to a host file, creating or truncating it.
+func WriteFile(path string, data []byte) error {
+	fd, err := Open(path, ModeWrite)
+	if err != nil {
+		return err
+	}
+	if _, err := Write(fd, data); err != nil {
+		Close(fd)
+		return err
+	}
+	return Close(fd)
+}
+
+// Stdout is the host's standard output as an io.Writer.
+var Stdout = &console{fd: -1}
+
+type console struct {
+	fd int
+}
+
+func (c *console) Write(b []byte) (int, error) {
+	if c.fd == -1 {
+		fd, err := Open(Console, ModeWrite)
+		if err != nil {
+			return 0, err
+		}
+		c.fd = fd
+	}
+	return Write(c.fd, b)
+}
+
+// Exit ends the program with an exit status, which the host passes on as its
+// own. It does not return.
+func Exit(code int) {
+	param := [2]uintptr{adpStoppedApplicationExit, uintptr(code)}
+	call(SysExitExtended, unsafe.Pointer(&param))
+	for {
+	}
+}
@@ -0,0 +1,18 @@
+//go:build tamago && riscv64
+
+// RISC-V semihosting trap
+
+#include "textflag.h"
+
+// func call(op uintptr, param unsafe.Pointer) uintptr
+TEXT ·call(SB),NOSPLIT,$0-24
+	MOV	op+0(FP), A0
+	MOV	param+8(FP), A1
+	// The host recognises the trap by the instructions around EBREAK, which
+	// must be uncompressed and on the same page.
+	PCALIGN	$16
+	WORD	$0x01f01013	// slli zero, zero, 0x1f
+	WORD	$0x00100073	// ebreak
+	WORD	$0x40705013	// srai zero, zero, 7
+	MOV	A0, ret+16(FP)
+	RET
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Alloc is a state dump in the format of the t8n alloc, and of the alloc
+// section of assets/exp.json.
+type Alloc types.GenesisAlloc
+
+func (g Alloc) OnRoot(common.Hash) {}
+
+func (g Alloc) OnAccount(addr *common.Address, dumpAccount state.DumpAccount) {
+	if addr == nil {
+		return
+	}
+	balance, _ := new(big.Int).SetString(dumpAccount.Balance, 0)
+	var storage map[common.Hash]common.Hash
+	if dumpAccount.Storage != nil {
+		storage = make(map[common.Hash]common.Hash, len(dumpAccount.Storage))
+		for k, v := range dumpAccount.Storage {
+			storage[k] = common.HexToHash(v)
+		}
+	}
+	g[*addr] = types.Account{
+		Code:    dumpAccount.Code,
+		Storage: storage,
+		Balance: balance,
+		Nonce:   dumpAccount.Nonce,
+	}
+}
+
+// dumpAlloc dumps every account of statedb. The addresses come from the
+// trie's preimages, which MakePreState records.
+func dumpAlloc(statedb *state.StateDB) Alloc {
+	alloc := make(Alloc)
+	statedb.DumpToCollector(alloc, nil)
+	return alloc
+}
+
+// touched returns the accounts of post that the block created or changed
+// compared to pre. Accounts the block deleted have no entry in an alloc and
+// are left out.
+func (post Alloc) touched(pre types.GenesisAlloc) Alloc {
+	out := make(Alloc)
+	for addr, account := range post {
+		if old, ok := pre[addr]; !ok || !sameAccount(old, account) {
+			out[addr] = account
+		}
+	}
+	return out
+}
+
+func sameAccount(a, b types.Account) bool {
+	if a.Nonce != b.Nonce || !bytes.Equal(a.Code, b.Code) || bigOrZero(a.Balance).Cmp(bigOrZero(b.Balance)) != 0 {
+		return false
+	}
+	for k, v := range a.Storage {
+		if b.Storage[k] != v {
+			return false
+		}
+	}
+	for k, v := range b.Storage {
+		if a.Storage[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func bigOrZero(n *big.Int) *big.Int {
+	if n == nil {
+		return new(big.Int)
+	}
+	return n
+}
+
+// saveOutput writes v as indented JSON to path, or to stdout for "-".
+func saveOutput(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return NewError(ErrorJson, fmt.Errorf("failed marshalling output: %v", err))
+	}
+	if path == "-" {
+		_, err = fmt.Fprintf(stdout, "%s\n", data)
+	} else {
+		err = writeFile(path, data)
+	}
+	if err != nil {
+		return NewError(ErrorIO, fmt.Errorf("failed writing output %s: %v", path, err))
+	}
+	return nil
+}
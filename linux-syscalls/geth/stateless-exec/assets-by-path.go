@@ -1,63 +1,73 @@
+//go:build !tamago || semihosting
+
 package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"os"
 )
 
-
-func obtainAssets(alloc_path, evn_path, tx_path string) *input {
-	
-	
-	// opening files
-	alloc_file, err := os.Open(alloc_path)
-	if err != nil {
-		panic(fmt.Sprintf("Could not open %s: %v", alloc_path, err))
+// obtainInput reads the input from the JSON files under ./assets, with the
+// pre-state from the -proofs file instead of alloc.json if one is given. The
+// transactions are left to loadTransactions, which streams them from the
+// returned file name (it may be RLP rather than JSON). With -input, the whole
+// input object comes from that file instead, and the transactions with it.
+func obtainInput() (*input, string, error) {
+	if *inputFile != "" {
+		if *proofsFile != "" {
+			return nil, "", NewError(ErrorConfig, errors.New("-input carries the proofs in the input object and cannot be combined with -proofs"))
+		}
+		data, err := readFile(*inputFile)
+		if err != nil {
+			return nil, "", NewError(ErrorIO, fmt.Errorf("could not read %s: %v", *inputFile, err))
+		}
+		inputData, err := decodeWholeInput(data, *inputFile)
+		return inputData, stdinSelector, err
 	}
-	defer alloc_file.Close()
-	
-	evn_file, err := os.Open(evn_path)
-	if err != nil {
-		panic(fmt.Sprintf("Could not open %s: %v", evn_path, err))
+	alloc_path := "./assets/alloc.json"
+	evn_path := "./assets/env.json"
+	tx_path := "./assets/tx.json"
+	if *proofsFile != "" {
+		alloc_path = ""
 	}
-	defer evn_file.Close()
-	
-	tx_file, err := os.Open(tx_path)
-	if err != nil {
-		panic(fmt.Sprintf("Could not open %s: %v", tx_path, err))
+
+	inputData, err := obtainAssets(alloc_path, evn_path)
+	if err == nil && *proofsFile != "" {
+		inputData.Proofs, err = readProofs(*proofsFile)
 	}
-	defer tx_file.Close()
-	
-	
+	return inputData, tx_path, err
+}
+
+func obtainAssets(alloc_path, evn_path string) (*input, error) {
+
+
 	// reading the file contents
-	alloc_data, err := io.ReadAll(alloc_file)
-	if err != nil {
-		panic(fmt.Sprintf("Could not read %s: %v", alloc_path, err))
-	}
-	evn_data, err := io.ReadAll(evn_file)
-	if err != nil {
-		panic(fmt.Sprintf("Could not read %s: %v", evn_path, err))
+	var alloc_data []byte
+	if alloc_path != "" {
+		var err error
+		alloc_data, err = readFile(alloc_path)
+		if err != nil {
+			return nil, NewError(ErrorIO, fmt.Errorf("could not read %s: %v", alloc_path, err))
+		}
 	}
-	tx_data, err := io.ReadAll(tx_file)
+	evn_data, err := readFile(evn_path)
 	if err != nil {
-		panic(fmt.Sprintf("Could not read %s: %v", tx_path, err))
+		return nil, NewError(ErrorIO, fmt.Errorf("could not read %s: %v", evn_path, err))
 	}
-	
+
 	// parsing the Json content
 	var inputOut input
-	if err := json.Unmarshal(alloc_data, &inputOut.Alloc); err != nil {
-		panic(fmt.Sprintf("Could not parse %s: %v", alloc_path, err))
+	if alloc_data != nil {
+		if err := json.Unmarshal(alloc_data, &inputOut.Alloc); err != nil {
+			return nil, NewError(ErrorJson, fmt.Errorf("could not parse %s: %v", alloc_path, err))
+		}
 	}
 	if err := json.Unmarshal(evn_data, &inputOut.Env); err != nil {
-		panic(fmt.Sprintf("Could not parse %s: %v", evn_path, err))
+		return nil, NewError(ErrorJson, fmt.Errorf("could not parse %s: %v", evn_path, err))
 	}
-	if err := json.Unmarshal(tx_data, &inputOut.Txs); err != nil {
-		panic(fmt.Sprintf("Could not parse %s: %v", tx_path, err))
-	}
-	
-	
-	
-	return &inputOut
-}
\ No newline at end of file
+
+
+
+	return &inputOut, nil
+}
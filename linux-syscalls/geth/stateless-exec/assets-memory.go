@@ -0,0 +1,54 @@
+//go:build tamago && !semihosting
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"unsafe"
+)
+
+// inputAddr is the physical address of the input region. The host places the
+// input there before starting the guest, e.g. with QEMU's
+// `-device loader,file=input.bin,addr=0xa0000000`. The default lies just past
+// the 512MB of RAM handed to the Go runtime, so the heap never overlaps it.
+// It can be moved at link time with `-ldflags "-X main.inputAddr=<addr>"`.
+var inputAddr = "0xa0000000"
+
+// maxInputSize bounds the length prefix, so that an address with nothing
+// loaded at it fails cleanly instead of reading garbage.
+const maxInputSize = 256 << 20
+
+// inputBlob returns the input region: a little-endian uint64 length followed
+// by that many bytes of input, JSON or compact. The bytes are not copied.
+func inputBlob() ([]byte, error) {
+	addr, err := strconv.ParseUint(inputAddr, 0, 64)
+	if err != nil {
+		return nil, NewError(ErrorConfig, fmt.Errorf("invalid input address %q: %v", inputAddr, err))
+	}
+	header := unsafe.Slice((*byte)(unsafe.Pointer(uintptr(addr))), 8)
+	size := binary.LittleEndian.Uint64(header)
+	if size == 0 || size > maxInputSize {
+		return nil, NewError(ErrorIO, fmt.Errorf("no input at %#x (length %d)", addr, size))
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(uintptr(addr+8))), size), nil
+}
+
+// obtainAssetsFromMemory parses the input region as a t8n-style input object
+// with alloc, env and txs (or txsRlp), or as a StatelessInput, in JSON or the
+// compact encoding.
+func obtainAssetsFromMemory() (*input, error) {
+	blob, err := inputBlob()
+	if err != nil {
+		return nil, err
+	}
+	return decodeWholeInput(blob, "input region at "+inputAddr)
+}
+
+// obtainInput reads the input from memory. The transactions come with it, so
+// they are loaded from the parsed input rather than from a file.
+func obtainInput() (*input, string, error) {
+	inputData, err := obtainAssetsFromMemory()
+	return inputData, stdinSelector, err
+}
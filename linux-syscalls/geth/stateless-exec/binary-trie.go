@@ -0,0 +1,693 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/trienode"
+	"github.com/ethereum/go-ethereum/trie/utils"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+
+	"github.com/eth-act/riscv-compilation/cryptobackend"
+)
+
+// The EIP-7864 tree layout. An account's header, its first 64 storage slots
+// and its first 128 code chunks share one stem; the rest of its storage and
+// code is spread over further stems.
+const (
+	binaryStemLength = 31
+	binaryStemWidth  = 256
+
+	basicDataLeafKey    = 0
+	codeHashLeafKey     = 1
+	headerStorageOffset = 64
+	codeOffset          = 128
+
+	basicDataCodeSizeOffset = 5
+	basicDataNonceOffset    = 8
+	basicDataBalanceOffset  = 16
+)
+
+// The first byte of a serialized node.
+const (
+	binaryStemNodeType     = 1
+	binaryInternalNodeType = 2
+)
+
+var errBinaryTrieUnsupported = errors.New("not supported by the binary trie")
+
+// binaryStem returns the stem of the subtree treeIndex of addr:
+// sha256(addr left-padded to 32 bytes ‖ treeIndex little-endian)[:31].
+func binaryStem(addr common.Address, treeIndex *uint256.Int) []byte {
+	var buf [64]byte
+	copy(buf[12:32], addr[:])
+	index := treeIndex.Bytes32()
+	for i := range index {
+		buf[63-i] = index[i]
+	}
+	h := cryptobackend.Default.Sha256(buf[:])
+	return h[:binaryStemLength]
+}
+
+func accountStem(addr common.Address) []byte {
+	return binaryStem(addr, new(uint256.Int))
+}
+
+// storageKey returns the stem and leaf of a storage slot. Slots past the
+// header start at MAIN_STORAGE_OFFSET = 256^31.
+func storageKey(addr common.Address, slot []byte) ([]byte, byte) {
+	key := new(uint256.Int).SetBytes(slot)
+	if key.LtUint64(codeOffset - headerStorageOffset) {
+		return accountStem(addr), byte(headerStorageOffset + key.Uint64())
+	}
+	leaf := byte(key.Uint64())
+	treeIndex := key.Rsh(key, 8)
+	treeIndex.Add(treeIndex, new(uint256.Int).Lsh(uint256.NewInt(1), 240))
+	return binaryStem(addr, treeIndex), leaf
+}
+
+// binaryHash is the tree hash: sha256, except that two empty subtrees hash to
+// zero.
+func binaryHash(data []byte) common.Hash {
+	if len(data) == 64 && bytes.Equal(data, make([]byte, 64)) {
+		return common.Hash{}
+	}
+	return cryptobackend.Default.Sha256(data)
+}
+
+// binaryNode is a node of the tree: nil for an empty subtree, a
+// *binaryInternalNode, a *binaryStemNode, or a binaryHashedNode that has not
+// been read from the database yet.
+type binaryNode interface{}
+
+type binaryInternalNode struct {
+	left, right binaryNode
+	hash        *common.Hash // nil if the node changed since it was hashed
+	dirty       bool         // not yet in the database
+}
+
+// binaryStemNode holds the 256 values sharing a stem, each 32 bytes or nil.
+type binaryStemNode struct {
+	stem   []byte
+	values [binaryStemWidth][]byte
+	hash   *common.Hash
+	dirty  bool
+}
+
+type binaryHashedNode common.Hash
+
+// stemBit returns bit depth of stem, most significant bit first.
+func stemBit(stem []byte, depth int) byte {
+	return stem[depth/8] >> (7 - depth%8) & 1
+}
+
+func (n *binaryStemNode) computeHash() common.Hash {
+	var level [binaryStemWidth]common.Hash
+	for i, v := range n.values {
+		if v != nil {
+			level[i] = binaryHash(v)
+		}
+	}
+	var pair [64]byte
+	for width := binaryStemWidth / 2; width > 0; width /= 2 {
+		for i := 0; i < width; i++ {
+			copy(pair[:32], level[2*i][:])
+			copy(pair[32:], level[2*i+1][:])
+			level[i] = binaryHash(pair[:])
+		}
+	}
+	data := make([]byte, 0, binaryStemLength+1+common.HashLength)
+	data = append(data, n.stem...)
+	data = append(data, 0)
+	data = append(data, level[0][:]...)
+	return binaryHash(data)
+}
+
+func hashBinaryNode(n binaryNode) common.Hash {
+	switch n := n.(type) {
+	case binaryHashedNode:
+		return common.Hash(n)
+	case *binaryInternalNode:
+		if n.hash == nil {
+			var pair [64]byte
+			left, right := hashBinaryNode(n.left), hashBinaryNode(n.right)
+			copy(pair[:32], left[:])
+			copy(pair[32:], right[:])
+			h := binaryHash(pair[:])
+			n.hash = &h
+		}
+		return *n.hash
+	case *binaryStemNode:
+		if n.hash == nil {
+			h := n.computeHash()
+			n.hash = &h
+		}
+		return *n.hash
+	}
+	return common.Hash{}
+}
+
+// encodeBinaryNode serializes a hashed node. An internal node is its type and
+// the hashes of its children; a stem node is its type, the stem, a bitmap of
+// the values present and those values.
+func encodeBinaryNode(n binaryNode) []byte {
+	switch n := n.(type) {
+	case *binaryInternalNode:
+		blob := make([]byte, 1, 1+2*common.HashLength)
+		blob[0] = binaryInternalNodeType
+		left, right := hashBinaryNode(n.left), hashBinaryNode(n.right)
+		blob = append(blob, left[:]...)
+		return append(blob, right[:]...)
+	case *binaryStemNode:
+		var bitmap [binaryStemWidth / 8]byte
+		count := 0
+		for i, v := range n.values {
+			if v != nil {
+				bitmap[i/8] |= 1 << (7 - i%8)
+				count++
+			}
+		}
+		blob := make([]byte, 0, 1+binaryStemLength+len(bitmap)+count*32)
+		blob = append(blob, binaryStemNodeType)
+		blob = append(blob, n.stem...)
+		blob = append(blob, bitmap[:]...)
+		for _, v := range n.values {
+			blob = append(blob, v...)
+		}
+		return blob
+	}
+	panic(fmt.Sprintf("cannot encode binary trie node %T", n))
+}
+
+func decodeBinaryNode(blob []byte, hash common.Hash) (binaryNode, error) {
+	child := func(h []byte) binaryNode {
+		if common.BytesToHash(h) == (common.Hash{}) {
+			return nil
+		}
+		return binaryHashedNode(common.BytesToHash(h))
+	}
+	switch {
+	case len(blob) == 1+2*common.HashLength && blob[0] == binaryInternalNodeType:
+		return &binaryInternalNode{
+			left:  child(blob[1 : 1+common.HashLength]),
+			right: child(blob[1+common.HashLength:]),
+			hash:  &hash,
+		}, nil
+	case len(blob) >= 1+binaryStemLength+binaryStemWidth/8 && blob[0] == binaryStemNodeType:
+		n := &binaryStemNode{stem: common.CopyBytes(blob[1 : 1+binaryStemLength]), hash: &hash}
+		bitmap := blob[1+binaryStemLength : 1+binaryStemLength+binaryStemWidth/8]
+		values := blob[1+binaryStemLength+binaryStemWidth/8:]
+		for i := range n.values {
+			if bitmap[i/8]&(1<<(7-i%8)) == 0 {
+				continue
+			}
+			if len(values) < 32 {
+				return nil, fmt.Errorf("binary trie node %x: truncated stem node", hash)
+			}
+			n.values[i], values = values[:32:32], values[32:]
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("binary trie node %x: invalid encoding", hash)
+}
+
+// binaryTrie is an EIP-7864 binary trie holding accounts, storage and code
+// in one tree. It implements state.Trie, so a StateDB can run on it.
+type binaryTrie struct {
+	db   *binaryDatabase
+	mu   sync.Mutex // StateDB commits the same tree from several goroutines
+	root binaryNode
+}
+
+func newBinaryTrie(root common.Hash, db *binaryDatabase) *binaryTrie {
+	t := &binaryTrie{db: db}
+	if root != (common.Hash{}) {
+		t.root = binaryHashedNode(root)
+	}
+	return t
+}
+
+func (t *binaryTrie) resolve(n binaryHashedNode) (binaryNode, error) {
+	blob, err := t.db.node(common.Hash(n))
+	if err != nil {
+		return nil, err
+	}
+	return decodeBinaryNode(blob, common.Hash(n))
+}
+
+// get returns the stem node of stem, or nil if the tree has none.
+func (t *binaryTrie) get(stem []byte) (*binaryStemNode, error) {
+	var (
+		found *binaryStemNode
+		err   error
+	)
+	t.root, found, err = t.lookup(t.root, stem, 0)
+	return found, err
+}
+
+// lookup finds stem under n, and returns n with the nodes read on the way
+// resolved.
+func (t *binaryTrie) lookup(n binaryNode, stem []byte, depth int) (binaryNode, *binaryStemNode, error) {
+	switch n := n.(type) {
+	case nil:
+		return nil, nil, nil
+	case binaryHashedNode:
+		resolved, err := t.resolve(n)
+		if err != nil {
+			return n, nil, err
+		}
+		return t.lookup(resolved, stem, depth)
+	case *binaryInternalNode:
+		var (
+			found *binaryStemNode
+			err   error
+		)
+		if stemBit(stem, depth) == 0 {
+			n.left, found, err = t.lookup(n.left, stem, depth+1)
+		} else {
+			n.right, found, err = t.lookup(n.right, stem, depth+1)
+		}
+		return n, found, err
+	case *binaryStemNode:
+		if bytes.Equal(n.stem, stem) {
+			return n, n, nil
+		}
+		return n, nil, nil
+	}
+	return n, nil, fmt.Errorf("unknown binary trie node %T", n)
+}
+
+// update applies set to the values of stem, creating the stem node if needed.
+func (t *binaryTrie) update(stem []byte, set func(values *[binaryStemWidth][]byte)) error {
+	var err error
+	t.root, err = t.insert(t.root, stem, 0, set)
+	return err
+}
+
+func (t *binaryTrie) insert(n binaryNode, stem []byte, depth int, set func(values *[binaryStemWidth][]byte)) (binaryNode, error) {
+	switch n := n.(type) {
+	case nil:
+		leaf := &binaryStemNode{stem: stem, dirty: true}
+		set(&leaf.values)
+		return leaf, nil
+	case binaryHashedNode:
+		resolved, err := t.resolve(n)
+		if err != nil {
+			return n, err
+		}
+		return t.insert(resolved, stem, depth, set)
+	case *binaryInternalNode:
+		var err error
+		if stemBit(stem, depth) == 0 {
+			n.left, err = t.insert(n.left, stem, depth+1, set)
+		} else {
+			n.right, err = t.insert(n.right, stem, depth+1, set)
+		}
+		n.hash, n.dirty = nil, true
+		return n, err
+	case *binaryStemNode:
+		if bytes.Equal(n.stem, stem) {
+			set(&n.values)
+			n.hash, n.dirty = nil, true
+			return n, nil
+		}
+		// Push the existing stem one level down, and keep splitting until
+		// the two stems diverge.
+		split := &binaryInternalNode{dirty: true}
+		if stemBit(n.stem, depth) == 0 {
+			split.left = n
+		} else {
+			split.right = n
+		}
+		return t.insert(split, stem, depth, set)
+	}
+	return n, fmt.Errorf("unknown binary trie node %T", n)
+}
+
+// store writes the nodes that changed since the last commit to the database.
+func (t *binaryTrie) store(n binaryNode) {
+	switch n := n.(type) {
+	case *binaryInternalNode:
+		if n.dirty {
+			t.store(n.left)
+			t.store(n.right)
+			t.db.put(hashBinaryNode(n), encodeBinaryNode(n))
+			n.dirty = false
+		}
+	case *binaryStemNode:
+		if n.dirty {
+			t.db.put(hashBinaryNode(n), encodeBinaryNode(n))
+			n.dirty = false
+		}
+	}
+}
+
+// GetKey returns nil: the tree keys are hashes of the address and position,
+// and no preimages are kept.
+func (t *binaryTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// GetAccount returns the account of addr, or nil if it does not exist or was
+// deleted.
+func (t *binaryTrie) GetAccount(addr common.Address) (*types.StateAccount, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	leaf, err := t.get(accountStem(addr))
+	if err != nil || leaf == nil {
+		return nil, err
+	}
+	basicData, codeHash := leaf.values[basicDataLeafKey], leaf.values[codeHashLeafKey]
+	if basicData == nil || codeHash == nil || common.BytesToHash(codeHash) == (common.Hash{}) {
+		return nil, nil
+	}
+	return &types.StateAccount{
+		Nonce:    binary.BigEndian.Uint64(basicData[basicDataNonceOffset:]),
+		Balance:  new(uint256.Int).SetBytes(basicData[basicDataBalanceOffset:]),
+		Root:     types.EmptyRootHash, // storage lives in the same tree
+		CodeHash: common.CopyBytes(codeHash),
+	}, nil
+}
+
+// GetStorage returns the value of a storage slot, without leading zeroes.
+func (t *binaryTrie) GetStorage(addr common.Address, key []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stem, leaf := storageKey(addr, key)
+	n, err := t.get(stem)
+	if err != nil || n == nil {
+		return nil, err
+	}
+	return common.TrimLeftZeroes(n.values[leaf]), nil
+}
+
+// UpdateAccount writes the basic data leaf (code size, nonce and balance) and
+// the code hash leaf of addr.
+func (t *binaryTrie) UpdateAccount(addr common.Address, account *types.StateAccount, codeLen int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if account.Balance.ByteLen() > 16 {
+		return fmt.Errorf("account %x: balance does not fit the basic data leaf", addr)
+	}
+	basicData := make([]byte, 32)
+	// The code size is 3 bytes; the 4-byte write spills into the reserved
+	// byte before it.
+	binary.BigEndian.PutUint32(basicData[basicDataCodeSizeOffset-1:], uint32(codeLen))
+	binary.BigEndian.PutUint64(basicData[basicDataNonceOffset:], account.Nonce)
+	account.Balance.WriteToSlice(basicData[basicDataBalanceOffset:])
+	codeHash := common.CopyBytes(account.CodeHash)
+	return t.update(accountStem(addr), func(values *[binaryStemWidth][]byte) {
+		values[basicDataLeafKey] = basicData
+		values[codeHashLeafKey] = codeHash
+	})
+}
+
+// UpdateStorage writes a storage slot, left-padded to 32 bytes.
+func (t *binaryTrie) UpdateStorage(addr common.Address, key, value []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stem, leaf := storageKey(addr, key)
+	t.db.addStorageStem(addr, stem)
+	padded := common.LeftPadBytes(value, 32)
+	return t.update(stem, func(values *[binaryStemWidth][]byte) {
+		values[leaf] = padded
+	})
+}
+
+// DeleteAccount zeroes the account's header and storage. The tree has no
+// deletion, so the leaves stay and GetAccount treats a zero code hash as a
+// deleted account.
+//
+// StateDB leaves the storage of a deleted account to the trie when it is a
+// single tree, and the slots hash to stems that cannot be told apart from
+// any other, so the database keeps the stems each address has written.
+func (t *binaryTrie) DeleteAccount(addr common.Address) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stem := accountStem(addr)
+	err := t.update(stem, func(values *[binaryStemWidth][]byte) {
+		values[basicDataLeafKey] = make([]byte, 32)
+		values[codeHashLeafKey] = make([]byte, 32)
+		zeroValues(values[headerStorageOffset:codeOffset])
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range t.db.takeStorageStems(addr) {
+		if bytes.Equal(s, stem) {
+			continue
+		}
+		if err := t.update(s, func(values *[binaryStemWidth][]byte) {
+			zeroValues(values[:])
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zeroValues zeroes the values that are set.
+func zeroValues(values [][]byte) {
+	for i, v := range values {
+		if v != nil {
+			values[i] = make([]byte, 32)
+		}
+	}
+}
+
+// DeleteStorage zeroes a storage slot.
+func (t *binaryTrie) DeleteStorage(addr common.Address, key []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stem, leaf := storageKey(addr, key)
+	return t.update(stem, func(values *[binaryStemWidth][]byte) {
+		values[leaf] = make([]byte, 32)
+	})
+}
+
+// UpdateContractCode writes the code in 31-byte chunks, each prefixed with
+// the number of leading bytes that are PUSH data.
+func (t *binaryTrie) UpdateContractCode(addr common.Address, _ common.Hash, code []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var (
+		chunks = trie.ChunkifyCode(code)
+		stem   []byte
+	)
+	for i := 0; i < len(chunks); i += 32 {
+		pos := codeOffset + uint64(i/32)
+		if stem == nil || pos%binaryStemWidth == 0 {
+			stem = binaryStem(addr, uint256.NewInt(pos/binaryStemWidth))
+		}
+		chunk := chunks[i : i+32]
+		if err := t.update(stem, func(values *[binaryStemWidth][]byte) {
+			values[pos%binaryStemWidth] = chunk
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hash returns the root hash of the tree.
+func (t *binaryTrie) Hash() common.Hash {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return hashBinaryNode(t.root)
+}
+
+// Commit writes the changed nodes straight to the database, so it returns no
+// node set. StateDB calls it once for the account trie and once per storage
+// trie, which are all this tree, so later calls find nothing to write.
+func (t *binaryTrie) Commit(bool) (common.Hash, *trienode.NodeSet) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	root := hashBinaryNode(t.root)
+	t.store(t.root)
+	return root, nil
+}
+
+// Witness returns every node read from the database. Reads of the state go
+// through the database's reader rather than this tree, so the set is kept by
+// the database.
+func (t *binaryTrie) Witness() map[string]struct{} {
+	return t.db.witness()
+}
+
+func (t *binaryTrie) NodeIterator([]byte) (trie.NodeIterator, error) {
+	return nil, errBinaryTrieUnsupported
+}
+
+func (t *binaryTrie) Prove([]byte, ethdb.KeyValueWriter) error {
+	return errBinaryTrieUnsupported
+}
+
+// IsVerkle reports true: like a verkle tree, the binary trie is a single tree
+// for accounts and storage.
+func (t *binaryTrie) IsVerkle() bool {
+	return true
+}
+
+// binaryDatabase is a state.Database keeping the state in a binary trie. The
+// nodes live in memory; code goes to disk like in the MPT database.
+type binaryDatabase struct {
+	disk ethdb.Database
+	// StateDB only asks the trie database whether it holds a verkle tree,
+	// which makes it keep one tree for accounts and storage.
+	triedb *triedb.Database
+
+	mu       sync.Mutex
+	nodes    map[common.Hash][]byte
+	accessed map[string]struct{}
+	// storageStems holds the stems each address has written storage to.
+	// Binary trie states are built from an alloc in this database, so
+	// every slot goes through UpdateStorage.
+	storageStems map[common.Address]map[string]struct{}
+}
+
+func newBinaryDatabase(disk ethdb.Database) *binaryDatabase {
+	return &binaryDatabase{
+		disk:         disk,
+		triedb:       triedb.NewDatabase(disk, &triedb.Config{IsVerkle: true}),
+		nodes:        make(map[common.Hash][]byte),
+		accessed:     make(map[string]struct{}),
+		storageStems: make(map[common.Address]map[string]struct{}),
+	}
+}
+
+func (db *binaryDatabase) node(hash common.Hash) ([]byte, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	blob, ok := db.nodes[hash]
+	if !ok {
+		return nil, fmt.Errorf("binary trie node %x not found", hash)
+	}
+	db.accessed[string(blob)] = struct{}{}
+	return blob, nil
+}
+
+func (db *binaryDatabase) put(hash common.Hash, blob []byte) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.nodes[hash] = blob
+}
+
+func (db *binaryDatabase) addStorageStem(addr common.Address, stem []byte) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	stems := db.storageStems[addr]
+	if stems == nil {
+		stems = make(map[string]struct{})
+		db.storageStems[addr] = stems
+	}
+	stems[string(stem)] = struct{}{}
+}
+
+// takeStorageStems returns the stems addr has written storage to and forgets
+// them.
+func (db *binaryDatabase) takeStorageStems(addr common.Address) [][]byte {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var stems [][]byte
+	for stem := range db.storageStems[addr] {
+		stems = append(stems, []byte(stem))
+	}
+	delete(db.storageStems, addr)
+	return stems
+}
+
+func (db *binaryDatabase) witness() map[string]struct{} {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	witness := make(map[string]struct{}, len(db.accessed))
+	for blob := range db.accessed {
+		witness[blob] = struct{}{}
+	}
+	return witness
+}
+
+func (db *binaryDatabase) Reader(root common.Hash) (state.Reader, error) {
+	return &binaryReader{db: db, trie: newBinaryTrie(root, db)}, nil
+}
+
+func (db *binaryDatabase) OpenTrie(root common.Hash) (state.Trie, error) {
+	return newBinaryTrie(root, db), nil
+}
+
+// OpenStorageTrie returns the account trie, which also holds the storage.
+func (db *binaryDatabase) OpenStorageTrie(_ common.Hash, _ common.Address, _ common.Hash, self state.Trie) (state.Trie, error) {
+	return self, nil
+}
+
+func (db *binaryDatabase) PointCache() *utils.PointCache {
+	return nil
+}
+
+func (db *binaryDatabase) TrieDB() *triedb.Database {
+	return db.triedb
+}
+
+func (db *binaryDatabase) Snapshot() *snapshot.Tree {
+	return nil
+}
+
+// binaryReader reads the state at a root through a tree of its own.
+type binaryReader struct {
+	db   *binaryDatabase
+	trie *binaryTrie
+}
+
+func (r *binaryReader) Account(addr common.Address) (*types.StateAccount, error) {
+	return r.trie.GetAccount(addr)
+}
+
+func (r *binaryReader) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	value, err := r.trie.GetStorage(addr, slot[:])
+	return common.BytesToHash(value), err
+}
+
+func (r *binaryReader) Code(_ common.Address, codeHash common.Hash) ([]byte, error) {
+	return rawdb.ReadCode(r.db.disk, codeHash), nil
+}
+
+func (r *binaryReader) CodeSize(addr common.Address, codeHash common.Hash) (int, error) {
+	code, err := r.Code(addr, codeHash)
+	return len(code), err
+}
+
+// binaryStateDB is the StateDB as the EVM sees it with the binary trie. A
+// StateDB on a single tree keeps verkle access events, but no EIP-4762 fork
+// is active, so the EVM never records any and the system calls would merge a
+// nil set. Hiding the events skips the merge.
+type binaryStateDB struct {
+	*state.StateDB
+}
+
+func (binaryStateDB) AccessEvents() *state.AccessEvents {
+	return nil
+}
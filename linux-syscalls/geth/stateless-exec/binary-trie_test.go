@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/holiman/uint256"
+)
+
+var binaryTestAddr = common.HexToAddress("0xa94f5374fce5edbc8e2a8697c15331677e6ebf0b")
+
+// The keys are get_tree_key, get_tree_key_for_storage_slot and
+// get_tree_key_for_code_chunk of the EIP-7864 reference implementation.
+func TestBinaryTreeKeys(t *testing.T) {
+	key := func(stem []byte, leaf byte) string {
+		return common.Bytes2Hex(append(common.CopyBytes(stem), leaf))
+	}
+	slotKey := func(slot string) string {
+		return key(storageKey(binaryTestAddr, common.HexToHash(slot).Bytes()))
+	}
+	tests := []struct{ name, key, want string }{
+		{"basic data", key(accountStem(binaryTestAddr), basicDataLeafKey), "5710ea38144c3fa7c1a5bbb8ee1f57a936829f110688e9eded1ae50a5921a800"},
+		{"code chunk 128", key(binaryStem(binaryTestAddr, uint256.NewInt(1)), 0), "e77993967a45cb2c575f8797f960e9da942f94ab57d61380c60ec15d05839000"},
+		{"slot 0", slotKey("0x00"), "5710ea38144c3fa7c1a5bbb8ee1f57a936829f110688e9eded1ae50a5921a840"},
+		{"slot 63", slotKey("0x3f"), "5710ea38144c3fa7c1a5bbb8ee1f57a936829f110688e9eded1ae50a5921a87f"},
+		{"slot 64", slotKey("0x40"), "69920adb0bb65bc7235eeb1e59a255a3a2589efa63ccf6e9813d381c9d2b5640"},
+		{"slot 2^256-1", slotKey("0xffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"), "0abe4046b5b878011fb8baca9231310f15d72afccf4684c86fcb1621254a9cff"},
+	}
+	for _, tt := range tests {
+		if tt.key != tt.want {
+			t.Errorf("%s: key %s, want %s", tt.name, tt.key, tt.want)
+		}
+	}
+}
+
+// The single and two entry roots are the vectors of the EIP-7864 reference
+// tests; the account root follows the basic data layout of the EIP.
+func TestBinaryTrieRoot(t *testing.T) {
+	value := func(b byte) []byte {
+		v := make([]byte, 32)
+		for i := range v {
+			v[i] = b
+		}
+		return v
+	}
+	set := func(tr *binaryTrie, key []byte, v []byte) {
+		if err := tr.update(key[:binaryStemLength], func(values *[binaryStemWidth][]byte) {
+			values[key[binaryStemLength]] = v
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db := newBinaryDatabase(rawdb.NewMemoryDatabase())
+
+	tr := newBinaryTrie(common.Hash{}, db)
+	if root := tr.Hash(); root != (common.Hash{}) {
+		t.Errorf("empty tree: root %s, want zero", root)
+	}
+	set(tr, make([]byte, 32), value(1))
+	if root, want := tr.Hash(), common.HexToHash("aab1060e04cb4f5dc6f697ae93156a95714debbf77d54238766adc5709282b6f"); root != want {
+		t.Errorf("single entry: root %s, want %s", root, want)
+	}
+	set(tr, append([]byte{0x80}, make([]byte, 31)...), value(2))
+	if root, want := tr.Hash(), common.HexToHash("dfc69c94013a8b3c65395625a719a87534a7cfd38719251ad8c8ea7fe79f065e"); root != want {
+		t.Errorf("two entries differing in the first bit: root %s, want %s", root, want)
+	}
+
+	tr = newBinaryTrie(common.Hash{}, db)
+	account := &types.StateAccount{Nonce: 1, Balance: uint256.NewInt(1e18), CodeHash: value(0x11)}
+	if err := tr.UpdateAccount(binaryTestAddr, account, 3); err != nil {
+		t.Fatal(err)
+	}
+	for slot, v := range map[byte]byte{0: 0x2a, 64: 0x2b} {
+		if err := tr.UpdateStorage(binaryTestAddr, common.Hash{31: slot}.Bytes(), []byte{v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if root, want := tr.Hash(), common.HexToHash("ae7a64f292922bd08791f619cc7a0dbd1ad7b68803949104586471b3346c1235"); root != want {
+		t.Errorf("account: root %s, want %s", root, want)
+	}
+}
+
+// A self-destructed account must take its storage with it, in the header
+// slots and in the main storage stems, or a later read or the state root
+// would still see it.
+func TestBinaryTrieDeleteAccountStorage(t *testing.T) {
+	var (
+		header = common.Hash{31: 1}
+		main   = common.Hash{31: 0x80}
+		alloc  = types.GenesisAlloc{
+			binaryTestAddr: {
+				Balance: big.NewInt(1),
+				Code:    []byte{0x60, 0x00, 0xff},
+				Storage: map[common.Hash]common.Hash{header: {31: 1}, main: {31: 2}},
+			},
+		}
+	)
+	// Zeroing the slots before the self-destruct leaves the same leaves behind.
+	want, err := MakePreState(rawdb.NewMemoryDatabase(), alloc, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.SetState(binaryTestAddr, header, common.Hash{})
+	want.SetState(binaryTestAddr, main, common.Hash{})
+	want.IntermediateRoot(true)
+	want.SelfDestruct(binaryTestAddr)
+	wantRoot := want.IntermediateRoot(true)
+
+	statedb, err := MakePreState(rawdb.NewMemoryDatabase(), alloc, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statedb.SelfDestruct(binaryTestAddr)
+	if root := statedb.IntermediateRoot(true); root != wantRoot {
+		t.Errorf("root after self-destruct %s, want %s", root, wantRoot)
+	}
+	tr := statedb.GetTrie()
+	for _, slot := range []common.Hash{header, main} {
+		if v, err := tr.GetStorage(binaryTestAddr, slot[:]); err != nil || len(v) != 0 {
+			t.Errorf("slot %x after self-destruct: %x, %v", slot, v, err)
+		}
+	}
+}
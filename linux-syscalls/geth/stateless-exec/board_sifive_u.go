@@ -0,0 +1,7 @@
+//go:build tamago && sifive_u
+
+package main
+
+import (
+	_ "github.com/usbarmory/tamago/board/qemu/sifive_u"
+)
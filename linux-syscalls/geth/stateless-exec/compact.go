@@ -0,0 +1,879 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+// The compact encoding of the inputs is RLP. A t8n input object is
+//
+//	[alloc, env, txs, proofs]
+//
+// and a StatelessInput is [block, witness], both in their consensus
+// encodings. Values that may be unset are written as an empty item of the
+// other kind: an empty list for a missing number or hash, and an empty string
+// for a missing list. The decoders walk the bytes with rlp.Split and build
+// every value by hand, without reflection, and keep byte values as slices of
+// the input.
+const (
+	absentValue = 0xc0
+	absentList  = 0x80
+)
+
+// isCompact reports whether b holds the compact encoding rather than JSON: an
+// RLP list starts at 0xc0, a JSON object with '{' or whitespace. Any other
+// first byte is an error. So is a UTF-8 byte order mark, whose first byte
+// would otherwise read as a list prefix.
+func isCompact(b []byte) (bool, error) {
+	if len(b) == 0 {
+		return false, errors.New("empty input")
+	}
+	if bytes.HasPrefix(b, utf8BOM) {
+		return false, errors.New("input starts with a UTF-8 byte order mark")
+	}
+	switch c := b[0]; {
+	case c >= 0xc0:
+		return true, nil
+	case c == '{' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		return false, nil
+	default:
+		return false, fmt.Errorf("input starts with byte %#x, neither an RLP list nor a JSON object", c)
+	}
+}
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+var errTooManyItems = errors.New("rlp: too many items in list")
+
+// rlpReader reads the items of an RLP list in order. Readers of nested lists
+// share the error of the outermost one, and the first error sticks, so a
+// decoder reads all its fields and checks the error once.
+type rlpReader struct {
+	rest []byte
+	err  *error
+}
+
+// newRLPReader returns a reader of the items of the list b holds, which must
+// be all of b.
+func newRLPReader(b []byte) *rlpReader {
+	r := &rlpReader{err: new(error)}
+	return r.sub(b)
+}
+
+// sub returns a reader of the list b holds, which must be all of b.
+func (r *rlpReader) sub(b []byte) *rlpReader {
+	content, rest, err := rlp.SplitList(b)
+	if err == nil && len(rest) > 0 {
+		err = rlp.ErrMoreThanOneValue
+	}
+	r.fail(err)
+	return &rlpReader{rest: content, err: r.err}
+}
+
+func (r *rlpReader) fail(err error) {
+	if err != nil && *r.err == nil {
+		*r.err = err
+	}
+}
+
+// next splits off the next item.
+func (r *rlpReader) next() (rlp.Kind, []byte) {
+	if *r.err != nil {
+		return rlp.String, nil
+	}
+	if len(r.rest) == 0 {
+		r.fail(rlp.EOL)
+		return rlp.String, nil
+	}
+	kind, content, rest, err := rlp.Split(r.rest)
+	if err != nil {
+		r.fail(err)
+		return rlp.String, nil
+	}
+	r.rest = rest
+	return kind, content
+}
+
+// more reports whether the list has items left.
+func (r *rlpReader) more() bool {
+	return *r.err == nil && len(r.rest) > 0
+}
+
+// end fails if the list has items left.
+func (r *rlpReader) end() {
+	if len(r.rest) > 0 {
+		r.fail(errTooManyItems)
+	}
+}
+
+// absent reports whether the next item is the marker of an unset value, and
+// skips it if so.
+func (r *rlpReader) absent(marker byte) bool {
+	if r.more() && r.rest[0] == marker {
+		r.rest = r.rest[1:]
+		return true
+	}
+	return false
+}
+
+func (r *rlpReader) list() *rlpReader {
+	kind, content := r.next()
+	if kind != rlp.List {
+		r.fail(rlp.ErrExpectedList)
+	}
+	return &rlpReader{rest: content, err: r.err}
+}
+
+func (r *rlpReader) bytes() []byte {
+	kind, content := r.next()
+	if kind == rlp.List {
+		r.fail(rlp.ErrExpectedString)
+		return nil
+	}
+	return content
+}
+
+func (r *rlpReader) fixed(n int) []byte {
+	b := r.bytes()
+	if *r.err == nil && len(b) != n {
+		r.fail(fmt.Errorf("rlp: %d bytes, want %d", len(b), n))
+		return make([]byte, n)
+	}
+	return b
+}
+
+// integer returns the bytes of a canonical integer of at most size bytes, or
+// of any size if size is negative.
+func (r *rlpReader) integer(size int) []byte {
+	b := r.bytes()
+	switch {
+	case size >= 0 && len(b) > size:
+		r.fail(rlp.ErrCanonSize)
+	case len(b) > 0 && b[0] == 0:
+		r.fail(rlp.ErrCanonInt)
+	default:
+		return b
+	}
+	return nil
+}
+
+func (r *rlpReader) uint64() uint64 {
+	var x uint64
+	for _, b := range r.integer(8) {
+		x = x<<8 | uint64(b)
+	}
+	return x
+}
+
+func (r *rlpReader) bigInt() *big.Int {
+	return new(big.Int).SetBytes(r.integer(-1))
+}
+
+func (r *rlpReader) uint256() *uint256.Int {
+	return new(uint256.Int).SetBytes(r.integer(32))
+}
+
+func (r *rlpReader) hash() common.Hash {
+	return common.BytesToHash(r.fixed(common.HashLength))
+}
+
+func (r *rlpReader) address() common.Address {
+	return common.BytesToAddress(r.fixed(common.AddressLength))
+}
+
+// to returns the recipient of a transaction that may create a contract.
+func (r *rlpReader) to() *common.Address {
+	b := r.bytes()
+	if len(b) == 0 {
+		return nil
+	}
+	if len(b) != common.AddressLength {
+		r.fail(fmt.Errorf("rlp: %d bytes, want %d", len(b), common.AddressLength))
+		return nil
+	}
+	addr := common.BytesToAddress(b)
+	return &addr
+}
+
+// word returns a 32-byte word written without its leading zeros.
+func (r *rlpReader) word() common.Hash {
+	return common.BytesToHash(r.integer(common.HashLength))
+}
+
+func (r *rlpReader) optBigInt() *big.Int {
+	if r.absent(absentValue) {
+		return nil
+	}
+	return r.bigInt()
+}
+
+func (r *rlpReader) optUint64() *uint64 {
+	if r.absent(absentValue) {
+		return nil
+	}
+	x := r.uint64()
+	return &x
+}
+
+func (r *rlpReader) optHash() *common.Hash {
+	if r.absent(absentValue) {
+		return nil
+	}
+	h := r.hash()
+	return &h
+}
+
+// encodeCompactInput returns the compact encoding of in. Transactions given
+// as txsRlp are written like the others, without a key.
+func encodeCompactInput(in *input) ([]byte, error) {
+	txs := in.Txs
+	if len(in.TxRlp) > 0 {
+		var signed types.Transactions
+		if err := rlp.DecodeBytes(common.FromHex(in.TxRlp), &signed); err != nil {
+			return nil, fmt.Errorf("invalid txsRlp: %v", err)
+		}
+		txs = make([]*txWithKey, len(signed))
+		for i, tx := range signed {
+			txs[i] = &txWithKey{tx: tx, protected: true}
+		}
+	}
+	if in.Env == nil {
+		return nil, errors.New("input has no env")
+	}
+	w := rlp.NewEncoderBuffer(nil)
+	l := w.List()
+	if in.Alloc == nil {
+		w.Write([]byte{absentList})
+	} else {
+		encodeAlloc(w, in.Alloc)
+	}
+	encodeEnv(w, in.Env)
+	txList := w.List()
+	for _, tx := range txs {
+		enc, err := tx.tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		item := w.List()
+		if tx.tx.Type() == types.LegacyTxType {
+			w.Write(enc)
+		} else {
+			w.WriteBytes(enc)
+		}
+		if tx.key != nil {
+			w.WriteBytes(crypto.FromECDSA(tx.key))
+		} else {
+			w.WriteBytes(nil)
+		}
+		w.WriteBool(tx.protected)
+		w.ListEnd(item)
+	}
+	w.ListEnd(txList)
+	if in.Proofs == nil {
+		w.Write([]byte{absentList})
+	} else if err := encodeProofs(w, in.Proofs); err != nil {
+		return nil, err
+	}
+	w.ListEnd(l)
+	return w.ToBytes(), nil
+}
+
+// decodeCompactInput decodes the compact encoding of a t8n input object.
+func decodeCompactInput(b []byte) (*input, error) {
+	var (
+		r  = newRLPReader(b)
+		in input
+	)
+	if !r.absent(absentList) {
+		in.Alloc = decodeAlloc(r.list())
+	}
+	in.Env = decodeEnv(r.list())
+	txs := r.list()
+	for txs.more() {
+		item := txs.list()
+		tx := &txWithKey{tx: decodeTx(item)}
+		if key := item.bytes(); len(key) > 0 {
+			var err error
+			if tx.key, err = crypto.ToECDSA(key); err != nil {
+				item.fail(err)
+			}
+		}
+		tx.protected = item.uint64() != 0
+		item.end()
+		in.Txs = append(in.Txs, tx)
+	}
+	if !r.absent(absentList) {
+		in.Proofs = decodeProofs(r.list())
+	}
+	r.end()
+	if *r.err != nil {
+		return nil, *r.err
+	}
+	return &in, nil
+}
+
+// encodeAlloc writes the accounts of alloc in address order, with storage
+// keys and values without their leading zeros.
+func encodeAlloc(w rlp.EncoderBuffer, alloc types.GenesisAlloc) {
+	addrs := make([]common.Address, 0, len(alloc))
+	for addr := range alloc {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+	l := w.List()
+	for _, addr := range addrs {
+		account := alloc[addr]
+		a := w.List()
+		w.WriteBytes(addr[:])
+		w.WriteUint64(account.Nonce)
+		if account.Balance != nil {
+			w.WriteBigInt(account.Balance)
+		} else {
+			w.WriteUint64(0)
+		}
+		w.WriteBytes(account.Code)
+		keys := make([]common.Hash, 0, len(account.Storage))
+		for k := range account.Storage {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+		storage := w.List()
+		for _, k := range keys {
+			v := account.Storage[k]
+			slot := w.List()
+			w.WriteBytes(bytes.TrimLeft(k[:], "\x00"))
+			w.WriteBytes(bytes.TrimLeft(v[:], "\x00"))
+			w.ListEnd(slot)
+		}
+		w.ListEnd(storage)
+		w.ListEnd(a)
+	}
+	w.ListEnd(l)
+}
+
+func decodeAlloc(r *rlpReader) types.GenesisAlloc {
+	alloc := make(types.GenesisAlloc)
+	for r.more() {
+		a := r.list()
+		addr := a.address()
+		account := types.Account{Nonce: a.uint64(), Balance: a.bigInt()}
+		if code := a.bytes(); len(code) > 0 {
+			account.Code = code
+		}
+		storage := a.list()
+		for storage.more() {
+			if account.Storage == nil {
+				account.Storage = make(map[common.Hash]common.Hash)
+			}
+			slot := storage.list()
+			k, v := slot.word(), slot.word()
+			slot.end()
+			account.Storage[k] = v
+		}
+		a.end()
+		alloc[addr] = account
+	}
+	return alloc
+}
+
+func encodeEnv(w rlp.EncoderBuffer, env *stEnv) {
+	l := w.List()
+	w.WriteBytes(env.Coinbase[:])
+	writeOptBigInt(w, env.Difficulty)
+	writeOptBigInt(w, env.Random)
+	writeOptBigInt(w, env.ParentDifficulty)
+	writeOptBigInt(w, env.ParentBaseFee)
+	w.WriteUint64(env.ParentGasUsed)
+	w.WriteUint64(env.ParentGasLimit)
+	w.WriteUint64(env.GasLimit)
+	w.WriteUint64(env.Number)
+	w.WriteUint64(env.Timestamp)
+	w.WriteUint64(env.ParentTimestamp)
+	numbers := make([]uint64, 0, len(env.BlockHashes))
+	for n := range env.BlockHashes {
+		numbers = append(numbers, uint64(n))
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	hashes := w.List()
+	for _, n := range numbers {
+		hash := env.BlockHashes[math.HexOrDecimal64(n)]
+		item := w.List()
+		w.WriteUint64(n)
+		w.WriteBytes(hash[:])
+		w.ListEnd(item)
+	}
+	w.ListEnd(hashes)
+	ommers := w.List()
+	for _, o := range env.Ommers {
+		item := w.List()
+		w.WriteUint64(o.Delta)
+		w.WriteBytes(o.Address[:])
+		w.ListEnd(item)
+	}
+	w.ListEnd(ommers)
+	if env.Withdrawals == nil {
+		w.Write([]byte{absentList})
+	} else {
+		encodeWithdrawals(w, env.Withdrawals)
+	}
+	writeOptBigInt(w, env.BaseFee)
+	w.WriteBytes(env.ParentUncleHash[:])
+	writeOptUint64(w, env.ExcessBlobGas)
+	writeOptUint64(w, env.ParentExcessBlobGas)
+	writeOptUint64(w, env.ParentBlobGasUsed)
+	writeOptHash(w, env.ParentBeaconBlockRoot)
+	w.ListEnd(l)
+}
+
+func decodeEnv(r *rlpReader) *stEnv {
+	env := &stEnv{
+		Coinbase:         r.address(),
+		Difficulty:       r.optBigInt(),
+		Random:           r.optBigInt(),
+		ParentDifficulty: r.optBigInt(),
+		ParentBaseFee:    r.optBigInt(),
+		ParentGasUsed:    r.uint64(),
+		ParentGasLimit:   r.uint64(),
+		GasLimit:         r.uint64(),
+		Number:           r.uint64(),
+		Timestamp:        r.uint64(),
+		ParentTimestamp:  r.uint64(),
+	}
+	hashes := r.list()
+	for hashes.more() {
+		if env.BlockHashes == nil {
+			env.BlockHashes = make(map[math.HexOrDecimal64]common.Hash)
+		}
+		item := hashes.list()
+		n, hash := item.uint64(), item.hash()
+		item.end()
+		env.BlockHashes[math.HexOrDecimal64(n)] = hash
+	}
+	ommers := r.list()
+	for ommers.more() {
+		item := ommers.list()
+		env.Ommers = append(env.Ommers, ommer{Delta: item.uint64(), Address: item.address()})
+		item.end()
+	}
+	if !r.absent(absentList) {
+		env.Withdrawals = decodeWithdrawals(r.list())
+	}
+	env.BaseFee = r.optBigInt()
+	env.ParentUncleHash = r.hash()
+	env.ExcessBlobGas = r.optUint64()
+	env.ParentExcessBlobGas = r.optUint64()
+	env.ParentBlobGasUsed = r.optUint64()
+	env.ParentBeaconBlockRoot = r.optHash()
+	r.end()
+	return env
+}
+
+func writeOptBigInt(w rlp.EncoderBuffer, x *big.Int) {
+	if x == nil {
+		w.Write([]byte{absentValue})
+	} else {
+		w.WriteBigInt(x)
+	}
+}
+
+func writeOptUint64(w rlp.EncoderBuffer, x *uint64) {
+	if x == nil {
+		w.Write([]byte{absentValue})
+	} else {
+		w.WriteUint64(*x)
+	}
+}
+
+func writeOptHash(w rlp.EncoderBuffer, h *common.Hash) {
+	if h == nil {
+		w.Write([]byte{absentValue})
+	} else {
+		w.WriteBytes(h[:])
+	}
+}
+
+func encodeWithdrawals(w rlp.EncoderBuffer, withdrawals []*types.Withdrawal) {
+	l := w.List()
+	for _, wd := range withdrawals {
+		item := w.List()
+		w.WriteUint64(wd.Index)
+		w.WriteUint64(wd.Validator)
+		w.WriteBytes(wd.Address[:])
+		w.WriteUint64(wd.Amount)
+		w.ListEnd(item)
+	}
+	w.ListEnd(l)
+}
+
+func decodeWithdrawals(r *rlpReader) []*types.Withdrawal {
+	withdrawals := []*types.Withdrawal{}
+	for r.more() {
+		item := r.list()
+		withdrawals = append(withdrawals, &types.Withdrawal{
+			Index:     item.uint64(),
+			Validator: item.uint64(),
+			Address:   item.address(),
+			Amount:    item.uint64(),
+		})
+		item.end()
+	}
+	return withdrawals
+}
+
+// encodeProofs writes proofs with the storage keys decoded to words, so they
+// come back as 32-byte hex.
+func encodeProofs(w rlp.EncoderBuffer, proofs *stateProofs) error {
+	l := w.List()
+	w.WriteBytes(proofs.StateRoot[:])
+	accounts := w.List()
+	for _, p := range proofs.Accounts {
+		a := w.List()
+		w.WriteBytes(p.Address[:])
+		writeProof(w, p.AccountProof)
+		w.WriteBigInt(bigOrZero((*big.Int)(p.Balance)))
+		w.WriteBytes(p.CodeHash[:])
+		w.WriteUint64(uint64(p.Nonce))
+		w.WriteBytes(p.StorageHash[:])
+		slots := w.List()
+		for _, slot := range p.StorageProof {
+			key, err := decodeStorageKey(slot.Key)
+			if err != nil {
+				return fmt.Errorf("account %x: slot %q: %v", p.Address, slot.Key, err)
+			}
+			s := w.List()
+			w.WriteBytes(key[:])
+			w.WriteBigInt(bigOrZero((*big.Int)(slot.Value)))
+			writeProof(w, slot.Proof)
+			w.ListEnd(s)
+		}
+		w.ListEnd(slots)
+		w.WriteBytes(p.Code)
+		w.ListEnd(a)
+	}
+	w.ListEnd(accounts)
+	w.ListEnd(l)
+	return nil
+}
+
+func writeProof(w rlp.EncoderBuffer, nodes []hexutil.Bytes) {
+	l := w.List()
+	for _, node := range nodes {
+		w.WriteBytes(node)
+	}
+	w.ListEnd(l)
+}
+
+func decodeProofs(r *rlpReader) *stateProofs {
+	proofs := &stateProofs{StateRoot: r.hash()}
+	accounts := r.list()
+	for accounts.more() {
+		a := accounts.list()
+		p := accountProof{
+			Address:      a.address(),
+			AccountProof: readProof(a.list()),
+			Balance:      (*hexutil.Big)(a.bigInt()),
+			CodeHash:     a.hash(),
+			Nonce:        hexutil.Uint64(a.uint64()),
+			StorageHash:  a.hash(),
+		}
+		slots := a.list()
+		for slots.more() {
+			s := slots.list()
+			p.StorageProof = append(p.StorageProof, storageProof{
+				Key:   s.hash().Hex(),
+				Value: (*hexutil.Big)(s.bigInt()),
+				Proof: readProof(s.list()),
+			})
+			s.end()
+		}
+		if code := a.bytes(); len(code) > 0 {
+			p.Code = code
+		}
+		a.end()
+		proofs.Accounts = append(proofs.Accounts, p)
+	}
+	r.end()
+	return proofs
+}
+
+func readProof(r *rlpReader) []hexutil.Bytes {
+	var nodes []hexutil.Bytes
+	for r.more() {
+		nodes = append(nodes, r.bytes())
+	}
+	return nodes
+}
+
+// encodeCompactStateless returns the compact encoding of in.
+func encodeCompactStateless(in *StatelessInput) ([]byte, error) {
+	block, err := rlp.EncodeToBytes(in.Block)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := encodeWitness(in.Witness)
+	if err != nil {
+		return nil, err
+	}
+	w := rlp.NewEncoderBuffer(nil)
+	l := w.List()
+	w.Write(block)
+	w.Write(witness)
+	w.ListEnd(l)
+	return w.ToBytes(), nil
+}
+
+// decodeCompactStateless decodes the compact encoding of a StatelessInput.
+func decodeCompactStateless(b []byte) (*StatelessInput, error) {
+	r := newRLPReader(b)
+	in := &StatelessInput{Block: decodeBlock(r.list()), Witness: decodeWitness(r.list())}
+	r.end()
+	if *r.err != nil {
+		return nil, *r.err
+	}
+	return in, nil
+}
+
+func decodeBlock(r *rlpReader) *types.Block {
+	var (
+		header = decodeHeader(r.list())
+		body   types.Body
+	)
+	txs := r.list()
+	for txs.more() {
+		body.Transactions = append(body.Transactions, decodeTx(txs))
+	}
+	uncles := r.list()
+	for uncles.more() {
+		body.Uncles = append(body.Uncles, decodeHeader(uncles.list()))
+	}
+	if r.more() {
+		body.Withdrawals = decodeWithdrawals(r.list())
+	}
+	r.end()
+	return types.NewBlockWithHeader(header).WithBody(body)
+}
+
+func decodeWitness(r *rlpReader) *stateless.Witness {
+	w := &stateless.Witness{Codes: make(map[string]struct{}), State: make(map[string]struct{})}
+	headers := r.list()
+	for headers.more() {
+		w.Headers = append(w.Headers, decodeHeader(headers.list()))
+	}
+	codes := r.list()
+	for codes.more() {
+		w.Codes[string(codes.bytes())] = struct{}{}
+	}
+	nodes := r.list()
+	for nodes.more() {
+		w.State[string(nodes.bytes())] = struct{}{}
+	}
+	r.end()
+	return w
+}
+
+// decodeHeader decodes a header, with the fields later forks added if the
+// encoding has them.
+func decodeHeader(r *rlpReader) *types.Header {
+	h := &types.Header{
+		ParentHash:  r.hash(),
+		UncleHash:   r.hash(),
+		Coinbase:    r.address(),
+		Root:        r.hash(),
+		TxHash:      r.hash(),
+		ReceiptHash: r.hash(),
+		Bloom:       types.BytesToBloom(r.fixed(types.BloomByteLength)),
+		Difficulty:  r.bigInt(),
+		Number:      r.bigInt(),
+		GasLimit:    r.uint64(),
+		GasUsed:     r.uint64(),
+		Time:        r.uint64(),
+		Extra:       r.bytes(),
+		MixDigest:   r.hash(),
+	}
+	copy(h.Nonce[:], r.fixed(len(h.Nonce)))
+	// The optional fields are present up to the last one the header sets.
+	if r.more() {
+		h.BaseFee = r.bigInt()
+	}
+	if r.more() {
+		hash := r.hash()
+		h.WithdrawalsHash = &hash
+	}
+	if r.more() {
+		gas := r.uint64()
+		h.BlobGasUsed = &gas
+	}
+	if r.more() {
+		gas := r.uint64()
+		h.ExcessBlobGas = &gas
+	}
+	if r.more() {
+		hash := r.hash()
+		h.ParentBeaconRoot = &hash
+	}
+	if r.more() {
+		hash := r.hash()
+		h.RequestsHash = &hash
+	}
+	r.end()
+	return h
+}
+
+// decodeTx decodes a transaction as a block body holds it: a list for legacy
+// transactions, and a string holding the type and the payload list for typed
+// ones.
+func decodeTx(r *rlpReader) *types.Transaction {
+	kind, content := r.next()
+	if kind == rlp.List {
+		fields := &rlpReader{rest: content, err: r.err}
+		tx := decodeLegacyTx(fields)
+		fields.end()
+		return types.NewTx(tx)
+	}
+	if len(content) < 2 {
+		r.fail(errors.New("short typed transaction"))
+		return nil
+	}
+	var (
+		fields = r.sub(content[1:])
+		inner  types.TxData
+	)
+	switch content[0] {
+	case types.AccessListTxType:
+		inner = decodeAccessListTx(fields)
+	case types.DynamicFeeTxType:
+		inner = decodeDynamicFeeTx(fields)
+	case types.BlobTxType:
+		inner = decodeBlobTx(fields)
+	case types.SetCodeTxType:
+		inner = decodeSetCodeTx(fields)
+	default:
+		r.fail(types.ErrTxTypeNotSupported)
+		return nil
+	}
+	fields.end()
+	return types.NewTx(inner)
+}
+
+func decodeLegacyTx(r *rlpReader) *types.LegacyTx {
+	return &types.LegacyTx{
+		Nonce:    r.uint64(),
+		GasPrice: r.bigInt(),
+		Gas:      r.uint64(),
+		To:       r.to(),
+		Value:    r.bigInt(),
+		Data:     r.bytes(),
+		V:        r.bigInt(),
+		R:        r.bigInt(),
+		S:        r.bigInt(),
+	}
+}
+
+func decodeAccessListTx(r *rlpReader) *types.AccessListTx {
+	return &types.AccessListTx{
+		ChainID:    r.bigInt(),
+		Nonce:      r.uint64(),
+		GasPrice:   r.bigInt(),
+		Gas:        r.uint64(),
+		To:         r.to(),
+		Value:      r.bigInt(),
+		Data:       r.bytes(),
+		AccessList: decodeAccessList(r.list()),
+		V:          r.bigInt(),
+		R:          r.bigInt(),
+		S:          r.bigInt(),
+	}
+}
+
+func decodeDynamicFeeTx(r *rlpReader) *types.DynamicFeeTx {
+	return &types.DynamicFeeTx{
+		ChainID:    r.bigInt(),
+		Nonce:      r.uint64(),
+		GasTipCap:  r.bigInt(),
+		GasFeeCap:  r.bigInt(),
+		Gas:        r.uint64(),
+		To:         r.to(),
+		Value:      r.bigInt(),
+		Data:       r.bytes(),
+		AccessList: decodeAccessList(r.list()),
+		V:          r.bigInt(),
+		R:          r.bigInt(),
+		S:          r.bigInt(),
+	}
+}
+
+func decodeBlobTx(r *rlpReader) *types.BlobTx {
+	tx := &types.BlobTx{
+		ChainID:    r.uint256(),
+		Nonce:      r.uint64(),
+		GasTipCap:  r.uint256(),
+		GasFeeCap:  r.uint256(),
+		Gas:        r.uint64(),
+		To:         r.address(),
+		Value:      r.uint256(),
+		Data:       r.bytes(),
+		AccessList: decodeAccessList(r.list()),
+		BlobFeeCap: r.uint256(),
+	}
+	hashes := r.list()
+	for hashes.more() {
+		tx.BlobHashes = append(tx.BlobHashes, hashes.hash())
+	}
+	tx.V, tx.R, tx.S = r.uint256(), r.uint256(), r.uint256()
+	return tx
+}
+
+func decodeSetCodeTx(r *rlpReader) *types.SetCodeTx {
+	tx := &types.SetCodeTx{
+		ChainID:    r.uint256(),
+		Nonce:      r.uint64(),
+		GasTipCap:  r.uint256(),
+		GasFeeCap:  r.uint256(),
+		Gas:        r.uint64(),
+		To:         r.address(),
+		Value:      r.uint256(),
+		Data:       r.bytes(),
+		AccessList: decodeAccessList(r.list()),
+	}
+	auths := r.list()
+	for auths.more() {
+		a := auths.list()
+		auth := types.SetCodeAuthorization{ChainID: *a.uint256(), Address: a.address(), Nonce: a.uint64()}
+		v := a.uint64()
+		if v > 0xff {
+			a.fail(rlp.ErrCanonSize)
+		}
+		auth.V, auth.R, auth.S = uint8(v), *a.uint256(), *a.uint256()
+		a.end()
+		tx.AuthList = append(tx.AuthList, auth)
+	}
+	tx.V, tx.R, tx.S = r.uint256(), r.uint256(), r.uint256()
+	return tx
+}
+
+func decodeAccessList(r *rlpReader) types.AccessList {
+	list := types.AccessList{}
+	for r.more() {
+		t := r.list()
+		tuple := types.AccessTuple{Address: t.address(), StorageKeys: []common.Hash{}}
+		keys := t.list()
+		for keys.more() {
+			tuple.StorageKeys = append(tuple.StorageKeys, keys.hash())
+		}
+		t.end()
+		list = append(list, tuple)
+	}
+	return list
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// sampleInput returns the input object of the sample block, built from the
+// files under assets.
+func sampleInput(t *testing.T) []byte {
+	fields := make(map[string]json.RawMessage)
+	for name, file := range map[string]string{"alloc": "alloc.json", "env": "env.json", "txs": "tx.json"} {
+		data, err := os.ReadFile("../assets/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields[name] = data
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testStatelessInput returns a StatelessInput whose block holds a transaction
+// of every type and a header with every optional field set.
+func testStatelessInput(t *testing.T) *StatelessInput {
+	var (
+		key, _  = crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+		chainID = big.NewInt(1)
+		signer  = types.LatestSignerForChainID(chainID)
+		to      = common.HexToAddress("0x1000")
+		list    = types.AccessList{{Address: to, StorageKeys: []common.Hash{{31: 1}}}}
+	)
+	auth, err := types.SignSetCode(key, types.SetCodeAuthorization{ChainID: *uint256.MustFromBig(chainID), Address: to, Nonce: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []*types.Transaction
+	for i, data := range []types.TxData{
+		&types.LegacyTx{Nonce: 0, GasPrice: big.NewInt(10), Gas: 21000, Value: big.NewInt(1)},
+		&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(10), Gas: 60000, To: &to, Data: []byte{0x01, 0x02}},
+		&types.AccessListTx{ChainID: chainID, Nonce: 2, GasPrice: big.NewInt(10), Gas: 30000, To: &to, AccessList: list},
+		&types.DynamicFeeTx{ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(20), Gas: 30000, To: &to, Value: big.NewInt(7), AccessList: list},
+		&types.BlobTx{ChainID: uint256.MustFromBig(chainID), Nonce: 4, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(20), Gas: 30000, To: to, BlobFeeCap: uint256.NewInt(3), BlobHashes: []common.Hash{{0: 0x01, 31: 1}}, AccessList: list},
+		&types.SetCodeTx{ChainID: uint256.MustFromBig(chainID), Nonce: 5, GasTipCap: uint256.NewInt(1), GasFeeCap: uint256.NewInt(20), Gas: 60000, To: to, AuthList: []types.SetCodeAuthorization{auth}},
+	} {
+		tx, err := types.SignNewTx(key, signer, data)
+		if err != nil {
+			t.Fatalf("tx %d: %v", i, err)
+		}
+		txs = append(txs, tx)
+	}
+	var (
+		withdrawalsHash = common.Hash{1}
+		blobGasUsed     = uint64(131072)
+		excessBlobGas   = uint64(0)
+		beaconRoot      = common.Hash{2}
+		requestsHash    = common.Hash{3}
+	)
+	parent := &types.Header{Difficulty: new(big.Int), Number: big.NewInt(9), GasLimit: 30_000_000, Time: 1000, BaseFee: big.NewInt(7)}
+	header := &types.Header{
+		ParentHash:       parent.Hash(),
+		Coinbase:         common.HexToAddress("0x2000"),
+		Root:             common.Hash{4},
+		Difficulty:       new(big.Int),
+		Number:           big.NewInt(10),
+		GasLimit:         30_000_000,
+		GasUsed:          201000,
+		Time:             1012,
+		Extra:            []byte("extra"),
+		BaseFee:          big.NewInt(7),
+		WithdrawalsHash:  &withdrawalsHash,
+		BlobGasUsed:      &blobGasUsed,
+		ExcessBlobGas:    &excessBlobGas,
+		ParentBeaconRoot: &beaconRoot,
+		RequestsHash:     &requestsHash,
+	}
+	body := &types.Body{
+		Transactions: txs,
+		Uncles:       []*types.Header{{Difficulty: big.NewInt(1), Number: big.NewInt(9), GasLimit: 30_000_000}},
+		Withdrawals:  []*types.Withdrawal{{Index: 1, Validator: 2, Address: to, Amount: 3}},
+	}
+	return &StatelessInput{
+		Block: types.NewBlock(header, body, nil, trie.NewStackTrie(nil)),
+		Witness: &stateless.Witness{
+			Headers: []*types.Header{parent},
+			Codes:   map[string]struct{}{"\x60\x00": {}, "\x5f\x5f\xf3": {}},
+			State:   map[string]struct{}{"\xc2\x01\x02": {}, "\xc3\x80\x01\x02": {}},
+		},
+	}
+}
+
+func TestCompactInputRoundTrip(t *testing.T) {
+	in, err := decodeInput(sampleInput(t), "sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := marshalInput(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := encodeCompactInput(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in, err = decodeInput(compact, "compact"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := marshalInput(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("JSON after a compact round trip differs:\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompactStatelessRoundTrip(t *testing.T) {
+	want, err := json.MarshalIndent(testStatelessInput(t), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := decodeStatelessInput(want, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := encodeCompactStateless(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeCompactStateless(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(decoded, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("JSON after a compact round trip differs:\n%s\nwant\n%s", got, want)
+	}
+	// The hand-written decoder must build the same block as the reflection
+	// one.
+	blockRLP, err := rlp.EncodeToBytes(in.Block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block types.Block
+	if err := rlp.DecodeBytes(blockRLP, &block); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := decoded.Block.Hash(), block.Hash(); got != want {
+		t.Errorf("block hash %s, rlp.DecodeBytes gives %s", got, want)
+	}
+	for i, tx := range block.Transactions() {
+		if got := decoded.Block.Transactions()[i].Hash(); got != tx.Hash() {
+			t.Errorf("tx %d (type %d): hash %s, rlp.DecodeBytes gives %s", i, tx.Type(), got, tx.Hash())
+		}
+	}
+}
+
+// Input that is neither an RLP list nor a JSON object, such as JSON behind a
+// byte order mark, is rejected rather than decoded as RLP.
+func TestIsCompactRejectsOtherInput(t *testing.T) {
+	for _, data := range []string{"", "\xef\xbb\xbf{}", "[]", "\x80"} {
+		if _, err := isCompact([]byte(data)); err == nil {
+			t.Errorf("%q: no error", data)
+		}
+		if _, err := decodeInput([]byte(data), "input"); exitCode(err) != ErrorJson {
+			t.Errorf("%q: error %v, want code %d", data, err, ErrorJson)
+		}
+	}
+	for data, want := range map[string]bool{"{}": false, "\n {}": false, "\xc0": true, "\xf9\x01\x00": true} {
+		if got, err := isCompact([]byte(data)); err != nil || got != want {
+			t.Errorf("%q: compact %t, error %v; want %t", data, got, err, want)
+		}
+	}
+}
+
+// An input given whole can be a StatelessInput in either encoding, as well
+// as an input object.
+func TestDecodeWholeInput(t *testing.T) {
+	want := testStatelessInput(t)
+	jsonData, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := encodeCompactStateless(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string][]byte{"json": jsonData, "compact": compact} {
+		in, err := decodeWholeInput(data, name)
+		switch {
+		case err != nil:
+			t.Errorf("%s: %v", name, err)
+		case in.Stateless == nil:
+			t.Errorf("%s: decoded as an input object", name)
+		case in.Stateless.Block.Hash() != want.Block.Hash():
+			t.Errorf("%s: block %s, want %s", name, in.Stateless.Block.Hash(), want.Block.Hash())
+		}
+	}
+	in, err := decodeWholeInput(sampleInput(t), "sample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Stateless != nil || in.Env == nil {
+		t.Errorf("sample input object decoded as a StatelessInput")
+	}
+}
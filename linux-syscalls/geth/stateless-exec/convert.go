@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// decodeInput decodes a t8n input object in JSON or the compact encoding.
+// name is used in errors.
+func decodeInput(data []byte, name string) (*input, error) {
+	compact, err := isCompact(data)
+	if err != nil {
+		return nil, NewError(ErrorJson, fmt.Errorf("could not parse %s: %v", name, err))
+	}
+	var in *input
+	if compact {
+		if in, err = decodeCompactInput(data); err != nil {
+			return nil, NewError(ErrorRlp, fmt.Errorf("could not decode %s: %v", name, err))
+		}
+	} else {
+		in = new(input)
+		if err := json.Unmarshal(data, in); err != nil {
+			return nil, NewError(ErrorJson, fmt.Errorf("could not parse %s: %v", name, err))
+		}
+	}
+	if in.Env == nil {
+		return nil, NewError(ErrorJson, fmt.Errorf("%s has no env", name))
+	}
+	return in, nil
+}
+
+// decodeWholeInput decodes an input given whole, in the memory region or with
+// -input: a t8n input object or a StatelessInput, in JSON or the compact
+// encoding. name is used in errors.
+func decodeWholeInput(data []byte, name string) (*input, error) {
+	stateless, err := isStatelessInput(data)
+	if err != nil {
+		return nil, err
+	}
+	if !stateless {
+		return decodeInput(data, name)
+	}
+	in, err := decodeStatelessInput(data, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := in.checkWitness(); err != nil {
+		return nil, err
+	}
+	return &input{Stateless: in}, nil
+}
+
+// isStatelessInput reports whether data holds a StatelessInput rather than a
+// t8n input object: a compact list of two items, or a JSON object keyed by
+// block and witness. Neither is a key of an input object, so the first key
+// tells them apart without parsing the rest, which a guest would otherwise
+// parse twice.
+func isStatelessInput(data []byte) (bool, error) {
+	compact, err := isCompact(data)
+	if err != nil {
+		return false, NewError(ErrorJson, err)
+	}
+	if compact {
+		content, _, err := rlp.SplitList(data)
+		if err != nil {
+			return false, NewError(ErrorRlp, err)
+		}
+		n, err := rlp.CountValues(content)
+		if err != nil {
+			return false, NewError(ErrorRlp, err)
+		}
+		return n == 2, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return false, NewError(ErrorJson, errors.New("the input is not a JSON object"))
+	}
+	key, err := dec.Token()
+	if err != nil {
+		return false, NewError(ErrorJson, err)
+	}
+	return key == "block" || key == "witness", nil
+}
+
+// marshalInput encodes in as indented JSON. The env leaves out empty
+// withdrawals, which the Shanghai checks need, so they are put back.
+func marshalInput(in *input) ([]byte, error) {
+	enc, err := json.Marshal(in)
+	if err != nil || in.Env.Withdrawals == nil || len(in.Env.Withdrawals) > 0 {
+		return json.MarshalIndent(in, "", "  ")
+	}
+	var fields, env map[string]json.RawMessage
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(fields["env"], &env); err != nil {
+		return nil, err
+	}
+	env["withdrawals"] = json.RawMessage("[]")
+	if fields["env"], err = json.Marshal(env); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+// convertInput converts the t8n input object or StatelessInput in the file at
+// path from JSON to the compact encoding or back, and writes it to out. With
+// runs, it then decodes the input runs times in each encoding and prints the
+// cost per decode.
+func convertInput(path, out string, runs int) error {
+	if out == "" && runs == 0 {
+		return NewError(ErrorConfig, errors.New("-convert needs -output.converted or -bench.decode"))
+	}
+	data, err := readFile(path)
+	if err != nil {
+		return NewError(ErrorIO, fmt.Errorf("could not read %s: %v", path, err))
+	}
+	stateless, err := isStatelessInput(data)
+	if err != nil {
+		return err
+	}
+	var (
+		jsonData, compactData []byte
+		decode                func([]byte) error
+	)
+	if stateless {
+		in, err := decodeStatelessInput(data, path)
+		if err != nil {
+			return err
+		}
+		if jsonData, err = json.MarshalIndent(in, "", "  "); err != nil {
+			return NewError(ErrorJson, err)
+		}
+		if compactData, err = encodeCompactStateless(in); err != nil {
+			return NewError(ErrorRlp, err)
+		}
+		decode = func(b []byte) error {
+			_, err := decodeStatelessInput(b, path)
+			return err
+		}
+	} else {
+		in, err := decodeInput(data, path)
+		if err != nil {
+			return err
+		}
+		if jsonData, err = marshalInput(in); err != nil {
+			return NewError(ErrorJson, err)
+		}
+		if compactData, err = encodeCompactInput(in); err != nil {
+			return NewError(ErrorRlp, err)
+		}
+		decode = func(b []byte) error {
+			_, err := decodeInput(b, path)
+			return err
+		}
+	}
+	// The encoding the input came in is used as it is.
+	converted := compactData
+	if compact, _ := isCompact(data); compact {
+		converted, compactData = jsonData, data
+	} else {
+		jsonData = data
+	}
+	if out == "-" {
+		_, err = stdout.Write(converted)
+	} else if out != "" {
+		err = writeFile(out, converted)
+		fmt.Fprintf(stdout, "Converted %s: %d bytes of JSON, %d bytes compact\n", path, len(jsonData), len(compactData))
+	}
+	if err != nil {
+		return NewError(ErrorIO, fmt.Errorf("failed writing output %s: %v", out, err))
+	}
+	if runs > 0 {
+		return benchDecoding(jsonData, compactData, decode, runs)
+	}
+	return nil
+}
+
+// benchDecoding decodes the JSON and the compact encoding of an input runs
+// times each and prints the time and heap allocations per decode.
+func benchDecoding(jsonData, compactData []byte, decode func([]byte) error, runs int) error {
+	encodings := []struct {
+		name string
+		data []byte
+	}{
+		{"JSON", jsonData},
+		{"compact", compactData},
+	}
+	for _, e := range encodings {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		for i := 0; i < runs; i++ {
+			if err := decode(e.data); err != nil {
+				return err
+			}
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		n := uint64(runs)
+		fmt.Fprintf(stdout, "Decode %s: %d bytes, %d ns/op, %d allocs/op, %d B/op\n",
+			e.name, len(e.data), elapsed.Nanoseconds()/int64(runs), (after.Mallocs-before.Mallocs)/n, (after.TotalAlloc-before.TotalAlloc)/n)
+	}
+	return nil
+}
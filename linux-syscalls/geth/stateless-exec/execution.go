@@ -11,6 +11,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/tracing"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -20,41 +21,49 @@ import (
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
-	"github.com/ethereum/go-ethereum/triedb"
 	"github.com/holiman/uint256"
-	"golang.org/x/crypto/sha3"
+
+	"github.com/eth-act/riscv-compilation/cryptobackend"
 )
 
 
 
-func MakePreState(db ethdb.Database, accounts types.GenesisAlloc) *state.StateDB {
-	tdb := triedb.NewDatabase(db, &triedb.Config{Preimages: true})
-	sdb := state.NewDatabase(tdb, nil)
-	statedb, _ := state.New(types.EmptyRootHash, sdb)
-	for addr, a := range accounts {
-		statedb.SetCode(addr, a.Code)
-		statedb.SetNonce(addr, a.Nonce, tracing.NonceChangeGenesis)
-		statedb.SetBalance(addr, uint256.MustFromBig(a.Balance), tracing.BalanceIncreaseGenesisBalance)
-		for k, v := range a.Storage {
-			statedb.SetState(addr, k, v)
-		}
-	}
-	// Commit and re-open to start with a clean state.
-	root, _ := statedb.Commit(0, false, false)
-	statedb, _ = state.New(root, sdb)
-	return statedb
+func rlpHash(x interface{}) (h common.Hash) {
+	data, _ := rlp.EncodeToBytes(x)
+	return cryptobackend.Default.Keccak256(data)
 }
 
-func rlpHash(x interface{}) (h common.Hash) {
-	hw := sha3.NewLegacyKeccak256()
-	rlp.Encode(hw, x)
-	hw.Sum(h[:0])
-	return h
+
+// applyOptions select how Apply keeps the state and what it records besides
+// the result.
+type applyOptions struct {
+	// recordRoots computes the state root after every included transaction,
+	// which costs a trie hash per transaction, and returns them in the result.
+	recordRoots bool
+	// binaryTrie keeps the state in an EIP-7864 binary trie instead of a
+	// Merkle-Patricia trie.
+	binaryTrie bool
+	// witness, if set, collects the trie nodes and code the block reads and
+	// writes.
+	witness *stateless.Witness
+	// db, if set, is the database the pre-state is built in, instead of a
+	// fresh memory database.
+	db ethdb.Database
+	// checkpoint, if set, is called once the pre-state is built, once the
+	// transactions have run and once the state is committed. An error it
+	// returns ends Apply.
+	checkpoint func() error
 }
 
+func (opts *applyOptions) check() error {
+	if opts.checkpoint == nil {
+		return nil
+	}
+	return opts.checkpoint()
+}
 
-// Apply applies a set of transactions to a pre-state
-func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig, txIt txIterator, miningReward int64) (*state.StateDB, *ExecutionResult, []byte, error) {
+// Apply applies a set of transactions to a pre-state.
+func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig, txIt txIterator, miningReward int64, opts applyOptions) (*state.StateDB, *ExecutionResult, []byte, error) {
 	// Capture errors for BLOCKHASH operation, if we haven't been supplied the
 	// required blockhashes
 	var hashError error
@@ -69,18 +78,41 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		}
 		return h
 	}
+	db := opts.db
+	if db == nil {
+		db = rawdb.NewMemoryDatabase()
+	}
+	statedb, err := pre.makeState(db, opts.binaryTrie)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	var (
-		statedb     = MakePreState(rawdb.NewMemoryDatabase(), pre.Pre)
-		signer      = types.MakeSigner(chainConfig, new(big.Int).SetUint64(pre.Env.Number), pre.Env.Timestamp)
+		signer      = cryptobackend.Signer(types.MakeSigner(chainConfig, new(big.Int).SetUint64(pre.Env.Number), pre.Env.Timestamp), cryptobackend.Default)
 		gaspool     = new(core.GasPool)
 		blockHash   = common.Hash{0x13, 0x37}
 		rejectedTxs []*rejectedTx
+		roots       []*intermediateRoot
 		includedTxs types.Transactions
 		gasUsed     = uint64(0)
 		blobGasUsed = uint64(0)
 		receipts    = make(types.Receipts, 0)
 		txIndex     = 0
 	)
+	parentStateRoot := statedb.IntermediateRoot(false)
+	if err := opts.check(); err != nil {
+		return nil, nil, nil, err
+	}
+	if opts.witness != nil {
+		// Geth collects the witness of a Merkle-Patricia trie through the
+		// prefetcher, which loads the paths of the accounts and slots read.
+		// Against a single tree, the prefetcher would swap in its own copy
+		// and drop the storage already written; the binary trie records its
+		// reads itself, so only the witness is kept.
+		statedb.StartPrefetcher("stateless-exec", opts.witness)
+		if opts.binaryTrie {
+			statedb.StopPrefetcher()
+		}
+	}
 	gaspool.AddGas(pre.Env.GasLimit)
 	vmContext := vm.BlockContext{
 		CanTransfer: core.CanTransfer,
@@ -136,7 +168,13 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		chainConfig.DAOForkBlock.Cmp(new(big.Int).SetUint64(pre.Env.Number)) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
-	evm := vm.NewEVM(vmContext, statedb, chainConfig, vmConfig)
+	var evmState vm.StateDB = statedb
+	if opts.binaryTrie {
+		evmState = binaryStateDB{statedb}
+	}
+	evm := vm.NewEVM(vmContext, evmState, chainConfig, vmConfig)
+	rules := chainConfig.Rules(vmContext.BlockNumber, vmContext.Random != nil, vmContext.Time)
+	evm.SetPrecompiles(cryptobackend.Precompiles(vm.ActivePrecompiledContracts(rules), cryptobackend.Default))
 	if beaconRoot := pre.Env.ParentBeaconBlockRoot; beaconRoot != nil {
 		core.ProcessBeaconBlockRoot(*beaconRoot, evm)
 	}
@@ -208,9 +246,21 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		{
 			var root []byte
 			if chainConfig.IsByzantium(vmContext.BlockNumber) {
-				statedb.Finalise(true)
+				if opts.recordRoots {
+					roots = append(roots, &intermediateRoot{i, tx.Hash(), statedb.IntermediateRoot(true), math.HexOrDecimal64(gasUsed)})
+				} else if opts.binaryTrie && !rules.IsCancun {
+					// Before EIP-6780, a self-destructed account may be
+					// created again by a later transaction. Updating the
+					// tree now deletes its old storage before then.
+					statedb.IntermediateRoot(true)
+				} else {
+					statedb.Finalise(true)
+				}
 			} else {
 				root = statedb.IntermediateRoot(chainConfig.IsEIP158(vmContext.BlockNumber)).Bytes()
+				if opts.recordRoots {
+					roots = append(roots, &intermediateRoot{i, tx.Hash(), common.BytesToHash(root), math.HexOrDecimal64(gasUsed)})
+				}
 			}
 
 			// Create a new receipt for the transaction, storing the intermediate root and
@@ -246,6 +296,9 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		txIndex++
 	}
 	statedb.IntermediateRoot(chainConfig.IsEIP158(vmContext.BlockNumber))
+	if err := opts.check(); err != nil {
+		return nil, nil, nil, err
+	}
 	// Add mining reward? (-1 means rewards are disabled)
 	if miningReward >= 0 {
 		// Add mining reward. The mining reward may be `0`, which only makes a difference in the cases
@@ -302,8 +355,16 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 	// Commit block
 	root, err := statedb.Commit(vmContext.BlockNumber.Uint64(), chainConfig.IsEIP158(vmContext.BlockNumber), chainConfig.IsCancun(vmContext.BlockNumber, vmContext.Time))
 	if err != nil {
+		// Reads of state the pre-state does not hold, such as an account
+		// without a proof, are recorded by the StateDB and abort the commit.
+		if dbErr := statedb.Error(); dbErr != nil {
+			return nil, nil, nil, NewError(ErrorMissingState, fmt.Errorf("block accessed state missing from the pre-state: %v", dbErr))
+		}
 		return nil, nil, nil, NewError(ErrorEVM, fmt.Errorf("could not commit state: %v", err))
 	}
+	if err := opts.check(); err != nil {
+		return nil, nil, nil, err
+	}
 	execRs := &ExecutionResult{
 		StateRoot:   root,
 		TxRoot:      types.DeriveSha(includedTxs, trie.NewStackTrie(nil)),
@@ -315,6 +376,9 @@ func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig,
 		Difficulty:  (*math.HexOrDecimal256)(vmContext.Difficulty),
 		GasUsed:     (math.HexOrDecimal64)(gasUsed),
 		BaseFee:     (*math.HexOrDecimal256)(vmContext.BaseFee),
+
+		IntermediateRoots: roots,
+		ParentStateRoot:   parentStateRoot,
 	}
 	if pre.Env.Withdrawals != nil {
 		h := types.DeriveSha(types.Withdrawals(pre.Env.Withdrawals), trie.NewStackTrie(nil))
@@ -0,0 +1,19 @@
+//go:build !(tamago && semihosting)
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Host I/O through the operating system. On tamago without semihosting there
+// is no file system, and os.Stdout and os.Exit go to the board's console and
+// exit hook.
+var (
+	readFile            = os.ReadFile
+	openFile            = func(path string) (io.ReadCloser, error) { return os.Open(path) }
+	writeFile           = func(path string, data []byte) error { return os.WriteFile(path, data, 0o644) }
+	stdout    io.Writer = os.Stdout
+	exit                = os.Exit
+)
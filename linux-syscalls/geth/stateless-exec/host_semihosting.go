@@ -0,0 +1,29 @@
+//go:build tamago && semihosting
+
+package main
+
+import (
+	"io"
+	"runtime"
+
+	"github.com/eth-act/riscv-compilation/semihosting"
+)
+
+// Host I/O through RISC-V semihosting, so that a run under
+// `qemu-system-riscv64 -semihosting` reads its assets from, and reports its
+// result and exit status to, the host like a normal command.
+var (
+	readFile            = semihosting.ReadFile
+	openFile            = func(path string) (io.ReadCloser, error) { return semihosting.OpenFile(path) }
+	writeFile           = semihosting.WriteFile
+	stdout    io.Writer = semihosting.Stdout
+	exit                = semihosting.Exit
+)
+
+func init() {
+	// Runtime exits, such as an unrecovered panic, also end the QEMU run
+	// with their status instead of halting the board.
+	runtime.Exit = func(code int32) {
+		semihosting.Exit(int(code))
+	}
+}
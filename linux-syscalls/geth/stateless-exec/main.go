@@ -1,66 +1,295 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"math/big"
+	"os"
 
-	_ "github.com/usbarmory/tamago/board/qemu/sifive_u"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
 )
 
 
 
 
 
+// Outputs for hosts and fixture tools, which guests without a command line
+// do not write.
+var (
+	outputAlloc  = flag.String("output.alloc", "", "write the post-state alloc as JSON to this file (- for stdout)")
+	touchedOnly  = flag.Bool("output.alloc.touched", false, "only include the accounts the block created or changed in the alloc")
+	outputDiff   = flag.String("output.diff", "", "write the per-account state diff as JSON to this file (- for stdout)")
+	printDiff    = flag.Bool("diff", false, "print the per-account state diff")
+	outputResult = flag.String("output.result", "", "write the execution result as JSON to this file (- for stdout)")
+)
+
+// recordRoots is the default of -roots, which makes Apply compute the state
+// root after every transaction to find the first one where two builds
+// diverge. Guests without a command line set it with
+// -ldflags "-X main.recordRoots=true".
+var (
+	recordRoots = "false"
+	roots       = flag.Bool("roots", recordRoots == "true", "record and print the state root and cumulative gas after every transaction")
+)
+
+// stateTrie and collectWitness are the defaults of -state.trie and -witness,
+// which select the trie the state is kept in and report the size of the
+// witness the block needs. Guests without a command line set them with
+// -ldflags "-X main.stateTrie=binary -X main.collectWitness=true".
+var (
+	stateTrie      = "mpt"
+	trieKind       = flag.String("state.trie", stateTrie, "keep the state in a Merkle-Patricia trie (mpt) or an EIP-7864 binary trie (binary)")
+	collectWitness = "false"
+	reportWitness  = flag.Bool("witness", collectWitness == "true", "collect the witness of the block and print its size (always on with the binary trie)")
+)
+
+// Witness statistics for hosts sizing proving inputs.
+var (
+	printWitnessStats  = flag.Bool("witness.stats", false, "print a breakdown of the witness by trie, depth and account")
+	outputWitnessStats = flag.String("output.witness.stats", "", "write the witness breakdown as JSON to this file (- for stdout)")
+)
+
+// proofsPath is the default of -proofs, which reads the pre-state from
+// eth_getProof results instead of ./assets/alloc.json. Guests on semihosting
+// set it with -ldflags "-X main.proofsPath=./assets/proofs.json"; guests
+// reading the memory region pass the proofs in the input object.
+var (
+	proofsPath = ""
+	proofsFile = flag.String("proofs", proofsPath, "read the pre-state from eth_getProof results against the parent state root in this file instead of ./assets/alloc.json")
+)
+
+// Witness minimisation for hosts shrinking a StatelessInput before proving.
+var (
+	minimiseInput   = flag.String("witness.minimise", "", "run the block of the StatelessInput in this file against its witness, keep only the trie nodes and code it reads and exit")
+	outputStateless = flag.String("output.stateless", "", "write the minimised StatelessInput as JSON to this file (- for stdout)")
+)
+
+// inputPath is the default of -input, which reads a whole t8n input object,
+// in JSON or the compact encoding, instead of the files under ./assets.
+// Guests on semihosting set it with
+// -ldflags "-X main.inputPath=./assets/input.rlp".
+var (
+	inputPath = ""
+	inputFile = flag.String("input", inputPath, "read a t8n input object with alloc, env and txs, or a StatelessInput, in JSON or the compact encoding, from this file instead of ./assets")
+)
+
+// Conversion between the JSON and compact encodings of the inputs.
+var (
+	convertFile     = flag.String("convert", "", "convert the t8n input object or StatelessInput in this file between JSON and the compact encoding and exit")
+	outputConverted = flag.String("output.converted", "", "write the converted input to this file (- for stdout)")
+	benchDecode     = flag.Int("bench.decode", 0, "with -convert, decode the input this many times in each encoding and compare their cost")
+)
+
 func main() {
-	fmt.Println("Starting stateless block execution")
-	
-	
-	alloc_path := "./assets/alloc.json"
-	evn_path := "./assets/env.json"
-	tx_path := "./assets/tx.json"
-	
+	flag.StringVar(&memoryBudget, "memory", memoryBudget, "abort when the runtime maps more than this much memory, e.g. 512MiB")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: stateless-exec [flags] [public-values]\n\n")
+		flag.PrintDefaults()
+	}
+	// Bare-metal guests have no command line.
+	if len(os.Args) > 0 {
+		flag.Parse()
+	}
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(exitCode(err))
+	}
+	exit(0)
+}
+
+// exitCode returns the status to exit with after err: the code of a
+// NumberedError, or 1.
+func exitCode(err error) int {
+	var numbered *NumberedError
+	if errors.As(err, &numbered) {
+		return numbered.errorCode
+	}
+	return 1
+}
+
+func run() error {
+	var budget uint64
+	if memoryBudget != "" {
+		var err error
+		if budget, err = parseBytes(memoryBudget); err != nil {
+			return NewError(ErrorConfig, err)
+		}
+	}
+	binaryTrie := *trieKind == "binary"
+	if !binaryTrie && *trieKind != "mpt" {
+		return NewError(ErrorConfig, fmt.Errorf("unknown state trie %q, want mpt or binary", *trieKind))
+	}
+	if binaryTrie && (*outputAlloc != "" || *outputDiff != "" || *printDiff) {
+		return NewError(ErrorConfig, errors.New("the post-state alloc and diff need the MPT state: the binary trie keeps no addresses"))
+	}
+	witnessStats := *printWitnessStats || *outputWitnessStats != ""
+	if !binaryTrie && (*reportWitness || witnessStats) && *roots {
+		return NewError(ErrorConfig, errors.New("-witness and the witness stats cannot be combined with -roots: the MPT witness comes from the state prefetcher, which stops at the first intermediate root"))
+	}
+	if *convertFile != "" {
+		return convertInput(*convertFile, *outputConverted, *benchDecode)
+	}
+	if *minimiseInput != "" {
+		if binaryTrie {
+			return NewError(ErrorConfig, errors.New("stateless witnesses hold Merkle-Patricia nodes and cannot build a binary trie"))
+		}
+		return minimiseWitness(*minimiseInput, *outputStateless)
+	}
+	mem := startMemoryMonitor(budget)
+
+	fmt.Fprintln(stdout, "Starting stateless block execution")
+
+
+	inputData, tx_path, err := obtainInput()
+	if err != nil {
+		return err
+	}
+	if err := mem.check(); err != nil {
+		return err
+	}
+	if inputData.Stateless != nil {
+		switch {
+		case binaryTrie:
+			return NewError(ErrorConfig, errors.New("stateless witnesses hold Merkle-Patricia nodes and cannot build a binary trie"))
+		case *outputAlloc != "" || *outputDiff != "" || *printDiff:
+			return NewError(ErrorConfig, errors.New("the post-state alloc and diff need the full pre-state, not a witness"))
+		}
+	}
+	if inputData.Proofs != nil {
+		switch {
+		case inputData.Alloc != nil:
+			return NewError(ErrorConfig, errors.New("the input has both an alloc and proofs"))
+		case binaryTrie:
+			return NewError(ErrorConfig, errors.New("proofs are Merkle-Patricia proofs and cannot build a binary trie"))
+		case *outputAlloc != "" || *outputDiff != "" || *printDiff:
+			return NewError(ErrorConfig, errors.New("the post-state alloc and diff need the full pre-state, not proofs"))
+		}
+	}
 	var (
 		prestate Prestate
-		txIt     txIterator
-		inputData = obtainAssets(alloc_path, evn_path, tx_path)
 		chainConfig = obtainChainConfig()
 		vmConfig = obtainVmConfig()
+		txIt txIterator
+		miningReward int64
 	)
-	
-
-	
-	prestate.Pre = inputData.Alloc
-	prestate.Env = *inputData.Env
-	
-	
-	fmt.Println("Loading transactions")
-	txIt, txit_err := loadTransactions(tx_path, inputData, chainConfig)
-	if txit_err != nil {
-		panic("Transactions failed to load")
-	}
-	
-	fmt.Println("Applying london checks")
-
-	
+
+
+
+	if in := inputData.Stateless; in != nil {
+		// The block comes with its transactions, and is checked against its
+		// header once applied. Post-merge blocks carry no mining reward.
+		var pre *Prestate
+		pre, txIt = in.prestate()
+		prestate, miningReward = *pre, -1
+	} else {
+		prestate.Pre = inputData.Alloc
+		prestate.Proofs = inputData.Proofs
+		prestate.Env = *inputData.Env
+
+		fmt.Fprintln(stdout, "Loading transactions")
+		if txIt, err = loadTransactions(tx_path, inputData, chainConfig); err != nil {
+			return err
+		}
+	}
+	envHash := hashEnv(&prestate.Env)
+
+	fmt.Fprintln(stdout, "Applying london checks")
+
+
 	if err := applyLondonChecks(&prestate.Env, chainConfig); err != nil {
-		panic("An error occurred while applying London checks")
+		return err
 	}
 	if err := applyShanghaiChecks(&prestate.Env, chainConfig); err != nil {
-		panic("An error occurred while applying shanghai checks")
+		return err
 	}
 	if err := applyMergeChecks(&prestate.Env, chainConfig); err != nil {
-		panic("An error occurred while applying merge checks")
+		return err
 	}
 	if err := applyCancunChecks(&prestate.Env, chainConfig); err != nil {
-		panic("An error occurred while applying cancun checks")
+		return err
 	}
-	
-	_, result, _, err := prestate.Apply(*vmConfig, chainConfig, txIt, 0)
+
+	opts := applyOptions{recordRoots: *roots, binaryTrie: binaryTrie, checkpoint: mem.check}
+	if *reportWitness || witnessStats || binaryTrie {
+		opts.witness, _ = stateless.NewWitness(&types.Header{Number: new(big.Int).SetUint64(prestate.Env.Number)}, nil)
+	}
+	txs := &txRecorder{txIterator: txIt}
+	statedb, result, _, err := prestate.Apply(*vmConfig, chainConfig, txs, miningReward, opts)
 	if err != nil {
-		panic("An error occured when appying the state transition function")
+		return err
+	}
+	if in := inputData.Stateless; in != nil {
+		if err := in.checkResult(result); err != nil {
+			return err
+		}
+	}
+	// A transactions file that breaks off part way, or holds a transaction
+	// that does not decode or sign, ends the block early and fails the run.
+	if it, ok := txIt.(interface{ Err() error }); ok && it.Err() != nil {
+		return it.Err()
+	}
+	summary, err := mem.stop()
+	if err != nil {
+		return err
 	}
-	
-	fmt.Printf("Execution result: %+v\n", result)
-}
-
 
+	fmt.Fprintf(stdout, "Execution result: %+v\n", result)
+	fmt.Fprintf(stdout, "Memory: %s\n", summary)
+	if opts.witness != nil {
+		fmt.Fprintf(stdout, "Witness: %s trie, state root %s, %s\n", *trieKind, result.StateRoot, witnessSize(opts.witness))
+	}
+	if witnessStats {
+		stats := newWitnessStats(opts.witness, result.ParentStateRoot, binaryTrie, witnessAccounts(prestate.Pre, prestate.Proofs))
+		if *printWitnessStats {
+			fmt.Fprint(stdout, stats)
+		}
+		if *outputWitnessStats != "" {
+			if err := saveOutput(*outputWitnessStats, stats); err != nil {
+				return err
+			}
+		}
+	}
+	for _, r := range result.IntermediateRoots {
+		fmt.Fprintf(stdout, "Intermediate root: tx %d %s root %s gas %d\n", r.Index, r.TxHash, r.StateRoot, uint64(r.CumulativeGasUsed))
+	}
+	if *outputResult != "" {
+		if err := saveOutput(*outputResult, result); err != nil {
+			return err
+		}
+	}
+	if *outputAlloc != "" || *outputDiff != "" || *printDiff {
+		post := dumpAlloc(statedb)
+		if *outputAlloc != "" {
+			alloc := post
+			if *touchedOnly {
+				alloc = post.touched(prestate.Pre)
+			}
+			if err := saveOutput(*outputAlloc, alloc); err != nil {
+				return err
+			}
+		}
+		diff := diffState(prestate.Pre, post)
+		if *printDiff {
+			fmt.Fprint(stdout, diff)
+		}
+		if *outputDiff != "" {
+			if err := saveOutput(*outputDiff, diff); err != nil {
+				return err
+			}
+		}
+	}
 
+	values := publicValues(envHash, txs, result)
+	fmt.Fprintf(stdout, "Public values: %#x\n", values.Encode())
+	// On the host, the public values reported by a guest run can be passed
+	// as the argument to check them against this run.
+	if flag.NArg() > 0 {
+		if err := verifyPublicValues(flag.Arg(0), values); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, "Public values verified")
+	}
+	return nil
+}
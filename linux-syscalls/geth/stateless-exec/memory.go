@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// memoryBudget is the RAM the run may use, such as "512MiB", or "" for no
+// limit. Guests without a command line set it with
+// -ldflags "-X main.memoryBudget=<size>".
+var memoryBudget = ""
+
+// memoryMonitor tracks the peak heap and the memory mapped by the runtime,
+// sampled after every garbage collection and by check, which main calls after
+// decoding the input and Apply calls after building the pre-state, running the
+// transactions and committing the state. It enforces the budget.
+//
+// A sample after a collection sees only the live heap, not the garbage the
+// collection freed, so the peak heap is a lower bound. The heap goal, the size
+// the runtime let the heap grow to before collecting, bounds it from above.
+type memoryMonitor struct {
+	budget uint64 // 0 for none
+
+	mu           sync.Mutex
+	samples      []metrics.Sample
+	peakHeap     uint64
+	peakHeapGoal uint64
+	peakMapped   uint64
+	gcs          uint64
+	stopped      bool
+}
+
+const (
+	heapObjectsMetric  = "/memory/classes/heap/objects:bytes"
+	totalMemoryMetric  = "/memory/classes/total:bytes"
+	heapReleasedMetric = "/memory/classes/heap/released:bytes"
+	gcCyclesMetric     = "/gc/cycles/total:gc-cycles"
+	heapGoalMetric     = "/gc/heap/goal:bytes"
+)
+
+// startMemoryMonitor starts monitoring, with the runtime's soft memory limit
+// set to the budget so that the collector works to stay under it.
+func startMemoryMonitor(budget uint64) *memoryMonitor {
+	m := &memoryMonitor{
+		budget: budget,
+		samples: []metrics.Sample{
+			{Name: heapObjectsMetric},
+			{Name: totalMemoryMetric},
+			{Name: heapReleasedMetric},
+			{Name: gcCyclesMetric},
+			{Name: heapGoalMetric},
+		},
+	}
+	if budget > 0 {
+		debug.SetMemoryLimit(int64(min(budget, math.MaxInt64)))
+	}
+	m.watchGC()
+	return m
+}
+
+// watchGC samples after the next garbage collection, and again after every
+// one that follows until the monitor is stopped.
+func (m *memoryMonitor) watchGC() {
+	sentinel := new([16]byte)
+	runtime.AddCleanup(sentinel, func(m *memoryMonitor) {
+		if err := m.check(); err != nil {
+			// The collection ran on behalf of some phase that cannot be
+			// interrupted, so end the run here, the way main does.
+			fmt.Fprintln(os.Stderr, err)
+			exit(exitCode(err))
+		}
+		m.mu.Lock()
+		stopped := m.stopped
+		m.mu.Unlock()
+		if !stopped {
+			m.watchGC()
+		}
+	}, m)
+}
+
+// check samples the memory use and returns an error if the budget is
+// exceeded.
+func (m *memoryMonitor) check() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics.Read(m.samples)
+	heap := m.samples[0].Value.Uint64()
+	mapped := m.samples[1].Value.Uint64() - m.samples[2].Value.Uint64()
+	m.peakHeap = max(m.peakHeap, heap)
+	m.peakHeapGoal = max(m.peakHeapGoal, m.samples[4].Value.Uint64())
+	m.peakMapped = max(m.peakMapped, mapped)
+	m.gcs = m.samples[3].Value.Uint64()
+	if m.budget > 0 && mapped > m.budget {
+		return NewError(ErrorMemoryBudget, fmt.Errorf("memory budget of %s exceeded: %s mapped, %s of heap", formatBytes(m.budget), formatBytes(mapped), formatBytes(heap)))
+	}
+	return nil
+}
+
+// stop takes a last sample and returns a summary of the run.
+func (m *memoryMonitor) stop() (string, error) {
+	err := m.check()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopped = true
+	summary := fmt.Sprintf("peak heap at least %s, heap goal %s, peak mapped %s, %d GCs", formatBytes(m.peakHeap), formatBytes(m.peakHeapGoal), formatBytes(m.peakMapped), m.gcs)
+	if m.budget > 0 {
+		summary += fmt.Sprintf(", budget %s", formatBytes(m.budget))
+	}
+	return summary, err
+}
+
+var byteUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}, {"B", 1},
+}
+
+// parseBytes parses a size in the format of GOMEMLIMIT, such as "512MiB" or
+// "1048576".
+func parseBytes(s string) (uint64, error) {
+	num, unit := s, uint64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			num, unit = strings.TrimSuffix(s, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseUint(num, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid memory size %q", s)
+	}
+	if n > math.MaxUint64/unit {
+		return 0, fmt.Errorf("memory size %q is too large", s)
+	}
+	return n * unit, nil
+}
+
+func formatBytes(n uint64) string {
+	return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+}
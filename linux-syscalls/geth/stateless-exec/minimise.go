@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// recordingDB is a database that records the keys read from it, so that the
+// trie nodes and code a run loads can be told from the rest.
+type recordingDB struct {
+	ethdb.Database
+
+	mu   sync.Mutex
+	read map[string]struct{}
+}
+
+func newRecordingDB() *recordingDB {
+	return &recordingDB{Database: rawdb.NewMemoryDatabase(), read: make(map[string]struct{})}
+}
+
+func (db *recordingDB) Has(key []byte) (bool, error) {
+	db.record(key)
+	return db.Database.Has(key)
+}
+
+func (db *recordingDB) Get(key []byte) ([]byte, error) {
+	db.record(key)
+	return db.Database.Get(key)
+}
+
+func (db *recordingDB) record(key []byte) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.read[string(key)] = struct{}{}
+}
+
+func (db *recordingDB) wasRead(key []byte) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, ok := db.read[string(key)]
+	return ok
+}
+
+// runWitness executes the block of in on its witness, building the state in
+// db if set, and checks the result against the roots and gas the block
+// header carries. Post-merge blocks carry no mining reward.
+func runWitness(in *StatelessInput, db ethdb.Database) (*ExecutionResult, error) {
+	var (
+		pre, txs    = in.prestate()
+		chainConfig = obtainChainConfig()
+		vmConfig    = obtainVmConfig()
+	)
+	for _, check := range []func(*stEnv, *params.ChainConfig) error{applyLondonChecks, applyShanghaiChecks, applyMergeChecks, applyCancunChecks} {
+		if err := check(&pre.Env, chainConfig); err != nil {
+			return nil, err
+		}
+	}
+	_, result, _, err := pre.Apply(*vmConfig, chainConfig, txs, -1, applyOptions{db: db})
+	if err != nil {
+		return nil, err
+	}
+	if err := in.checkResult(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkResult checks result against the roots and gas the block header of in
+// carries, where it sets them, and that no transaction was rejected.
+func (in *StatelessInput) checkResult(result *ExecutionResult) error {
+	header := in.Block.Header()
+	switch {
+	case len(result.Rejected) > 0:
+		return NewError(ErrorEVM, fmt.Errorf("block has an invalid transaction %d: %s", result.Rejected[0].Index, result.Rejected[0].Err))
+	case header.Root != (common.Hash{}) && header.Root != result.StateRoot:
+		return NewError(ErrorEVM, fmt.Errorf("state root %s, block has %s", result.StateRoot, header.Root))
+	case header.ReceiptHash != (common.Hash{}) && header.ReceiptHash != result.ReceiptRoot:
+		return NewError(ErrorEVM, fmt.Errorf("receipts root %s, block has %s", result.ReceiptRoot, header.ReceiptHash))
+	case header.GasUsed != 0 && header.GasUsed != uint64(result.GasUsed):
+		return NewError(ErrorEVM, fmt.Errorf("gas used %d, block has %d", uint64(result.GasUsed), header.GasUsed))
+	}
+	return nil
+}
+
+// minimiseWitness runs the block of the StatelessInput at path against its
+// witness and keeps the trie nodes and code the run read. The pruned input
+// must execute the block to the same result. The size reduction is printed,
+// and the pruned input written to out if it is set.
+func minimiseWitness(path, out string) error {
+	in, err := readStatelessInput(path)
+	if err != nil {
+		return err
+	}
+	db := newRecordingDB()
+	full, err := runWitness(in, db)
+	if err != nil {
+		return err
+	}
+	// Node witnesses carry only the headers BLOCKHASH reaches, so they are
+	// all kept.
+	pruned := &stateless.Witness{
+		Headers: in.Witness.Headers,
+		Codes:   make(map[string]struct{}),
+		State:   make(map[string]struct{}),
+	}
+	for code := range in.Witness.Codes {
+		hash := crypto.Keccak256Hash([]byte(code))
+		if db.wasRead(append(rawdb.CodePrefix[:len(rawdb.CodePrefix):len(rawdb.CodePrefix)], hash[:]...)) {
+			pruned.Codes[code] = struct{}{}
+		}
+	}
+	for node := range in.Witness.State {
+		if hash := crypto.Keccak256Hash([]byte(node)); db.wasRead(hash[:]) {
+			pruned.State[node] = struct{}{}
+		}
+	}
+	minimal := &StatelessInput{Block: in.Block, Witness: pruned}
+	result, err := runWitness(minimal, nil)
+	if err != nil {
+		return fmt.Errorf("the pruned witness does not execute the block: %w", err)
+	}
+	if result.StateRoot != full.StateRoot || result.ReceiptRoot != full.ReceiptRoot || result.GasUsed != full.GasUsed {
+		return NewError(ErrorEVM, errors.New("the pruned witness executes the block to a different result"))
+	}
+	before, err := json.Marshal(in)
+	if err != nil {
+		return NewError(ErrorJson, err)
+	}
+	after, err := json.Marshal(minimal)
+	if err != nil {
+		return NewError(ErrorJson, err)
+	}
+	fmt.Fprintf(stdout, "Witness before: %s\n", witnessSize(in.Witness))
+	fmt.Fprintf(stdout, "Witness after:  %s\n", witnessSize(pruned))
+	fmt.Fprintf(stdout, "Input: %d -> %d bytes (%.1f%% smaller), state root %s\n", len(before), len(after), 100-100*float64(len(after))/float64(len(before)), result.StateRoot)
+	if out != "" {
+		return saveOutput(out, minimal)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// MakePreState builds the state of accounts, in a Merkle-Patricia trie or,
+// with binaryTrie, in an EIP-7864 binary trie. The tries are written to db in
+// one pass and the state is opened once at their root, without going through
+// a StateDB commit.
+func MakePreState(db ethdb.Database, accounts types.GenesisAlloc, binaryTrie bool) (*state.StateDB, error) {
+	var (
+		sdb  state.Database
+		root common.Hash
+		err  error
+	)
+	if binaryTrie {
+		bdb := newBinaryDatabase(db)
+		sdb = bdb
+		root, err = writeBinaryTrie(bdb, accounts)
+	} else {
+		sdb = state.NewDatabase(triedb.NewDatabase(db, &triedb.Config{Preimages: true}), nil)
+		root, err = writeStateTrie(db, accounts)
+	}
+	if err != nil {
+		return nil, NewError(ErrorEVM, fmt.Errorf("could not build the pre-state: %v", err))
+	}
+	statedb, err := state.New(root, sdb)
+	if err != nil {
+		return nil, NewError(ErrorEVM, fmt.Errorf("could not open the pre-state %s: %v", root, err))
+	}
+	return statedb, nil
+}
+
+// makeState opens the pre-state in db, from the proofs or the witness if the
+// input came as one of them and from the alloc otherwise.
+func (pre *Prestate) makeState(db ethdb.Database, binaryTrie bool) (*state.StateDB, error) {
+	switch {
+	case pre.Proofs != nil:
+		return MakeProofState(db, pre.Proofs)
+	case pre.Witness != nil:
+		return MakeWitnessState(db, pre.Witness)
+	}
+	return MakePreState(db, pre.Pre, binaryTrie)
+}
+
+// trieLeaf is a key and value to insert into a stack trie.
+type trieLeaf struct {
+	key   common.Hash
+	value []byte
+}
+
+// stackTrieRoot inserts leaves in key order into a stack trie that writes its
+// nodes to w, and returns the root.
+func stackTrieRoot(w ethdb.KeyValueWriter, leaves []trieLeaf) common.Hash {
+	sort.Slice(leaves, func(i, j int) bool {
+		return bytes.Compare(leaves[i].key[:], leaves[j].key[:]) < 0
+	})
+	st := trie.NewStackTrie(func(_ []byte, hash common.Hash, blob []byte) {
+		rawdb.WriteLegacyTrieNode(w, hash, blob)
+	})
+	for _, leaf := range leaves {
+		st.Update(leaf.key[:], leaf.value)
+	}
+	return st.Hash()
+}
+
+// writeStateTrie writes the account trie, the storage tries, the code and the
+// preimages of the trie keys of accounts to db, in the layout of the hash
+// scheme, and returns the state root. Every key and node is hashed once.
+func writeStateTrie(db ethdb.Database, accounts types.GenesisAlloc) (common.Hash, error) {
+	var (
+		batch     = db.NewBatch()
+		preimages = make(map[common.Hash][]byte)
+		leaves    = make([]trieLeaf, 0, len(accounts))
+	)
+	for addr, a := range accounts {
+		account := types.StateAccount{
+			Nonce:    a.Nonce,
+			Balance:  uint256.MustFromBig(a.Balance),
+			Root:     types.EmptyRootHash,
+			CodeHash: types.EmptyCodeHash[:],
+		}
+		if len(a.Code) > 0 {
+			codeHash := crypto.Keccak256Hash(a.Code)
+			rawdb.WriteCode(batch, codeHash, a.Code)
+			account.CodeHash = codeHash[:]
+		}
+		var slots []trieLeaf
+		for k, v := range a.Storage {
+			// A StateDB drops zero slots rather than storing them.
+			if v == (common.Hash{}) {
+				continue
+			}
+			key := crypto.Keccak256Hash(k[:])
+			preimages[key] = common.CopyBytes(k[:])
+			value, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(v[:]))
+			slots = append(slots, trieLeaf{key, value})
+		}
+		if len(slots) > 0 {
+			account.Root = stackTrieRoot(batch, slots)
+		}
+		key := crypto.Keccak256Hash(addr[:])
+		preimages[key] = common.CopyBytes(addr[:])
+		value, _ := rlp.EncodeToBytes(&account)
+		leaves = append(leaves, trieLeaf{key, value})
+	}
+	root := stackTrieRoot(batch, leaves)
+	rawdb.WritePreimages(batch, preimages)
+	return root, batch.Write()
+}
+
+// writeBinaryTrie inserts accounts into a binary trie, commits it to db and
+// returns the root.
+func writeBinaryTrie(db *binaryDatabase, accounts types.GenesisAlloc) (common.Hash, error) {
+	t := newBinaryTrie(common.Hash{}, db)
+	for addr, a := range accounts {
+		codeHash := types.EmptyCodeHash
+		if len(a.Code) > 0 {
+			codeHash = crypto.Keccak256Hash(a.Code)
+			rawdb.WriteCode(db.disk, codeHash, a.Code)
+			if err := t.UpdateContractCode(addr, codeHash, a.Code); err != nil {
+				return common.Hash{}, err
+			}
+		}
+		err := t.UpdateAccount(addr, &types.StateAccount{
+			Nonce:    a.Nonce,
+			Balance:  uint256.MustFromBig(a.Balance),
+			CodeHash: codeHash[:],
+		}, len(a.Code))
+		if err != nil {
+			return common.Hash{}, err
+		}
+		for k, v := range a.Storage {
+			if v == (common.Hash{}) {
+				continue
+			}
+			if err := t.UpdateStorage(addr, k[:], v[:]); err != nil {
+				return common.Hash{}, err
+			}
+		}
+	}
+	// The binary trie writes its nodes straight to db, so Commit has no
+	// node set or error to return.
+	root, _ := t.Commit(false)
+	return root, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/tracing"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// makePreStateCommit is the builder MakePreState replaced, kept as the
+// baseline of the benchmarks: it sets every account in a StateDB, commits it
+// to the trie database and reopens the state at the committed root.
+func makePreStateCommit(db ethdb.Database, accounts types.GenesisAlloc, binaryTrie bool) (*state.StateDB, error) {
+	var (
+		sdb  state.Database
+		root = types.EmptyRootHash
+	)
+	if binaryTrie {
+		sdb, root = newBinaryDatabase(db), common.Hash{}
+	} else {
+		sdb = state.NewDatabase(triedb.NewDatabase(db, &triedb.Config{Preimages: true}), nil)
+	}
+	statedb, err := state.New(root, sdb)
+	if err != nil {
+		return nil, err
+	}
+	for addr, a := range accounts {
+		statedb.SetCode(addr, a.Code)
+		statedb.SetNonce(addr, a.Nonce, tracing.NonceChangeGenesis)
+		statedb.SetBalance(addr, uint256.MustFromBig(a.Balance), tracing.BalanceIncreaseGenesisBalance)
+		for k, v := range a.Storage {
+			statedb.SetState(addr, k, v)
+		}
+	}
+	// Commit and re-open to start with a clean state.
+	if root, err = statedb.Commit(0, false, false); err != nil {
+		return nil, err
+	}
+	return state.New(root, sdb)
+}
+
+type preStateAlloc struct {
+	name  string
+	alloc types.GenesisAlloc
+}
+
+// preStateAllocs returns the alloc of the sample block, and the same alloc
+// with 2,000 extra accounts, 200 of them contracts with 2KB of code and 20
+// slots each.
+func preStateAllocs(tb testing.TB) []preStateAlloc {
+	data, err := os.ReadFile("../assets/alloc.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var sample, large types.GenesisAlloc
+	if err := json.Unmarshal(data, &sample); err != nil {
+		tb.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &large); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < 2000; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(0x100000 + i)))
+		account := types.Account{Nonce: uint64(i), Balance: big.NewInt(int64(i) * 1e9)}
+		if i%10 == 0 {
+			account.Code = make([]byte, 2048)
+			for j := range account.Code {
+				account.Code[j] = byte(i + j)
+			}
+			account.Storage = make(map[common.Hash]common.Hash)
+			for j := 1; j <= 20; j++ {
+				account.Storage[common.BigToHash(big.NewInt(int64(j)))] = common.BigToHash(big.NewInt(int64(i*j + 1)))
+			}
+		}
+		large[addr] = account
+	}
+	return []preStateAlloc{{"sample", sample}, {"large", large}}
+}
+
+var preStateTries = []struct {
+	name       string
+	binaryTrie bool
+}{{"mpt", false}, {"binary", true}}
+
+func TestMakePreStateMatchesCommit(t *testing.T) {
+	for _, a := range preStateAllocs(t) {
+		for _, tr := range preStateTries {
+			want, err := makePreStateCommit(rawdb.NewMemoryDatabase(), a.alloc, tr.binaryTrie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := MakePreState(rawdb.NewMemoryDatabase(), a.alloc, tr.binaryTrie)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotRoot, wantRoot := got.IntermediateRoot(false), want.IntermediateRoot(false); gotRoot != wantRoot {
+				t.Errorf("%s alloc, %s: root %s, commit and reopen gives %s", a.name, tr.name, gotRoot, wantRoot)
+			}
+		}
+	}
+}
+
+// A balance the binary trie's basic data leaf cannot hold must fail the
+// build rather than drop the account.
+func TestMakePreStateWideBalance(t *testing.T) {
+	alloc := types.GenesisAlloc{
+		common.HexToAddress("0x1000"): {Balance: new(big.Int).Lsh(big.NewInt(1), 128)},
+	}
+	if _, err := MakePreState(rawdb.NewMemoryDatabase(), alloc, false); err != nil {
+		t.Fatalf("MPT: %v", err)
+	}
+	_, err := MakePreState(rawdb.NewMemoryDatabase(), alloc, true)
+	if code := exitCode(err); code != ErrorEVM {
+		t.Fatalf("binary trie: error %v, want code %d", err, ErrorEVM)
+	}
+}
+
+func benchmarkPreState(b *testing.B, build func(ethdb.Database, types.GenesisAlloc, bool) (*state.StateDB, error)) {
+	for _, a := range preStateAllocs(b) {
+		for _, tr := range preStateTries {
+			b.Run(a.name+"/"+tr.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					statedb, err := build(rawdb.NewMemoryDatabase(), a.alloc, tr.binaryTrie)
+					if err != nil {
+						b.Fatal(err)
+					}
+					statedb.IntermediateRoot(false)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkMakePreState(b *testing.B) {
+	benchmarkPreState(b, MakePreState)
+}
+
+func BenchmarkMakePreStateCommit(b *testing.B) {
+	benchmarkPreState(b, makePreStateCommit)
+}
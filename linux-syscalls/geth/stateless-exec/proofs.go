@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// stateProofs is a pre-state given as eth_getProof results against the
+// state root of the parent block, instead of a full alloc.
+type stateProofs struct {
+	StateRoot common.Hash    `json:"stateRoot"`
+	Accounts  []accountProof `json:"accounts"`
+}
+
+// accountProof is an eth_getProof result. eth_getProof leaves out the code,
+// so it is added from eth_getCode; accounts whose code the block does not
+// run can go without.
+type accountProof struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []storageProof  `json:"storageProof"`
+	Code         hexutil.Bytes   `json:"code,omitempty"`
+}
+
+// storageProof is a slot of an eth_getProof result. The key is echoed as the
+// caller passed it, so it may be shorter than 32 bytes.
+type storageProof struct {
+	Key   string          `json:"key"`
+	Value *hexutil.Big    `json:"value"`
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// errNotProven marks reads of accounts and slots the proofs do not cover.
+var errNotProven = errors.New("not covered by the proofs")
+
+// provenAccount is what the proofs cover of an account: all of its storage
+// if the storage trie is empty, otherwise the proven slots.
+type provenAccount struct {
+	emptyStorage bool
+	slots        map[common.Hash]struct{}
+}
+
+// readProofs reads a stateProofs file.
+func readProofs(path string) (*stateProofs, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, NewError(ErrorIO, fmt.Errorf("could not read %s: %v", path, err))
+	}
+	var proofs stateProofs
+	if err := json.Unmarshal(data, &proofs); err != nil {
+		return nil, NewError(ErrorJson, fmt.Errorf("could not parse %s: %v", path, err))
+	}
+	return &proofs, nil
+}
+
+// MakeProofState verifies proofs against their state root and opens a
+// partial state holding the proven accounts, slots and code. Reading
+// anything else from the state is an error, which the StateDB records.
+func MakeProofState(db ethdb.Database, proofs *stateProofs) (*state.StateDB, error) {
+	proven := make(map[common.Address]*provenAccount, len(proofs.Accounts))
+	for i := range proofs.Accounts {
+		p := &proofs.Accounts[i]
+		if proven[p.Address] != nil {
+			return nil, NewError(ErrorProof, fmt.Errorf("account %x: proven twice", p.Address))
+		}
+		writeProofNodes(db, p.AccountProof)
+		for _, slot := range p.StorageProof {
+			writeProofNodes(db, slot.Proof)
+		}
+		account, err := verifyAccountProof(db, proofs.StateRoot, p)
+		if err != nil {
+			return nil, NewError(ErrorProof, fmt.Errorf("account %x: %v", p.Address, err))
+		}
+		proven[p.Address] = account
+	}
+	sdb := &proofDatabase{state.NewDatabase(triedb.NewDatabase(db, nil), nil), proven}
+	statedb, err := state.New(proofs.StateRoot, sdb)
+	if err != nil {
+		return nil, NewError(ErrorProof, fmt.Errorf("could not open state %s: %v", proofs.StateRoot, err))
+	}
+	return statedb, nil
+}
+
+// writeProofNodes writes the nodes of a proof to db, in the layout of the
+// hash scheme. Nodes are keyed by their hash, so the proofs of all accounts
+// share one node set, and a trie opened on db resolves exactly the proven
+// paths.
+func writeProofNodes(db ethdb.KeyValueWriter, nodes []hexutil.Bytes) {
+	for _, node := range nodes {
+		rawdb.WriteLegacyTrieNode(db, crypto.Keccak256Hash(node), node)
+	}
+}
+
+// verifyAccountProof checks the account and storage proofs of p against root,
+// using the nodes in db, and that the fields p reports match the proven
+// account. The code, if given, is written to db.
+func verifyAccountProof(db ethdb.Database, root common.Hash, p *accountProof) (*provenAccount, error) {
+	enc, err := trie.VerifyProof(root, crypto.Keccak256(p.Address[:]), db)
+	if err != nil {
+		return nil, fmt.Errorf("invalid account proof: %v", err)
+	}
+	var (
+		balance = (*big.Int)(p.Balance)
+		account types.StateAccount
+	)
+	if balance == nil {
+		balance = new(big.Int)
+	}
+	if enc == nil {
+		// Absent accounts are reported as empty, with either zero or empty
+		// hashes depending on the client.
+		if p.Nonce != 0 || balance.Sign() != 0 ||
+			(p.CodeHash != (common.Hash{}) && p.CodeHash != types.EmptyCodeHash) ||
+			(p.StorageHash != (common.Hash{}) && p.StorageHash != types.EmptyRootHash) {
+			return nil, errors.New("proven absent, but reported with state")
+		}
+		account = types.StateAccount{Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash[:]}
+	} else {
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			return nil, fmt.Errorf("invalid proven account: %v", err)
+		}
+		switch {
+		case account.Nonce != uint64(p.Nonce):
+			return nil, fmt.Errorf("nonce %d, proof has %d", p.Nonce, account.Nonce)
+		case account.Balance.ToBig().Cmp(balance) != 0:
+			return nil, fmt.Errorf("balance %d, proof has %d", balance, account.Balance)
+		case common.BytesToHash(account.CodeHash) != p.CodeHash:
+			return nil, fmt.Errorf("code hash %s, proof has %x", p.CodeHash, account.CodeHash)
+		case account.Root != p.StorageHash:
+			return nil, fmt.Errorf("storage hash %s, proof has %s", p.StorageHash, account.Root)
+		}
+	}
+	if len(p.Code) > 0 {
+		codeHash := crypto.Keccak256Hash(p.Code)
+		if codeHash != common.BytesToHash(account.CodeHash) {
+			return nil, fmt.Errorf("code hashes to %s, account has %x", codeHash, account.CodeHash)
+		}
+		rawdb.WriteCode(db, codeHash, p.Code)
+	}
+	proven := &provenAccount{
+		emptyStorage: account.Root == types.EmptyRootHash,
+		slots:        make(map[common.Hash]struct{}, len(p.StorageProof)),
+	}
+	for _, slot := range p.StorageProof {
+		key, err := decodeStorageKey(slot.Key)
+		if err != nil {
+			return nil, fmt.Errorf("slot %q: %v", slot.Key, err)
+		}
+		var value []byte
+		if !proven.emptyStorage {
+			enc, err := trie.VerifyProof(account.Root, crypto.Keccak256(key[:]), db)
+			if err != nil {
+				return nil, fmt.Errorf("slot %s: invalid storage proof: %v", key, err)
+			}
+			if enc != nil {
+				if _, value, _, err = rlp.Split(enc); err != nil {
+					return nil, fmt.Errorf("slot %s: invalid proven value: %v", key, err)
+				}
+			}
+		}
+		want := (*big.Int)(slot.Value)
+		if want == nil {
+			want = new(big.Int)
+		}
+		if got := new(big.Int).SetBytes(value); got.Cmp(want) != 0 {
+			return nil, fmt.Errorf("slot %s: value %#x, proof has %#x", key, want, got)
+		}
+		proven.slots[key] = struct{}{}
+	}
+	return proven, nil
+}
+
+// decodeStorageKey decodes a storage key as eth_getProof accepts it: hex of
+// up to 32 bytes, left-padded with zeros.
+func decodeStorageKey(s string) (common.Hash, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if len(b) > common.HashLength {
+		return common.Hash{}, fmt.Errorf("key longer than %d bytes", common.HashLength)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// proofDatabase is the state database of a pre-state built from proofs. Its
+// readers refuse the accounts and slots the proofs do not cover, so that a
+// block reading them fails rather than seeing them as empty, even where the
+// proven nodes would resolve the read.
+type proofDatabase struct {
+	state.Database
+	proven map[common.Address]*provenAccount
+}
+
+func (db *proofDatabase) Reader(root common.Hash) (state.Reader, error) {
+	r, err := db.Database.Reader(root)
+	if err != nil {
+		return nil, err
+	}
+	return &proofReader{r, db.proven}, nil
+}
+
+// proofReader is a state.Reader limited to the proven state. Code is not
+// checked: code missing from the input already fails the read.
+type proofReader struct {
+	state.Reader
+	proven map[common.Address]*provenAccount
+}
+
+func (r *proofReader) Account(addr common.Address) (*types.StateAccount, error) {
+	if r.proven[addr] == nil {
+		return nil, fmt.Errorf("account %x: %w", addr, errNotProven)
+	}
+	return r.Reader.Account(addr)
+}
+
+func (r *proofReader) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	account := r.proven[addr]
+	switch {
+	case account == nil:
+		return common.Hash{}, fmt.Errorf("account %x: %w", addr, errNotProven)
+	case account.emptyStorage:
+		return common.Hash{}, nil
+	}
+	if _, ok := account.slots[slot]; !ok {
+		return common.Hash{}, fmt.Errorf("slot %s of account %x: %w", slot, addr, errNotProven)
+	}
+	return r.Reader.Storage(addr, slot)
+}
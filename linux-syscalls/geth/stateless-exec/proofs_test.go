@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// proofNodes collects the nodes trie.Prove writes.
+type proofNodes []hexutil.Bytes
+
+func (p *proofNodes) Put(_, value []byte) error {
+	*p = append(*p, common.CopyBytes(value))
+	return nil
+}
+
+func (p *proofNodes) Delete([]byte) error {
+	return nil
+}
+
+var (
+	proofTestContract = common.HexToAddress("0x1000")
+	proofTestOwner    = common.HexToAddress("0x2000")
+	proofTestOther    = common.HexToAddress("0x3000")
+	proofTestSlot     = common.Hash{31: 1}
+)
+
+// testProofs returns the eth_getProof results for the contract, with its
+// slot, and the owner, in a small state that also holds another account.
+func testProofs(t *testing.T) *stateProofs {
+	alloc := types.GenesisAlloc{
+		proofTestContract: {Balance: big.NewInt(1), Code: []byte{0x00}, Storage: map[common.Hash]common.Hash{proofTestSlot: {31: 7}, {31: 2}: {31: 8}}},
+		proofTestOwner:    {Balance: big.NewInt(1e18), Nonce: 3},
+		proofTestOther:    {Balance: big.NewInt(5)},
+	}
+	db := rawdb.NewMemoryDatabase()
+	statedb, err := MakePreState(db, alloc, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := statedb.IntermediateRoot(false)
+	tdb := triedb.NewDatabase(db, nil)
+	accounts, err := trie.NewStateTrie(trie.StateTrieID(root), tdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proofs := &stateProofs{StateRoot: root}
+	for _, addr := range []common.Address{proofTestContract, proofTestOwner} {
+		p := accountProof{
+			Address:     addr,
+			Balance:     (*hexutil.Big)(statedb.GetBalance(addr).ToBig()),
+			CodeHash:    statedb.GetCodeHash(addr),
+			Nonce:       hexutil.Uint64(statedb.GetNonce(addr)),
+			StorageHash: statedb.GetStorageRoot(addr),
+			Code:        statedb.GetCode(addr),
+		}
+		if err := accounts.Prove(crypto.Keccak256(addr[:]), (*proofNodes)(&p.AccountProof)); err != nil {
+			t.Fatal(err)
+		}
+		if addr == proofTestContract {
+			storage, err := trie.NewStateTrie(trie.StorageTrieID(root, crypto.Keccak256Hash(addr[:]), p.StorageHash), tdb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slot := storageProof{Key: proofTestSlot.Hex(), Value: (*hexutil.Big)(statedb.GetState(addr, proofTestSlot).Big())}
+			if err := storage.Prove(crypto.Keccak256(proofTestSlot[:]), (*proofNodes)(&slot.Proof)); err != nil {
+				t.Fatal(err)
+			}
+			p.StorageProof = []storageProof{slot}
+		}
+		proofs.Accounts = append(proofs.Accounts, p)
+	}
+	return proofs
+}
+
+func TestMakeProofStateRejectsTampering(t *testing.T) {
+	if _, err := MakeProofState(rawdb.NewMemoryDatabase(), testProofs(t)); err != nil {
+		t.Fatalf("untampered proofs: %v", err)
+	}
+	tests := map[string]func(*stateProofs){
+		"balance": func(p *stateProofs) { p.Accounts[1].Balance = (*hexutil.Big)(big.NewInt(2e18)) },
+		"slot":    func(p *stateProofs) { p.Accounts[0].StorageProof[0].Value = (*hexutil.Big)(big.NewInt(9)) },
+	}
+	for name, tamper := range tests {
+		proofs := testProofs(t)
+		tamper(proofs)
+		_, err := MakeProofState(rawdb.NewMemoryDatabase(), proofs)
+		if code := exitCode(err); code != ErrorProof {
+			t.Errorf("tampered %s: error %v, want code %d", name, err, ErrorProof)
+		}
+	}
+}
+
+// A block that reads an account without a proof fails with the missing state
+// status, naming the account.
+func TestProofStateUnprovenAccount(t *testing.T) {
+	for _, recipient := range []common.Address{proofTestOwner, proofTestOther} {
+		pre := &Prestate{
+			Env: stEnv{
+				Coinbase:    proofTestOwner,
+				Difficulty:  new(big.Int),
+				GasLimit:    30_000_000,
+				Number:      1,
+				Timestamp:   1000,
+				Withdrawals: []*types.Withdrawal{{Address: recipient, Amount: 1}},
+			},
+			Proofs: testProofs(t),
+		}
+		_, _, _, err := pre.Apply(*obtainVmConfig(), obtainChainConfig(), newSliceTxIterator(nil), 0, applyOptions{})
+		switch {
+		case recipient == proofTestOwner && err != nil:
+			t.Errorf("withdrawal to a proven account: %v", err)
+		case recipient == proofTestOther && exitCode(err) != ErrorMissingState:
+			t.Errorf("withdrawal to an unproven account: error %v, want code %d", err, ErrorMissingState)
+		case recipient == proofTestOther && !strings.Contains(err.Error(), fmt.Errorf("account %x: %w", proofTestOther, errNotProven).Error()):
+			t.Errorf("withdrawal to an unproven account: error %v does not name the account", err)
+		}
+	}
+}
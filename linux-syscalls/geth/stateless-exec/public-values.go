@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"github.com/eth-act/riscv-compilation/cryptobackend"
+	"github.com/eth-act/riscv-compilation/publicvalues"
+)
+
+// txRecorder passes transactions through to Apply and records their hashes,
+// which the public values commit to.
+type txRecorder struct {
+	txIterator
+	hashes []common.Hash
+}
+
+func (r *txRecorder) Tx() (*types.Transaction, error) {
+	tx, err := r.txIterator.Tx()
+	var h common.Hash
+	if err == nil {
+		h = tx.Hash()
+	}
+	r.hashes = append(r.hashes, h)
+	return tx, err
+}
+
+// hashEnv returns the keccak256 hash of the compact encoding of env, taken
+// before the fork checks fill in derived fields. The encoding fixes the order
+// and form of every field, so the commitment does not move with the JSON
+// encoding of stEnv.
+func hashEnv(env *stEnv) common.Hash {
+	w := rlp.NewEncoderBuffer(nil)
+	encodeEnv(w, env)
+	return cryptobackend.Default.Keccak256(w.ToBytes())
+}
+
+// publicValues collects the public values of an execution.
+func publicValues(envHash common.Hash, txs *txRecorder, result *ExecutionResult) *publicvalues.Values {
+	values := &publicvalues.Values{
+		Input:        publicvalues.InputCommitment(result.ParentStateRoot, envHash, publicvalues.TransactionsHash(txs.hashes)),
+		StateRoot:    result.StateRoot,
+		ReceiptsRoot: result.ReceiptRoot,
+		GasUsed:      uint64(result.GasUsed),
+	}
+	if result.RequestsHash != nil {
+		values.RequestsHash = *result.RequestsHash
+	}
+	return values
+}
+
+// verifyPublicValues checks the public values a guest run claims, given in
+// hex, against the values recomputed by this run.
+func verifyPublicValues(hexValues string, values *publicvalues.Values) error {
+	claimed, err := publicvalues.Decode(common.FromHex(hexValues))
+	if err != nil {
+		return NewError(ErrorPublicValues, err)
+	}
+	if diffs := values.Diff(claimed); diffs != nil {
+		return NewError(ErrorPublicValues, fmt.Errorf("public values do not match:\n  %s", strings.Join(diffs, "\n  ")))
+	}
+	return nil
+}
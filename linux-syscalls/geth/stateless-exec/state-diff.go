@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The status of an account in a StateDiff.
+const (
+	accountCreated        = "created"
+	accountChanged        = "changed"
+	accountSelfDestructed = "self-destructed"
+	// An empty account that the block touched, deleted by EIP-161.
+	accountRemoved = "removed"
+)
+
+// StateDiff is the difference between the pre-state and the post-state of a
+// block, per account, in address order.
+type StateDiff []AccountDiff
+
+// AccountDiff lists what changed in one account. Fields that did not change
+// are nil.
+type AccountDiff struct {
+	Address    common.Address                      `json:"address"`
+	Status     string                              `json:"status"`
+	Balance    *Change[*hexutil.Big]               `json:"balance,omitempty"`
+	Nonce      *Change[hexutil.Uint64]             `json:"nonce,omitempty"`
+	CodeHash   *Change[common.Hash]                `json:"codeHash,omitempty"`
+	Delegation *Change[*common.Address]            `json:"delegation,omitempty"` // EIP-7702 delegation target
+	Storage    map[common.Hash]Change[common.Hash] `json:"storage,omitempty"`
+}
+
+// Change is the old and new value of a field.
+type Change[T any] struct {
+	Old T `json:"old"`
+	New T `json:"new"`
+}
+
+// diffState compares pre with post, an alloc of the full post-state.
+func diffState(pre types.GenesisAlloc, post Alloc) StateDiff {
+	var diff StateDiff
+	for addr, old := range pre {
+		if _, ok := post[addr]; ok {
+			continue
+		}
+		status := accountSelfDestructed
+		if old.Nonce == 0 && bigOrZero(old.Balance).Sign() == 0 && len(old.Code) == 0 {
+			status = accountRemoved
+		}
+		diff = append(diff, diffAccount(addr, status, old, types.Account{}))
+	}
+	for addr, account := range post {
+		old, ok := pre[addr]
+		switch {
+		case !ok:
+			diff = append(diff, diffAccount(addr, accountCreated, types.Account{}, account))
+		case !sameAccount(old, account):
+			diff = append(diff, diffAccount(addr, accountChanged, old, account))
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		return bytes.Compare(diff[i].Address[:], diff[j].Address[:]) < 0
+	})
+	return diff
+}
+
+func diffAccount(addr common.Address, status string, old, new types.Account) AccountDiff {
+	d := AccountDiff{Address: addr, Status: status}
+	if oldBalance, newBalance := bigOrZero(old.Balance), bigOrZero(new.Balance); oldBalance.Cmp(newBalance) != 0 {
+		d.Balance = &Change[*hexutil.Big]{(*hexutil.Big)(oldBalance), (*hexutil.Big)(newBalance)}
+	}
+	if old.Nonce != new.Nonce {
+		d.Nonce = &Change[hexutil.Uint64]{hexutil.Uint64(old.Nonce), hexutil.Uint64(new.Nonce)}
+	}
+	if !bytes.Equal(old.Code, new.Code) {
+		d.CodeHash = &Change[common.Hash]{codeHash(old.Code), codeHash(new.Code)}
+		oldTarget, newTarget := delegation(old.Code), delegation(new.Code)
+		if oldTarget != nil || newTarget != nil {
+			d.Delegation = &Change[*common.Address]{oldTarget, newTarget}
+		}
+	}
+	for k, v := range old.Storage {
+		if new.Storage[k] != v {
+			d.addSlot(k, v, new.Storage[k])
+		}
+	}
+	for k, v := range new.Storage {
+		if _, ok := old.Storage[k]; !ok && v != (common.Hash{}) {
+			d.addSlot(k, common.Hash{}, v)
+		}
+	}
+	return d
+}
+
+func (d *AccountDiff) addSlot(key, old, new common.Hash) {
+	if d.Storage == nil {
+		d.Storage = make(map[common.Hash]Change[common.Hash])
+	}
+	d.Storage[key] = Change[common.Hash]{old, new}
+}
+
+func codeHash(code []byte) common.Hash {
+	if len(code) == 0 {
+		return types.EmptyCodeHash
+	}
+	return crypto.Keccak256Hash(code)
+}
+
+func delegation(code []byte) *common.Address {
+	if addr, ok := types.ParseDelegation(code); ok {
+		return &addr
+	}
+	return nil
+}
+
+// String renders the diff for reading.
+func (diff StateDiff) String() string {
+	var sb strings.Builder
+	for _, d := range diff {
+		fmt.Fprintf(&sb, "%s %s\n", d.Address, d.Status)
+		if d.Balance != nil {
+			fmt.Fprintf(&sb, "  balance     %s -> %s\n", (*big.Int)(d.Balance.Old), (*big.Int)(d.Balance.New))
+		}
+		if d.Nonce != nil {
+			fmt.Fprintf(&sb, "  nonce       %d -> %d\n", d.Nonce.Old, d.Nonce.New)
+		}
+		if d.CodeHash != nil {
+			fmt.Fprintf(&sb, "  code hash   %s -> %s\n", d.CodeHash.Old, d.CodeHash.New)
+		}
+		if d.Delegation != nil {
+			fmt.Fprintf(&sb, "  delegation  %s -> %s\n", formatDelegation(d.Delegation.Old), formatDelegation(d.Delegation.New))
+		}
+		keys := make([]common.Hash, 0, len(d.Storage))
+		for k := range d.Storage {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "  storage     %s: %s -> %s\n", k, d.Storage[k].Old, d.Storage[k].New)
+		}
+	}
+	if len(diff) == 0 {
+		sb.WriteString("no state changes\n")
+	}
+	return sb.String()
+}
+
+func formatDelegation(addr *common.Address) string {
+	if addr == nil {
+		return "none"
+	}
+	return addr.Hex()
+}
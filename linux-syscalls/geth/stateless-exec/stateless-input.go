@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/triedb"
+)
+
+// statelessInputJSON is the JSON form of a StatelessInput. Neither a block
+// nor a witness has a JSON encoding of its own, so both are carried as hex
+// of their RLP, the way the engine API carries witnesses.
+type statelessInputJSON struct {
+	Block   hexutil.Bytes `json:"block"`
+	Witness hexutil.Bytes `json:"witness"`
+}
+
+func (in *StatelessInput) MarshalJSON() ([]byte, error) {
+	block, err := rlp.EncodeToBytes(in.Block)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := encodeWitness(in.Witness)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&statelessInputJSON{block, witness})
+}
+
+// encodeWitness returns the consensus encoding of w with its code and trie
+// nodes sorted, so that the same witness always encodes to the same bytes.
+func encodeWitness(w *stateless.Witness) ([]byte, error) {
+	headers, err := rlp.EncodeToBytes(w.Headers)
+	if err != nil {
+		return nil, err
+	}
+	buf := rlp.NewEncoderBuffer(nil)
+	l := buf.List()
+	buf.Write(headers)
+	for _, set := range []map[string]struct{}{w.Codes, w.State} {
+		items := make([]string, 0, len(set))
+		for item := range set {
+			items = append(items, item)
+		}
+		sort.Strings(items)
+		list := buf.List()
+		for _, item := range items {
+			buf.WriteString(item)
+		}
+		buf.ListEnd(list)
+	}
+	buf.ListEnd(l)
+	return buf.ToBytes(), nil
+}
+
+func (in *StatelessInput) UnmarshalJSON(input []byte) error {
+	var enc statelessInputJSON
+	if err := json.Unmarshal(input, &enc); err != nil {
+		return err
+	}
+	in.Block, in.Witness = new(types.Block), new(stateless.Witness)
+	if err := rlp.DecodeBytes(enc.Block, in.Block); err != nil {
+		return fmt.Errorf("invalid block: %v", err)
+	}
+	if err := rlp.DecodeBytes(enc.Witness, in.Witness); err != nil {
+		return fmt.Errorf("invalid witness: %v", err)
+	}
+	return nil
+}
+
+// readStatelessInput reads a StatelessInput file, in JSON or the compact
+// encoding, and checks that its witness starts at the parent of its block.
+func readStatelessInput(path string) (*StatelessInput, error) {
+	data, err := readFile(path)
+	if err != nil {
+		return nil, NewError(ErrorIO, fmt.Errorf("could not read %s: %v", path, err))
+	}
+	in, err := decodeStatelessInput(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := in.checkWitness(); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+// checkWitness checks that the witness of in starts at the parent of its
+// block.
+func (in *StatelessInput) checkWitness() error {
+	switch {
+	case len(in.Witness.Headers) == 0:
+		return NewError(ErrorConfig, errors.New("the witness has no parent header"))
+	case in.Witness.Headers[0].Hash() != in.Block.ParentHash():
+		return NewError(ErrorConfig, fmt.Errorf("the witness starts at %s, not at the parent %s of the block", in.Witness.Headers[0].Hash(), in.Block.ParentHash()))
+	}
+	return nil
+}
+
+// decodeStatelessInput decodes a StatelessInput in JSON or the compact
+// encoding. name is used in errors.
+func decodeStatelessInput(data []byte, name string) (*StatelessInput, error) {
+	compact, err := isCompact(data)
+	if err != nil {
+		return nil, NewError(ErrorJson, fmt.Errorf("could not parse %s: %v", name, err))
+	}
+	if compact {
+		in, err := decodeCompactStateless(data)
+		if err != nil {
+			return nil, NewError(ErrorRlp, fmt.Errorf("could not decode %s: %v", name, err))
+		}
+		return in, nil
+	}
+	in := new(StatelessInput)
+	if err := json.Unmarshal(data, in); err != nil {
+		return nil, NewError(ErrorJson, fmt.Errorf("could not parse %s: %v", name, err))
+	}
+	return in, nil
+}
+
+// prestate returns the block of in as a pre-state and environment for Apply,
+// with the pre-state in the witness, and its transactions.
+func (in *StatelessInput) prestate() (*Prestate, txIterator) {
+	header := in.Block.Header()
+	env := stEnv{
+		Coinbase:              header.Coinbase,
+		Difficulty:            header.Difficulty,
+		GasLimit:              header.GasLimit,
+		Number:                header.Number.Uint64(),
+		Timestamp:             header.Time,
+		BlockHashes:           make(map[math.HexOrDecimal64]common.Hash),
+		Withdrawals:           in.Block.Withdrawals(),
+		BaseFee:               header.BaseFee,
+		ExcessBlobGas:         header.ExcessBlobGas,
+		ParentBeaconBlockRoot: header.ParentBeaconRoot,
+	}
+	if header.WithdrawalsHash != nil && env.Withdrawals == nil {
+		env.Withdrawals = []*types.Withdrawal{}
+	}
+	if header.Difficulty == nil || header.Difficulty.Sign() == 0 {
+		env.Random = new(big.Int).SetBytes(header.MixDigest[:])
+	}
+	// BLOCKHASH can reach the headers the witness carries.
+	for _, h := range in.Witness.Headers {
+		env.BlockHashes[math.HexOrDecimal64(h.Number.Uint64())] = h.Hash()
+	}
+	return &Prestate{Env: env, Witness: in.Witness}, newSliceTxIterator(in.Block.Transactions())
+}
+
+// MakeWitnessState imports the trie nodes and code of w into db and opens the
+// state at the pre-state root of w, the state root of its parent header.
+func MakeWitnessState(db ethdb.Database, w *stateless.Witness) (*state.StateDB, error) {
+	writeWitness(db, w)
+	statedb, err := state.New(w.Root(), state.NewDatabase(triedb.NewDatabase(db, triedb.HashDefaults), nil))
+	if err != nil {
+		return nil, NewError(ErrorMissingState, fmt.Errorf("could not open the witness state %s: %v", w.Root(), err))
+	}
+	return statedb, nil
+}
+
+// writeWitness writes the trie nodes and code of w to db, keyed by hash in the
+// layout of the hash scheme, as stateless.Witness.MakeHashDB does.
+func writeWitness(db ethdb.KeyValueWriter, w *stateless.Witness) {
+	for code := range w.Codes {
+		rawdb.WriteCode(db, crypto.Keccak256Hash([]byte(code)), []byte(code))
+	}
+	for node := range w.State {
+		rawdb.WriteLegacyTrieNode(db, crypto.Keccak256Hash([]byte(node)), []byte(node))
+	}
+}
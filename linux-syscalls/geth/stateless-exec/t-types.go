@@ -86,16 +86,46 @@ func (t *txWithKey) UnmarshalJSON(input []byte) error {
 	return nil
 }
 
+// MarshalJSON writes the transaction with its secretKey and protected flag,
+// in the form UnmarshalJSON reads.
+func (t *txWithKey) MarshalJSON() ([]byte, error) {
+	enc, err := t.tx.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		return nil, err
+	}
+	if t.key != nil {
+		fields["secretKey"], _ = json.Marshal(common.BytesToHash(crypto.FromECDSA(t.key)))
+	}
+	if !t.protected {
+		fields["protected"] = json.RawMessage("false")
+	}
+	return json.Marshal(fields)
+}
+
 type input struct {
 	Alloc types.GenesisAlloc `json:"alloc,omitempty"`
 	Env   *stEnv             `json:"env,omitempty"`
 	Txs   []*txWithKey       `json:"txs,omitempty"`
 	TxRlp string             `json:"txsRlp,omitempty"`
+	// Proofs replaces Alloc with eth_getProof results for the state the
+	// block touches.
+	Proofs *stateProofs `json:"proofs,omitempty"`
+	// Stateless, if set, replaces the rest with a block and its witness,
+	// when the input is given whole as a StatelessInput.
+	Stateless *StatelessInput `json:"-"`
 }
 
 type Prestate struct {
 	Env stEnv              `json:"env"`
 	Pre types.GenesisAlloc `json:"pre"`
+	// Proofs, if set, replaces Pre.
+	Proofs *stateProofs `json:"proofs,omitempty"`
+	// Witness, if set, replaces Pre with the state a stateless witness holds.
+	Witness *stateless.Witness `json:"-"`
 }
 
 type txIterator interface {
@@ -132,9 +162,23 @@ type ExecutionResult struct {
 	CurrentBlobGasUsed   *math.HexOrDecimal64  `json:"blobGasUsed,omitempty"`
 	RequestsHash         *common.Hash          `json:"requestsHash,omitempty"`
 	Requests             [][]byte              `json:"requests"`
+	IntermediateRoots    []*intermediateRoot   `json:"intermediateRoots,omitempty"`
+
+	// ParentStateRoot is the state root of the pre-state. It is not part of
+	// the t8n result, only of the public values.
+	ParentStateRoot common.Hash `json:"-"`
 }
 
 type rejectedTx struct {
 	Index int    `json:"index"`
 	Err   string `json:"error"`
 }
+
+// intermediateRoot is the state after an included transaction, recorded when
+// Apply is asked to for debugging.
+type intermediateRoot struct {
+	Index             int                 `json:"index"`
+	TxHash            common.Hash         `json:"txHash"`
+	StateRoot         common.Hash         `json:"stateRoot"`
+	CumulativeGasUsed math.HexOrDecimal64 `json:"cumulativeGasUsed"`
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -17,22 +17,7 @@ import (
 func signUnsignedTransactions(txs []*txWithKey, signer types.Signer) (types.Transactions, error) {
 	var signedTxs []*types.Transaction
 	for i, tx := range txs {
-		var (
-			v, r, s = tx.tx.RawSignatureValues()
-			signed  *types.Transaction
-			err     error
-		)
-		if tx.key == nil || v.BitLen()+r.BitLen()+s.BitLen() != 0 {
-			// Already signed
-			signedTxs = append(signedTxs, tx.tx)
-			continue
-		}
-		// This transaction needs to be signed
-		if tx.protected {
-			signed, err = types.SignTx(tx.tx, signer, tx.key)
-		} else {
-			signed, err = types.SignTx(tx.tx, types.HomesteadSigner{}, tx.key)
-		}
+		signed, err := signTx(tx, signer)
 		if err != nil {
 			return nil, NewError(ErrorJson, fmt.Errorf("tx %d: failed to sign tx: %v", i, err))
 		}
@@ -41,6 +26,20 @@ func signUnsignedTransactions(txs []*txWithKey, signer types.Signer) (types.Tran
 	return signedTxs, nil
 }
 
+// signTx signs tx with its key, unless it has no key or is already signed.
+func signTx(tx *txWithKey, signer types.Signer) (*types.Transaction, error) {
+	v, r, s := tx.tx.RawSignatureValues()
+	if tx.key == nil || v.BitLen()+r.BitLen()+s.BitLen() != 0 {
+		// Already signed
+		return tx.tx, nil
+	}
+	// This transaction needs to be signed
+	if tx.protected {
+		return types.SignTx(tx.tx, signer, tx.key)
+	}
+	return types.SignTx(tx.tx, types.HomesteadSigner{}, tx.key)
+}
+
 func newSliceTxIterator(transactions types.Transactions) txIterator {
 	return &sliceTxIterator{0, transactions}
 }
@@ -57,24 +56,118 @@ func (ait *sliceTxIterator) Tx() (*types.Transaction, error) {
 	return nil, io.EOF
 }
 
+// jsonTxIterator streams a JSON array of transactions from a file. Each one
+// is decoded and signed only when Apply asks for it, so neither the file nor
+// the unsigned transactions are ever held whole. As when the file was read
+// whole, an element that is not a valid transaction, or cannot be signed,
+// ends the run rather than being rejected: it ends the stream, as malformed
+// JSON does, and Err reports it.
+type jsonTxIterator struct {
+	file   io.ReadCloser
+	dec    *json.Decoder
+	signer types.Signer
+	idx    int
+	done   bool
+	err    error
+}
+
+// newJSONTxIterator reads file up to the start of its array. The iterator
+// closes file once the array ends.
+func newJSONTxIterator(file io.ReadCloser, signer types.Signer) (*jsonTxIterator, error) {
+	dec := json.NewDecoder(file)
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		file.Close()
+		return nil, errors.New("transactions are not a JSON array")
+	}
+	return &jsonTxIterator{file: file, dec: dec, signer: signer}, nil
+}
+
+func (it *jsonTxIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	if it.dec.More() {
+		return true
+	}
+	// The array must be closed, with nothing after it.
+	if tok, err := it.dec.Token(); err != nil {
+		it.stop(malformedTxs(err))
+	} else if tok != json.Delim(']') {
+		it.stop(malformedTxs(errors.New("unterminated transaction array")))
+	} else if _, err := it.dec.Token(); err != io.EOF {
+		it.stop(malformedTxs(errors.New("data after the transaction array")))
+	} else {
+		it.stop(nil)
+	}
+	return false
+}
+
+func (it *jsonTxIterator) Tx() (*types.Transaction, error) {
+	// Reading the element whole first tells malformed JSON, after which the
+	// decoder cannot go on, from a transaction that does not decode.
+	var raw json.RawMessage
+	if err := it.dec.Decode(&raw); err != nil {
+		it.stop(malformedTxs(err))
+		return nil, it.err
+	}
+	i := it.idx
+	it.idx++
+	var tx txWithKey
+	if err := json.Unmarshal(raw, &tx); err != nil {
+		it.stop(NewError(ErrorJson, fmt.Errorf("tx %d: failed to decode tx: %v", i, err)))
+		return nil, it.err
+	}
+	signed, err := signTx(&tx, it.signer)
+	if err != nil {
+		it.stop(NewError(ErrorJson, fmt.Errorf("tx %d: failed to sign tx: %v", i, err)))
+		return nil, it.err
+	}
+	return signed, nil
+}
+
+// Err returns the error that ended the stream early, if any.
+func (it *jsonTxIterator) Err() error {
+	return it.err
+}
+
+// malformedTxs is the error of a transactions file that is not valid JSON.
+func malformedTxs(err error) error {
+	return NewError(ErrorJson, fmt.Errorf("failed unmarshalling txs-file: %v", err))
+}
+
+func (it *jsonTxIterator) stop(err error) {
+	it.done, it.err = true, err
+	it.file.Close()
+}
+
 func loadTransactions(txStr string, inputData *input, chainConfig *params.ChainConfig) (txIterator, error) {
 	var txsWithKeys []*txWithKey
+	signer := types.LatestSignerForChainID(chainConfig.ChainID)
 	if txStr != stdinSelector {
 		println(txStr)
-		data, err := os.ReadFile(txStr)
+		if !strings.HasSuffix(txStr, ".rlp") {
+			// JSON transactions are streamed from the file, and decoded and
+			// signed as they are applied.
+			file, err := openFile(txStr)
+			if err != nil {
+				return nil, NewError(ErrorIO, fmt.Errorf("failed reading txs file: %v", err))
+			}
+			txIt, err := newJSONTxIterator(file, signer)
+			if err != nil {
+				return nil, NewError(ErrorJson, fmt.Errorf("failed unmarshalling txs-file: %v", err))
+			}
+			return txIt, nil
+		}
+		data, err := readFile(txStr)
 		if err != nil {
 			return nil, NewError(ErrorIO, fmt.Errorf("failed reading txs file: %v", err))
 		}
-		if strings.HasSuffix(txStr, ".rlp") { // A file containing an rlp list
-			var body hexutil.Bytes
-			if err := json.Unmarshal(data, &body); err != nil {
-				return nil, err
-			}
-			return newRlpTxIterator(body), nil
-		}
-		if err := json.Unmarshal(data, &txsWithKeys); err != nil {
-			return nil, NewError(ErrorJson, fmt.Errorf("failed unmarshalling txs-file: %v", err))
+		// A file containing an rlp list
+		var body hexutil.Bytes
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, err
 		}
+		return newRlpTxIterator(body), nil
 	} else {
 		if len(inputData.TxRlp) > 0 {
 			// Decode the body of already signed transactions
@@ -84,7 +177,6 @@ func loadTransactions(txStr string, inputData *input, chainConfig *params.ChainC
 		txsWithKeys = inputData.Txs
 	}
 	// We may have to sign the transactions.
-	signer := types.LatestSignerForChainID(chainConfig.ChainID)
 	txs, err := signUnsignedTransactions(txsWithKeys, signer)
 	return newSliceTxIterator(txs), err
 }
\ No newline at end of file
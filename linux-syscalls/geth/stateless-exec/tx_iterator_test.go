@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// A transaction that does not decode or sign ends the stream and fails the
+// run, as it did when the file was read whole.
+func TestJSONTxIteratorStopsOnBadTx(t *testing.T) {
+	data, err := os.ReadFile("../assets/tx.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &txs); err != nil {
+		t.Fatal(err)
+	}
+	unsignable := make(map[string]json.RawMessage)
+	for k, v := range txs[0] {
+		unsignable[k] = v
+	}
+	// Unprotected transactions are signed as Homestead ones, which cannot
+	// be typed.
+	unsignable["protected"] = json.RawMessage("false")
+	tests := map[string]struct {
+		tx   any
+		want string
+	}{
+		"decode": {map[string]string{"type": "0x2", "nonce": "zz"}, "tx 1: failed to decode tx"},
+		"sign":   {unsignable, "tx 1: failed to sign tx"},
+	}
+	for name, test := range tests {
+		file, err := json.Marshal([]any{txs[0], test.tx, txs[0]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		it, err := newJSONTxIterator(io.NopCloser(strings.NewReader(string(file))), types.LatestSignerForChainID(big.NewInt(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for ; it.Next(); n++ {
+			it.Tx()
+		}
+		switch err := it.Err(); {
+		case n != 2:
+			t.Errorf("%s: read %d txs, want the stream to stop at the second", name, n)
+		case exitCode(err) != ErrorJson:
+			t.Errorf("%s: error %v, want code %d", name, err, ErrorJson)
+		case !strings.Contains(err.Error(), test.want):
+			t.Errorf("%s: error %v, want %q", name, err, test.want)
+		}
+	}
+}
@@ -19,6 +19,11 @@ const (
 	ErrorMissingBlockhash = 4
 	ErrorJson = 10
 	ErrorIO   = 11
+	ErrorRlp  = 12
+	ErrorPublicValues = 13
+	ErrorMemoryBudget = 14
+	ErrorProof = 15
+	ErrorMissingState = 16
 	stdinSelector = "stdin"
 )
 
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// witnessSize summarises the trie nodes and code a witness holds.
+func witnessSize(w *stateless.Witness) string {
+	var nodeBytes, codeBytes int
+	for node := range w.State {
+		nodeBytes += len(node)
+	}
+	for code := range w.Codes {
+		codeBytes += len(code)
+	}
+	return fmt.Sprintf("%d trie nodes (%d bytes), %d codes (%d bytes)", len(w.State), nodeBytes, len(w.Codes), codeBytes)
+}
+
+// WitnessStats breaks the size of a witness down by what its trie nodes,
+// code and headers are for. The nodes are placed by walking the trie from
+// the pre-state root.
+type WitnessStats struct {
+	Trie       string    `json:"trie"`
+	TotalBytes int       `json:"totalBytes"`
+	Nodes      NodeCount `json:"nodes"`
+	// For the MPT, AccountNodes are the account trie and StorageNodes the
+	// storage tries. The binary trie is one tree: AccountNodes are its
+	// internal nodes and the stems holding account headers, StorageNodes
+	// the other stems, which hold storage and the code past the header.
+	AccountNodes NodeCount `json:"accountNodes"`
+	StorageNodes NodeCount `json:"storageNodes"`
+	// Unreachable nodes are not on a path from the root through the
+	// witness.
+	Unreachable NodeCount `json:"unreachable"`
+	// Duplicates are nodes reached through more than one path, such as
+	// storage tries two contracts share. The witness holds them once;
+	// DuplicateRefs and DuplicateBytes are the extra references and the
+	// bytes they would cost if every path carried its own copy.
+	Duplicates     int          `json:"duplicates"`
+	DuplicateRefs  int          `json:"duplicateRefs"`
+	DuplicateBytes int          `json:"duplicateBytes"`
+	Depths         []DepthCount `json:"depths"`
+	Codes          NodeCount    `json:"codes"`
+	// UnownedCodes is code no account of the pre-state has, such as code the
+	// block deployed or EIP-7702 delegations it set and then read.
+	UnownedCodes NodeCount `json:"unownedCodes"`
+	Headers      NodeCount `json:"headers"`
+	// Accounts are the accounts with trie nodes or code of their own in the
+	// witness, largest first.
+	Accounts []*AccountWitness `json:"accounts"`
+}
+
+// NodeCount is a number of witness entries and their size.
+type NodeCount struct {
+	Count int `json:"count"`
+	Bytes int `json:"bytes"`
+}
+
+func (c *NodeCount) add(size int) {
+	c.Count++
+	c.Bytes += size
+}
+
+func (c NodeCount) String() string {
+	return fmt.Sprintf("%d (%d bytes)", c.Count, c.Bytes)
+}
+
+// DepthCount is the number of account and storage nodes at a depth. Storage
+// tries count their depth from their own root.
+type DepthCount struct {
+	Depth   int `json:"depth"`
+	Account int `json:"account"`
+	Storage int `json:"storage"`
+}
+
+// AccountWitness is the part of a witness that belongs to one account: its
+// storage trie nodes, or for the binary trie its stems, and its code. A node
+// shared with other accounts counts for the first one reached. Hash is the
+// account's key in the account trie, or for the binary trie its header stem.
+// Address is nil where the input does not name the account.
+type AccountWitness struct {
+	Address   *common.Address `json:"address,omitempty"`
+	Hash      common.Hash     `json:"hash"`
+	Nodes     NodeCount       `json:"nodes"`
+	CodeBytes int             `json:"codeBytes"`
+}
+
+func (a *AccountWitness) size() int {
+	return a.Nodes.Bytes + a.CodeBytes
+}
+
+// witnessWalker places the nodes of a witness while walking its trie.
+type witnessWalker struct {
+	stats    *WitnessStats
+	nodes    map[common.Hash][]byte
+	reached  map[common.Hash]int
+	depths   map[int]*DepthCount
+	accounts map[common.Hash]*AccountWitness
+	// codeOwners maps a code hash to the first account found with it.
+	codeOwners map[common.Hash]*AccountWitness
+	// named maps the account hash of the MPT, or the stem of the binary
+	// trie, to the accounts the input names.
+	named map[common.Hash]common.Address
+}
+
+// newWitnessStats reports on w, a witness of the state at root. accounts are
+// the addresses the input names, with their storage keys, which the binary
+// trie needs to tell whose stems are whose.
+func newWitnessStats(w *stateless.Witness, root common.Hash, binaryTrie bool, accounts map[common.Address][]common.Hash) *WitnessStats {
+	walker := &witnessWalker{
+		stats:      &WitnessStats{Trie: "mpt"},
+		nodes:      make(map[common.Hash][]byte, len(w.State)),
+		reached:    make(map[common.Hash]int, len(w.State)),
+		depths:     make(map[int]*DepthCount),
+		accounts:   make(map[common.Hash]*AccountWitness),
+		codeOwners: make(map[common.Hash]*AccountWitness),
+		named:      make(map[common.Hash]common.Address),
+	}
+	stats := walker.stats
+	for node := range w.State {
+		var hash common.Hash
+		if binaryTrie {
+			hash = binaryNodeHash([]byte(node))
+		} else {
+			hash = crypto.Keccak256Hash([]byte(node))
+		}
+		walker.nodes[hash] = []byte(node)
+		stats.Nodes.add(len(node))
+	}
+	if binaryTrie {
+		stats.Trie = "binary"
+		for addr, slots := range accounts {
+			walker.named[common.BytesToHash(accountStem(addr))] = addr
+			for _, slot := range slots {
+				stem, _ := storageKey(addr, slot[:])
+				walker.named[common.BytesToHash(stem)] = addr
+			}
+		}
+		walker.walkBinary(root, 0)
+	} else {
+		for addr := range accounts {
+			walker.named[crypto.Keccak256Hash(addr[:])] = addr
+		}
+		walker.walkMPT(root, 0, nil, nil)
+	}
+	for hash, node := range walker.nodes {
+		if walker.reached[hash] == 0 {
+			stats.Unreachable.add(len(node))
+		}
+	}
+	for code := range w.Codes {
+		stats.Codes.add(len(code))
+		if owner := walker.codeOwners[crypto.Keccak256Hash([]byte(code))]; owner != nil {
+			owner.CodeBytes += len(code)
+		} else {
+			stats.UnownedCodes.add(len(code))
+		}
+	}
+	for _, h := range w.Headers {
+		enc, _ := rlp.EncodeToBytes(h)
+		stats.Headers.add(len(enc))
+	}
+	stats.TotalBytes = stats.Nodes.Bytes + stats.Codes.Bytes + stats.Headers.Bytes
+	for _, d := range walker.depths {
+		stats.Depths = append(stats.Depths, *d)
+	}
+	sort.Slice(stats.Depths, func(i, j int) bool { return stats.Depths[i].Depth < stats.Depths[j].Depth })
+	for _, a := range walker.accounts {
+		if a.size() > 0 {
+			stats.Accounts = append(stats.Accounts, a)
+		}
+	}
+	sort.Slice(stats.Accounts, func(i, j int) bool {
+		if a, b := stats.Accounts[i], stats.Accounts[j]; a.size() != b.size() {
+			return a.size() > b.size()
+		}
+		return bytes.Compare(stats.Accounts[i].Hash[:], stats.Accounts[j].Hash[:]) < 0
+	})
+	return stats
+}
+
+// visit counts the node hash at depth, in the storage of owner or, for a nil
+// owner, in the account trie. It returns the node if the witness holds it
+// and this is the first path to reach it.
+func (w *witnessWalker) visit(hash common.Hash, depth int, owner *AccountWitness) []byte {
+	node, ok := w.nodes[hash]
+	if !ok {
+		return nil
+	}
+	if w.reached[hash]++; w.reached[hash] > 1 {
+		if w.reached[hash] == 2 {
+			w.stats.Duplicates++
+		}
+		w.stats.DuplicateRefs++
+		w.stats.DuplicateBytes += len(node)
+		return nil
+	}
+	d := w.depths[depth]
+	if d == nil {
+		d = &DepthCount{Depth: depth}
+		w.depths[depth] = d
+	}
+	if owner == nil {
+		d.Account++
+		w.stats.AccountNodes.add(len(node))
+	} else {
+		d.Storage++
+		w.stats.StorageNodes.add(len(node))
+		owner.Nodes.add(len(node))
+	}
+	return node
+}
+
+// account returns the entry of the account with the given hash.
+func (w *witnessWalker) account(hash common.Hash) *AccountWitness {
+	a := w.accounts[hash]
+	if a == nil {
+		a = &AccountWitness{Hash: hash}
+		if addr, ok := w.named[hash]; ok {
+			a.Address = &addr
+		}
+		w.accounts[hash] = a
+	}
+	return a
+}
+
+// walkMPT walks the MPT node hash at depth and path, in the account trie or,
+// with an owner, in its storage trie. Nodes embedded in their parent are not
+// in the witness and hold no hashes, so the walk skips them.
+func (w *witnessWalker) walkMPT(hash common.Hash, depth int, path []byte, owner *AccountWitness) {
+	node := w.visit(hash, depth, owner)
+	if node == nil {
+		return
+	}
+	elems, _, err := rlp.SplitList(node)
+	if err != nil {
+		return
+	}
+	child := func(ref []byte, path []byte) {
+		if kind, content, _, err := rlp.Split(ref); err == nil && kind == rlp.String && len(content) == common.HashLength {
+			w.walkMPT(common.BytesToHash(content), depth+1, path, owner)
+		}
+	}
+	switch n, _ := rlp.CountValues(elems); n {
+	case 2:
+		key, rest, err := rlp.SplitString(elems)
+		if err != nil {
+			return
+		}
+		nibbles, leaf := compactToNibbles(key)
+		path = append(append([]byte{}, path...), nibbles...)
+		if !leaf {
+			child(rest, path)
+			return
+		}
+		if owner != nil || len(path) != 2*common.HashLength {
+			return
+		}
+		value, _, err := rlp.SplitString(rest)
+		if err != nil {
+			return
+		}
+		var account types.StateAccount
+		if rlp.DecodeBytes(value, &account) != nil {
+			return
+		}
+		a := w.account(nibblesToHash(path))
+		if _, ok := w.codeOwners[common.BytesToHash(account.CodeHash)]; !ok {
+			w.codeOwners[common.BytesToHash(account.CodeHash)] = a
+		}
+		if account.Root != types.EmptyRootHash {
+			w.walkMPT(account.Root, 0, nil, a)
+		}
+	case 17:
+		for i := 0; i < 16; i++ {
+			_, _, rest, err := rlp.Split(elems)
+			if err != nil {
+				return
+			}
+			child(elems[:len(elems)-len(rest)], append(append([]byte{}, path...), byte(i)))
+			elems = rest
+		}
+	}
+}
+
+// walkBinary walks the binary trie node hash at depth. Stems are assigned to
+// the account they belong to where the input names it; stems the input does
+// not name count as storage, each as an unnamed account of its own.
+func (w *witnessWalker) walkBinary(hash common.Hash, depth int) {
+	blob, ok := w.nodes[hash]
+	if !ok {
+		return
+	}
+	n, err := decodeBinaryNode(blob, hash)
+	if err != nil {
+		w.visit(hash, depth, nil)
+		return
+	}
+	switch n := n.(type) {
+	case *binaryInternalNode:
+		if w.visit(hash, depth, nil) == nil {
+			return
+		}
+		for _, c := range []binaryNode{n.left, n.right} {
+			if c, ok := c.(binaryHashedNode); ok {
+				w.walkBinary(common.Hash(c), depth+1)
+			}
+		}
+	case *binaryStemNode:
+		stem := common.BytesToHash(n.stem)
+		addr, named := w.named[stem]
+		if !named {
+			w.visit(hash, depth, w.account(stem))
+			return
+		}
+		a := w.account(common.BytesToHash(accountStem(addr)))
+		if !bytes.Equal(n.stem, accountStem(addr)) {
+			w.visit(hash, depth, a)
+			return
+		}
+		// The header stem counts with the account nodes, and for its
+		// account.
+		if w.visit(hash, depth, nil) != nil {
+			a.Nodes.add(len(blob))
+		}
+		if codeHash := n.values[codeHashLeafKey]; codeHash != nil {
+			if _, ok := w.codeOwners[common.BytesToHash(codeHash)]; !ok {
+				w.codeOwners[common.BytesToHash(codeHash)] = a
+			}
+		}
+	}
+}
+
+// binaryNodeHash returns the hash of a serialized binary trie node.
+func binaryNodeHash(blob []byte) common.Hash {
+	n, err := decodeBinaryNode(blob, common.Hash{})
+	if err != nil {
+		return binaryHash(blob)
+	}
+	switch n := n.(type) {
+	case *binaryInternalNode:
+		n.hash = nil
+	case *binaryStemNode:
+		n.hash = nil
+	}
+	return hashBinaryNode(n)
+}
+
+// compactToNibbles decodes the hex-prefix encoded key of a short node, and
+// reports whether the node is a leaf.
+func compactToNibbles(compact []byte) ([]byte, bool) {
+	if len(compact) == 0 {
+		return nil, false
+	}
+	flag := compact[0] >> 4
+	var nibbles []byte
+	if flag&1 != 0 {
+		nibbles = append(nibbles, compact[0]&0xf)
+	}
+	for _, b := range compact[1:] {
+		nibbles = append(nibbles, b>>4, b&0xf)
+	}
+	return nibbles, flag&2 != 0
+}
+
+func nibblesToHash(nibbles []byte) common.Hash {
+	var h common.Hash
+	for i := range h {
+		h[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return h
+}
+
+// maxReportedAccounts bounds the accounts String lists; the JSON report has
+// them all.
+const maxReportedAccounts = 10
+
+// String renders the report for reading.
+func (s *WitnessStats) String() string {
+	var sb strings.Builder
+	accountLabel, storageLabel := "account trie", "storage tries"
+	if s.Trie == "binary" {
+		accountLabel, storageLabel = "internal and header stems", "other stems"
+	}
+	fmt.Fprintf(&sb, "Witness stats (%s trie): %d bytes\n", s.Trie, s.TotalBytes)
+	fmt.Fprintf(&sb, "  trie nodes     %s\n", s.Nodes)
+	fmt.Fprintf(&sb, "    %-26s %s\n", accountLabel, s.AccountNodes)
+	fmt.Fprintf(&sb, "    %-26s %s\n", storageLabel, s.StorageNodes)
+	fmt.Fprintf(&sb, "    %-26s %s\n", "unreachable", s.Unreachable)
+	fmt.Fprintf(&sb, "    %-26s %d, %d extra references (%d bytes)\n", "duplicates", s.Duplicates, s.DuplicateRefs, s.DuplicateBytes)
+	fmt.Fprintf(&sb, "  codes          %s\n", s.Codes)
+	fmt.Fprintf(&sb, "    %-26s %s\n", "unowned", s.UnownedCodes)
+	fmt.Fprintf(&sb, "  headers        %s\n", s.Headers)
+	fmt.Fprintf(&sb, "  depth  %7s  %7s\n", "account", "storage")
+	for _, d := range s.Depths {
+		fmt.Fprintf(&sb, "  %5d  %7d  %7d\n", d.Depth, d.Account, d.Storage)
+	}
+	for i, a := range s.Accounts {
+		if i == maxReportedAccounts {
+			fmt.Fprintf(&sb, "  ... %d more accounts\n", len(s.Accounts)-i)
+			break
+		}
+		name := a.Hash.Hex() + " (unnamed)"
+		if a.Address != nil {
+			name = a.Address.Hex()
+		}
+		fmt.Fprintf(&sb, "  %s  %d bytes: %d nodes (%d bytes), code %d bytes\n", name, a.size(), a.Nodes.Count, a.Nodes.Bytes, a.CodeBytes)
+	}
+	return sb.String()
+}
+
+// witnessAccounts returns the addresses of the pre-state's accounts with
+// their storage keys, to name the accounts of a witness report.
+func witnessAccounts(pre types.GenesisAlloc, proofs *stateProofs) map[common.Address][]common.Hash {
+	accounts := make(map[common.Address][]common.Hash)
+	for addr, account := range pre {
+		keys := make([]common.Hash, 0, len(account.Storage))
+		for k := range account.Storage {
+			keys = append(keys, k)
+		}
+		accounts[addr] = keys
+	}
+	if proofs != nil {
+		for _, p := range proofs.Accounts {
+			accounts[p.Address] = nil
+		}
+	}
+	return accounts
+}
@@ -0,0 +1,72 @@
+// Command syscall-diff compares the syscalls of two traces, for example the Go
+// and Rust executors, GC on and off, or GOMAXPROCS=1 and the default.
+//
+// Each input is either a raw `strace -o` log or an analysis JSON as written by
+// `syscall-diff -profile`.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/eth-act/riscv-compilation/syscalls"
+)
+
+func main() {
+	var (
+		since   = flag.String("since", "", "skip strace lines before the first one containing this marker")
+		asJSON  = flag.Bool("json", false, "print the diff as JSON")
+		profile = flag.Bool("profile", false, "print the analysis JSON of a single trace instead of a diff")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: syscall-diff [flags] <trace-a> <trace-b>\n       syscall-diff -profile [flags] <trace>\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *profile {
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		p, err := syscalls.Load(flag.Arg(0), *since)
+		if err != nil {
+			fatal(err)
+		}
+		writeJSON(p)
+		return
+	}
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	a, err := syscalls.Load(flag.Arg(0), *since)
+	if err != nil {
+		fatal(err)
+	}
+	b, err := syscalls.Load(flag.Arg(1), *since)
+	if err != nil {
+		fatal(err)
+	}
+	diff := syscalls.Compare(a, b)
+	if *asJSON {
+		writeJSON(diff)
+		return
+	}
+	fmt.Print(diff)
+}
+
+func writeJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "syscall-diff: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,71 @@
+// Command syscall-tier checks a trace or a static syscall set against the
+// zkVM Linux-ABI tiers defined in syscalls/tiers.json, and reports the lowest
+// tier that can run the binary.
+//
+// With -tier, the exit status tells whether a zkVM offering that tier can run
+// the binary, which makes it usable as a CI gate.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/eth-act/riscv-compilation/syscalls"
+)
+
+func main() {
+	var (
+		since  = flag.String("since", "", "skip strace lines before the first one containing this marker")
+		tier   = flag.String("tier", "", "exit with status 1 unless this tier can run the binary")
+		asJSON = flag.Bool("json", false, "print the report as JSON")
+		list   = flag.Bool("list", false, "print the tier definitions and exit")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: syscall-tier [flags] <trace|analysis.json|syscalls.txt>\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *list {
+		writeJSON(syscalls.Tiers())
+		return
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	p, err := syscalls.Load(flag.Arg(0), *since)
+	if err != nil {
+		fatal(err)
+	}
+	report := syscalls.Check(p)
+	if *asJSON {
+		writeJSON(report)
+	} else {
+		fmt.Print(report)
+	}
+	if *tier != "" {
+		result, ok := report.Result(syscalls.Tier(*tier))
+		if !ok {
+			fatal(fmt.Errorf("unknown tier %q", *tier))
+		}
+		if !result.Sufficient {
+			os.Exit(1)
+		}
+	}
+}
+
+func writeJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "syscall-tier: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,145 @@
+package syscalls
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Violation is a syscall that falls outside a tier, together with the
+// argument patterns it was invoked with.
+type Violation struct {
+	Name     string         `json:"name"`
+	Count    int            `json:"count"`
+	Patterns map[string]int `json:"patterns,omitempty"`
+}
+
+// TierResult is the verdict for a single tier.
+type TierResult struct {
+	Tier       Tier        `json:"tier"`
+	Sufficient bool        `json:"sufficient"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Report is the outcome of checking a profile against all tiers.
+type Report struct {
+	Source string       `json:"source"`
+	Lowest Tier         `json:"lowest"`
+	Tiers  []TierResult `json:"tiers"`
+}
+
+// Check determines the lowest tier able to run the traced binary, and lists
+// for every tier the syscalls that fall outside it. Argument rules are only
+// checked for profiles that carry the individual calls; a static syscall set
+// is checked by name.
+func Check(p *Profile) *Report {
+	report := &Report{Source: p.Source, Lowest: TierNone}
+	for i, def := range definitions {
+		result := TierResult{Tier: def.Name, Sufficient: true}
+		violations := make(map[string]*Violation)
+		outside := func(call Call, count int) {
+			v := violations[call.Name]
+			if v == nil {
+				v = &Violation{Name: call.Name, Patterns: make(map[string]int)}
+				violations[call.Name] = v
+			}
+			v.Count += count
+			if pattern := argPattern(call); pattern != "" {
+				v.Patterns[pattern] += count
+			}
+		}
+		seen := make(map[string]bool)
+		for _, call := range p.Calls {
+			seen[call.Name] = true
+			if !admitted(definitions[:i+1], call) {
+				outside(call, 1)
+			}
+		}
+		for name, count := range p.Syscalls {
+			if !seen[name] && !admitted(definitions[:i+1], Call{Name: name}) {
+				outside(Call{Name: name}, count)
+			}
+		}
+		for _, v := range violations {
+			result.Violations = append(result.Violations, *v)
+		}
+		sort.Slice(result.Violations, func(a, b int) bool {
+			return result.Violations[a].Name < result.Violations[b].Name
+		})
+		result.Sufficient = len(result.Violations) == 0
+		if result.Sufficient && report.Lowest == TierNone {
+			report.Lowest = def.Name
+		}
+		report.Tiers = append(report.Tiers, result)
+	}
+	return report
+}
+
+// Result returns the verdict for the named tier.
+func (r *Report) Result(tier Tier) (TierResult, bool) {
+	for _, result := range r.Tiers {
+		if result.Tier == tier {
+			return result, true
+		}
+	}
+	return TierResult{}, false
+}
+
+func admitted(defs []TierDef, call Call) bool {
+	for _, def := range defs {
+		for _, rule := range def.Syscalls {
+			if rule.admits(call) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// argPattern renders the arguments of a call that any tier puts a rule on,
+// e.g. `flags=MAP_PRIVATE|MAP_ANONYMOUS fd=-1` for mmap.
+func argPattern(call Call) string {
+	var parts []string
+	for _, def := range definitions {
+		for _, rule := range def.Syscalls {
+			if !rule.matchName(call.Name) {
+				continue
+			}
+			for _, arg := range rule.Args {
+				if value, ok := arg.value(call.Args); ok {
+					parts = append(parts, arg.Name+"="+value)
+				}
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// String renders the report as a plain-text summary.
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Trace:       %s\nLowest tier: %s\n", r.Source, r.Lowest)
+	for _, result := range r.Tiers {
+		if result.Sufficient {
+			fmt.Fprintf(&sb, "\n%s: sufficient\n", result.Tier)
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s: not sufficient, %d syscalls outside\n", result.Tier, len(result.Violations))
+		for _, v := range result.Violations {
+			count := "" // static syscall sets carry no counts
+			if v.Count > 0 {
+				count = fmt.Sprintf("x%d", v.Count)
+			}
+			fmt.Fprintf(&sb, "  %-20s %-7s %s\n", v.Name, count, TierOf(v.Name))
+			patterns := make([]string, 0, len(v.Patterns))
+			for pattern := range v.Patterns {
+				patterns = append(patterns, pattern)
+			}
+			sort.Strings(patterns)
+			for _, pattern := range patterns {
+				fmt.Fprintf(&sb, "      x%-6d %s\n", v.Patterns[pattern], pattern)
+			}
+		}
+	}
+	return sb.String()
+}
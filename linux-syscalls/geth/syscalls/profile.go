@@ -0,0 +1,186 @@
+// Package syscalls parses syscall traces of the stateless executors and
+// classifies them against the zkVM Linux-ABI tiers proposed in REPORT.md.
+package syscalls
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var syscallName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Call is a single syscall invocation as recorded in a trace.
+type Call struct {
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"`
+	Ret  string   `json:"ret,omitempty"`
+}
+
+// Profile is the analysis of one trace: how often every syscall and signal
+// was seen. It is also the "analysis JSON" format accepted by the tools.
+type Profile struct {
+	Source   string         `json:"source,omitempty"`
+	Syscalls map[string]int `json:"syscalls"`
+	Signals  map[string]int `json:"signals,omitempty"`
+	Calls    []Call         `json:"calls,omitempty"`
+}
+
+// NewProfile returns an empty profile.
+func NewProfile() *Profile {
+	return &Profile{
+		Syscalls: make(map[string]int),
+		Signals:  make(map[string]int),
+	}
+}
+
+// Add records a call in the profile.
+func (p *Profile) Add(call Call) {
+	p.Syscalls[call.Name]++
+	p.Calls = append(p.Calls, call)
+}
+
+// Names returns the syscalls seen in the profile, sorted alphabetically.
+func (p *Profile) Names() []string {
+	names := make([]string, 0, len(p.Syscalls))
+	for name := range p.Syscalls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newStaticProfile creates a profile from a static syscall set, e.g. one
+// derived from disassembling a binary. Counts are unknown and left at zero.
+func newStaticProfile(names []string) *Profile {
+	p := NewProfile()
+	for _, name := range names {
+		p.Syscalls[name] += 0
+	}
+	return p
+}
+
+// parseNames parses a static syscall set written as one name per line, with
+// `#` comments. It reports false if the data is not in that format.
+func parseNames(data []byte) ([]string, bool) {
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !syscallName.MatchString(line) {
+			return nil, false
+		}
+		names = append(names, line)
+	}
+	return names, len(names) > 0
+}
+
+// Load reads a profile from an analysis JSON, a raw strace log or a static
+// syscall set (a JSON array or a file with one name per line). The since
+// marker only applies to strace logs, see ParseStrace.
+func Load(path string, since string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p *Profile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		p = NewProfile()
+		if err := json.Unmarshal(trimmed, p); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		if p.Syscalls == nil {
+			p.Syscalls = make(map[string]int)
+		}
+		if p.Signals == nil {
+			p.Signals = make(map[string]int)
+		}
+	} else if len(trimmed) > 0 && trimmed[0] == '[' {
+		var names []string
+		if err := json.Unmarshal(trimmed, &names); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+		p = newStaticProfile(names)
+	} else if names, ok := parseNames(trimmed); ok {
+		p = newStaticProfile(names)
+	} else {
+		if p, err = ParseStrace(bytes.NewReader(data), since); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", path, err)
+		}
+	}
+	if p.Source == "" {
+		p.Source = path
+	}
+	return p, nil
+}
+
+// Delta is the comparison of a single syscall between two profiles.
+type Delta struct {
+	Name  string `json:"name"`
+	Tier  Tier   `json:"tier"`
+	A     int    `json:"a"`
+	B     int    `json:"b"`
+	Delta int    `json:"delta"`
+}
+
+// Diff is the differential view of two profiles.
+type Diff struct {
+	A      string  `json:"a"`
+	B      string  `json:"b"`
+	OnlyA  []Delta `json:"onlyA"`
+	OnlyB  []Delta `json:"onlyB"`
+	Shared []Delta `json:"shared"`
+}
+
+// Compare lists the syscalls that appear only in a, only in b and the count
+// deltas (b - a) of the ones that appear in both.
+func Compare(a, b *Profile) *Diff {
+	d := &Diff{A: a.Source, B: b.Source}
+	for _, name := range a.Names() {
+		delta := Delta{Name: name, Tier: TierOf(name), A: a.Syscalls[name], B: b.Syscalls[name]}
+		delta.Delta = delta.B - delta.A
+		if _, ok := b.Syscalls[name]; ok {
+			d.Shared = append(d.Shared, delta)
+		} else {
+			d.OnlyA = append(d.OnlyA, delta)
+		}
+	}
+	for _, name := range b.Names() {
+		if _, ok := a.Syscalls[name]; !ok {
+			d.OnlyB = append(d.OnlyB, Delta{Name: name, Tier: TierOf(name), B: b.Syscalls[name], Delta: b.Syscalls[name]})
+		}
+	}
+	return d
+}
+
+// String renders the diff as a plain-text report.
+func (d *Diff) String() string {
+	var sb strings.Builder
+	section := func(title string, deltas []Delta) {
+		fmt.Fprintf(&sb, "%s (%d)\n", title, len(deltas))
+		if len(deltas) == 0 {
+			sb.WriteString("  -\n")
+			return
+		}
+		fmt.Fprintf(&sb, "  %-24s %-10s %8s %8s %8s\n", "SYSCALL", "TIER", "A", "B", "DELTA")
+		for _, delta := range deltas {
+			fmt.Fprintf(&sb, "  %-24s %-10s %8d %8d %+8d\n", delta.Name, delta.Tier, delta.A, delta.B, delta.Delta)
+		}
+	}
+	fmt.Fprintf(&sb, "A: %s\nB: %s\n\n", d.A, d.B)
+	section("Only in A", d.OnlyA)
+	sb.WriteString("\n")
+	section("Only in B", d.OnlyB)
+	sb.WriteString("\n")
+	section("Shared", d.Shared)
+	return sb.String()
+}
@@ -0,0 +1,117 @@
+package syscalls
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// stracePrefix matches the optional "[pid N]" / "N " prefix written by
+	// `strace -f` and the optional timestamp written by `strace -t/-tt/-r`.
+	stracePrefix = regexp.MustCompile(`^(?:\[pid\s+\d+\]\s+|\d+\s+)?(?:\d+(?::\d+:\d+)?(?:\.\d+)?\s+)?`)
+	straceCall   = regexp.MustCompile(`^([a-z_][a-z0-9_]*)\((.*)$`)
+	straceSignal = regexp.MustCompile(`^--- (SIG[A-Z0-9]+) `)
+)
+
+// ParseStrace reads a log produced by `strace -o` and collects every syscall
+// it contains. If since is not empty, all lines before the first line
+// containing it are skipped, which is how REPORT.md separates the dynamic
+// loader from the application logic (e.g. since=`write(1, "Starting`).
+func ParseStrace(r io.Reader, since string) (*Profile, error) {
+	var (
+		p       = NewProfile()
+		scanner = bufio.NewScanner(r)
+		started = since == ""
+		lineNo  = 0
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if !started {
+			if !strings.Contains(line, since) {
+				continue
+			}
+			started = true
+		}
+		line = stracePrefix.ReplaceAllString(strings.TrimSpace(line), "")
+		if line == "" || strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "<...") {
+			// Exit notices carry no syscall, and resumed calls were already
+			// counted when strace printed them as unfinished.
+			continue
+		}
+		if m := straceSignal.FindStringSubmatch(line); m != nil {
+			p.Signals[m[1]]++
+			continue
+		}
+		m := straceCall.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: unrecognised strace line %q", lineNo, line)
+		}
+		call := Call{Name: m[1]}
+		rest := m[2]
+		if idx := strings.Index(rest, "<unfinished ...>"); idx >= 0 {
+			call.Args = splitArgs(strings.TrimSpace(rest[:idx]))
+		} else if idx := strings.LastIndex(rest, ") = "); idx >= 0 {
+			call.Args = splitArgs(rest[:idx])
+			call.Ret = strings.TrimSpace(rest[idx+len(") = "):])
+		} else if idx := strings.LastIndex(rest, ")"); idx >= 0 {
+			// Calls such as exit_group may be cut off with only padding after
+			// the closing parenthesis.
+			call.Args = splitArgs(rest[:idx])
+			call.Ret = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest[idx+1:]), "="))
+		} else {
+			return nil, fmt.Errorf("line %d: unterminated syscall %q", lineNo, line)
+		}
+		p.Add(call)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, fmt.Errorf("marker %q not found in trace", since)
+	}
+	return p, nil
+}
+
+// splitArgs splits a strace argument list on its top-level commas, leaving
+// quoted strings, structs, arrays and nested calls intact.
+func splitArgs(s string) []string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	var (
+		args    []string
+		depth   int
+		quoted  bool
+		escaped bool
+		start   int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				quoted = false
+			}
+		case c == '"':
+			quoted = true
+		case c == '{' || c == '[' || c == '(':
+			depth++
+		case c == '}' || c == ']' || c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(s[start:]))
+}
@@ -0,0 +1,132 @@
+package syscalls
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Tier is a level of Linux-ABI support a zkVM syscall dispatcher can offer,
+// following "The Syscall Dispatcher Revisited" in REPORT.md. zkVM-specific
+// precompiles are dispatched separately and are not Linux syscalls, so they
+// have no tier here.
+type Tier string
+
+const (
+	// TierBasic is the minimal set seen in the reth-stateless analysis, enough
+	// for Rust and C/C++ guests.
+	TierBasic Tier = "basic"
+	// TierAdvanced adds threading, mmap-based memory management and signal
+	// handling, as required by the Go runtime.
+	TierAdvanced Tier = "advanced"
+	// TierNone marks syscalls that neither tier covers.
+	TierNone Tier = "none"
+)
+
+// tiersJSON is the machine-readable tier definition. Tiers are listed from the
+// lowest to the highest, and every tier includes the ones before it.
+//
+//go:embed tiers.json
+var tiersJSON []byte
+
+// ArgRule restricts one argument of a syscall to a set of values. Flag
+// arguments such as `MAP_PRIVATE|MAP_ANONYMOUS` are allowed if every flag is.
+type ArgRule struct {
+	Name  string   `json:"name"`  // Name strace uses for the argument, e.g. flags in `flags=CLONE_VM`
+	Index int      `json:"index"` // Position of the argument when strace prints it unnamed
+	Allow []string `json:"allow"` // Values or flags the tier supports
+}
+
+// SyscallRule admits a syscall into a tier. Name may be a glob such as
+// `epoll_*`; without Args every invocation is admitted.
+type SyscallRule struct {
+	Name string    `json:"name"`
+	Args []ArgRule `json:"args,omitempty"`
+}
+
+// TierDef is the definition of a single tier.
+type TierDef struct {
+	Name        Tier          `json:"name"`
+	Description string        `json:"description"`
+	Syscalls    []SyscallRule `json:"syscalls"`
+}
+
+var definitions = mustParseTiers(tiersJSON)
+
+func mustParseTiers(data []byte) []TierDef {
+	var defs struct {
+		Tiers []TierDef `json:"tiers"`
+	}
+	if err := json.Unmarshal(data, &defs); err != nil {
+		panic(fmt.Sprintf("invalid tier definition: %v", err))
+	}
+	return defs.Tiers
+}
+
+// Tiers returns the tier definitions, lowest tier first.
+func Tiers() []TierDef {
+	return definitions
+}
+
+// TierOf returns the lowest tier that lists a syscall, regardless of its
+// arguments, or TierNone if it is not part of any tier.
+func TierOf(name string) Tier {
+	for _, def := range definitions {
+		for _, rule := range def.Syscalls {
+			if rule.matchName(name) {
+				return def.Name
+			}
+		}
+	}
+	return TierNone
+}
+
+func (r *SyscallRule) matchName(name string) bool {
+	ok, _ := path.Match(r.Name, name)
+	return ok
+}
+
+// admits reports whether a call is covered by the rule. Arguments that are not
+// present in the call (e.g. a static syscall set) are not checked.
+func (r *SyscallRule) admits(call Call) bool {
+	if !r.matchName(call.Name) {
+		return false
+	}
+	for _, arg := range r.Args {
+		value, ok := arg.value(call.Args)
+		if !ok {
+			continue
+		}
+		for _, flag := range strings.Split(value, "|") {
+			if !contains(arg.Allow, strings.TrimSpace(flag)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// value returns the argument the rule applies to, looking it up by name first
+// and by position second.
+func (a *ArgRule) value(args []string) (string, bool) {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, a.Name+"=") {
+			return strings.TrimPrefix(arg, a.Name+"="), true
+		}
+	}
+	if a.Index < len(args) && !strings.Contains(args[a.Index], "=") {
+		return args[a.Index], true
+	}
+	return "", false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}